@@ -567,6 +567,8 @@ export default {
      * @returns {Promise<Response>}
      */
     async fetch(request, env, ctx) {
+        // 允许通过 Worker 变量覆盖上游地址（例如一致性测试时指向 doubao mock-upstream）
+        if (env?.DOUBAO_BASE_URL) CONFIG.DOUBAO_BASE_URL = env.DOUBAO_BASE_URL;
         const url = new URL(request.url);
 
         // 在 Cloudflare 环境中，所有外部请求默认都是 HTTPS，此检查主要用于防御 x-forwarded-proto 伪造
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"doubao/conformance"
+	"doubao/mockupstream"
+	"doubao/translator"
+)
+
+// doubao conformance：对多个实现运行同一组用例并报告行为差异，第一个目标为比较基准。
+
+const conformanceUsage = `Usage: doubao conformance [flags]
+
+Run the shared request corpus against every --target and report fields whose
+status, body, SSE events or upstream payload differ from the first target.
+All targets must send their upstream calls to the mock started on --mock-addr,
+e.g. wrangler dev --var DOUBAO_BASE_URL:http://127.0.0.1:18080/api/v3/responses
+
+Examples:
+  doubao conformance --target go --target worker=http://127.0.0.1:8787
+  doubao conformance --target go --target edge=https://example.com --category streaming -v
+
+Flags:
+`
+
+type conformanceTargets []conformance.Target
+
+func (t *conformanceTargets) String() string { return "" }
+
+func (t *conformanceTargets) Set(value string) error {
+	name, baseURL, _ := strings.Cut(value, "=")
+	if name == "" {
+		return errors.New("target name is empty")
+	}
+	if baseURL == "" && name != "go" {
+		return fmt.Errorf("target %q needs a URL (name=url); only \"go\" runs in-process", name)
+	}
+	*t = append(*t, conformance.Target{Name: name, BaseURL: baseURL})
+	return nil
+}
+
+func runConformance(args []string) int {
+	flags := flag.NewFlagSet("conformance", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), conformanceUsage)
+		flags.PrintDefaults()
+	}
+	var targets conformanceTargets
+	var ignore conformance.Matcher
+	flags.Var(&targets, "target", "implementation to test as name=url; \"go\" alone runs this binary's handler in-process (repeatable, default go)")
+	flags.Func("ignore", "field to leave out of the comparison, e.g. 'body.detected_source_language' or 'events[*].data.passthrough' (repeatable)", func(value string) error {
+		ignore = append(ignore, value)
+		return nil
+	})
+	mockAddr := flags.String("mock-addr", "127.0.0.1:18080", "listen address of the mock upstream shared by all targets")
+	casesPath := flags.String("cases", "", "JSON corpus to use instead of the built-in cases")
+	categories := flags.String("category", "", "comma-separated categories to run (system-prompt, overrides, errors, streaming)")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout for each request")
+	verbose := flags.Bool("v", false, "print every observed field, not only differences")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "doubao conformance: unexpected argument %q\n", flags.Arg(0))
+		return 2
+	}
+	if len(targets) == 0 {
+		targets = conformanceTargets{{Name: "go"}}
+	}
+
+	cases := conformance.DefaultCases()
+	if *casesPath != "" {
+		data, err := os.ReadFile(*casesPath)
+		if err == nil {
+			cases, err = conformance.ParseCases(data)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "doubao conformance: --cases: %v\n", err)
+			return 2
+		}
+	}
+	if *categories != "" {
+		wanted := map[string]bool{}
+		for _, category := range strings.Split(*categories, ",") {
+			wanted[strings.TrimSpace(category)] = true
+		}
+		filtered := cases[:0]
+		for _, c := range cases {
+			if wanted[c.Category] {
+				filtered = append(filtered, c)
+			}
+		}
+		cases = filtered
+	}
+
+	// 流式用例依赖较小的分块，以便出现只含换行的 delta。
+	mock := mockupstream.New(mockupstream.Options{ChunkRunes: 4})
+	listener, err := net.Listen("tcp", *mockAddr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "doubao conformance: mock upstream: %v\n", err)
+		return 1
+	}
+	mockSrv := &http.Server{Handler: mock, ErrorLog: log.New(io.Discard, "", 0)}
+	go mockSrv.Serve(listener)
+	defer mockSrv.Close()
+	mockURL := "http://" + listener.Addr().String() + mockupstream.Path
+	fmt.Fprintf(os.Stderr, "mock upstream: %s\n", mockURL)
+
+	for i, target := range targets {
+		if target.BaseURL != "" {
+			continue
+		}
+		// 进程内的 Go 实现：直接指向 mock，关闭心跳以免注释行混入比较。
+		translator.CONFIG.DoubaoBaseURL = mockURL
+		translator.CONFIG.StreamHeartbeatInterval = 0
+		handler := translator.NewHandler()
+		defer handler.Close()
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+		targets[i].BaseURL = srv.URL
+	}
+
+	runner := &conformance.Runner{Client: &http.Client{Timeout: *timeout}, Mock: mock}
+	results := make([][]conformance.Observation, len(targets))
+	for i, target := range targets {
+		results[i] = runner.Run(context.Background(), target, cases)
+	}
+	return reportConformance(os.Stdout, targets, cases, results, ignore, *verbose)
+}
+
+func reportConformance(w io.Writer, targets conformanceTargets, cases []conformance.Case, results [][]conformance.Observation, ignore conformance.Matcher, verbose bool) int {
+	failed := 0
+	for ci, c := range cases {
+		reference := results[0][ci]
+		var diffs []conformance.Difference
+		for ti := 1; ti < len(targets); ti++ {
+			diffs = append(diffs, conformance.Compare(reference, results[ti][ci], ignore)...)
+		}
+		status := "ok  "
+		if len(diffs) > 0 || reference.Error != "" {
+			status = "DIFF"
+			failed++
+		}
+		fmt.Fprintf(w, "%s  %-14s %s\n", status, c.Category, c.Name)
+		if reference.Error != "" && len(diffs) == 0 {
+			fmt.Fprintf(w, "      %s: request failed: %s\n", targets[0].Name, reference.Error)
+		}
+		for _, d := range diffs {
+			fmt.Fprintf(w, "      [%s] %s: %s=%s %s=%s\n", d.Target, d.Field, targets[0].Name, d.Reference, d.Target, d.Value)
+		}
+		if verbose {
+			for ti, target := range targets {
+				obs := results[ti][ci]
+				fields := make([]string, 0, len(obs.Fields))
+				for field := range obs.Fields {
+					fields = append(fields, field)
+				}
+				sort.Strings(fields)
+				for _, field := range fields {
+					fmt.Fprintf(w, "      [%s] %s = %s\n", target.Name, field, obs.Fields[field])
+				}
+			}
+		}
+	}
+
+	names := make([]string, len(targets))
+	for i, target := range targets {
+		names[i] = target.Name
+	}
+	fmt.Fprintf(w, "\n%d cases against %s: %d consistent, %d with differences\n", len(cases), strings.Join(names, ", "), len(cases)-failed, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
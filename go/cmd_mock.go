@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"doubao/mockupstream"
+)
+
+// doubao mock-upstream：在本地启动方舟 Responses 接口的替身，配合 DOUBAO_BASE_URL 离线联调代理。
+
+const mockUsage = `Usage: doubao mock-upstream [flags]
+
+Serve a fake Ark /api/v3/responses endpoint with deterministic "[lang] text" translations.
+Point the proxy at it with DOUBAO_BASE_URL=http://<addr>/api/v3/responses.
+Requests whose model is mock-429, mock-500, mock-malformed or mock-truncated always fail that way.
+
+Flags:
+`
+
+func runMockUpstream(args []string) int {
+	flags := flag.NewFlagSet("mock-upstream", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), mockUsage)
+		flags.PrintDefaults()
+	}
+	addr := flags.String("addr", "127.0.0.1:18080", "listen address")
+	latency := flags.Duration("latency", 0, "delay before the response headers")
+	chunkDelay := flags.Duration("chunk-delay", 0, "delay between streamed events")
+	chunkRunes := flags.Int("chunk-runes", 8, "characters per response.output_text.delta")
+	fault := flags.String("fault", "", "inject an error: 429, 500, malformed or truncated")
+	faultRate := flags.Float64("fault-rate", 1, "fraction of requests that get the injected error")
+	anonymous := flags.Bool("allow-anonymous", false, "accept requests without an Authorization header")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "doubao mock-upstream: unexpected argument %q\n", flags.Arg(0))
+		return 2
+	}
+	injected, err := mockupstream.ParseFault(*fault)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "doubao mock-upstream: --fault: %v\n", err)
+		return 2
+	}
+
+	mock := mockupstream.New(mockupstream.Options{
+		Latency:        *latency,
+		ChunkDelay:     *chunkDelay,
+		ChunkRunes:     *chunkRunes,
+		Fault:          injected,
+		FaultRate:      *faultRate,
+		AllowAnonymous: *anonymous,
+	})
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Printf("mock upstream: %v", err)
+		return 1
+	}
+	srv := &http.Server{Handler: mock, ReadTimeout: 30 * time.Second, IdleTimeout: 60 * time.Second}
+	log.Printf("Mock Ark upstream listening on http://%s%s", listener.Addr(), mockupstream.Path)
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("mock upstream: %v", err)
+		return 1
+	}
+	return 0
+}
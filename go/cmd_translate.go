@@ -0,0 +1,704 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"doubao/translator"
+)
+
+// doubao translate：使用与服务端相同的上游客户端与语言解析翻译文件或标准输入。
+// 已完成的片段写入状态文件，中断后重新执行同一命令会跳过已翻译的片段与已生成的文件。
+
+const translateUsage = `Usage: doubao translate [flags] [file|dir|- ...]
+
+Translate files, directories (with -r) or stdin using the Doubao translation model.
+Formats (txt, md, html, srt, json) are detected from the file extension or content.
+
+Examples:
+  doubao translate --to ja README.md
+  cat notes.txt | doubao translate --to en
+  doubao translate --to zh-TW -r docs/ -o build/i18n --name '{dir}/{name}.{lang}{ext}'
+
+Flags:
+`
+
+const defaultNameTemplate = "{dir}/{name}.{lang}{ext}"
+
+type translateCommand struct {
+	client       *translator.Client
+	options      translator.Options
+	lang         string
+	format       docFormat
+	nameTemplate string
+	overwrite    bool
+	concurrency  int
+	retries      int
+	progress     *progressReporter
+	state        *translateState
+}
+
+type translateJob struct {
+	// input 为空表示标准输入，output 为空表示标准输出。
+	input   string
+	output  string
+	display string
+}
+
+type translateStats struct {
+	files    int
+	skipped  int
+	failed   int
+	segments int
+	tokens   int
+}
+
+func runTranslate(args []string) int {
+	flags := flag.NewFlagSet("translate", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), translateUsage)
+		flags.PrintDefaults()
+	}
+	to := flags.String("to", "", "target language: code, name or BCP-47 tag (default DEFAULT_TARGET_LANGUAGE or zh)")
+	from := flags.String("from", "", "source language (default: auto-detect)")
+	model := flags.String("model", os.Getenv("DOUBAO_MODEL"), "model / endpoint ID (env DOUBAO_MODEL)")
+	apiKey := flags.String("api-key", firstEnv("ARK_API_KEY", "DOUBAO_API_KEY"), "Ark API key (env ARK_API_KEY or DOUBAO_API_KEY)")
+	baseURL := flags.String("base-url", "", "upstream Responses endpoint (default "+translator.CONFIG.DoubaoBaseURL+")")
+	format := flags.String("format", "", "force input format: txt, md, html, srt or json")
+	output := flags.String("o", "", "output file, or output directory for multiple inputs")
+	name := flags.String("name", "", "output naming template for multiple inputs; placeholders {dir} {name} {ext} {lang} (default \""+defaultNameTemplate+"\")")
+	recursive := flags.Bool("r", false, "translate directories recursively")
+	overwrite := flags.Bool("overwrite", false, "re-translate files whose output already exists")
+	skipSame := flags.Bool("skip-same-language", false, "return segments unchanged when already in the target language")
+	concurrency := flags.Int("concurrency", 4, "segments translated in parallel per file")
+	retries := flags.Int("retries", 3, "retries for rate-limited or failed upstream calls")
+	statePath := flags.String("state", "", "progress file used to resume interrupted runs (default next to the output)")
+	quiet := flags.Bool("q", false, "do not report progress on stderr")
+
+	inputs, err := parseInterspersed(flags, args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	if *to == "" {
+		*to = translator.CONFIG.DefaultTargetLanguage
+	}
+	lang, err := translator.ResolveLanguage(*to)
+	if err != nil {
+		return usageError("--to: %v", err)
+	}
+	if *from != "" {
+		if _, err := translator.ResolveLanguage(*from); err != nil {
+			return usageError("--from: %v", err)
+		}
+	}
+	if *apiKey == "" {
+		return usageError("missing API key: set ARK_API_KEY or pass --api-key")
+	}
+	if *model == "" {
+		return usageError("missing model: set DOUBAO_MODEL or pass --model")
+	}
+	if *concurrency < 1 {
+		*concurrency = 1
+	}
+	if err := translator.LoadPIIPolicy(translator.CONFIG.PIIMasking, translator.CONFIG.PIIReject, translator.CONFIG.PIIPatternsFile); err != nil {
+		fmt.Fprintf(os.Stderr, "doubao translate: failed to load PII policy: %v\n", err)
+		return 1
+	}
+
+	cmd := &translateCommand{
+		client:       translator.NewClient(*apiKey, *model),
+		options:      translator.Options{SourceLanguage: *from, TargetLanguage: lang, SkipSameLanguage: *skipSame},
+		lang:         lang,
+		nameTemplate: *name,
+		overwrite:    *overwrite,
+		concurrency:  *concurrency,
+		retries:      *retries,
+		progress:     newProgressReporter(os.Stderr, *quiet),
+	}
+	cmd.client.BaseURL = *baseURL
+	if *format != "" {
+		if cmd.format, err = parseDocFormat(*format); err != nil {
+			return usageError("--format: %v", err)
+		}
+	}
+
+	jobs, defaultState, err := cmd.planJobs(inputs, *output, *recursive)
+	if err != nil {
+		return usageError("%v", err)
+	}
+	if *statePath == "" {
+		*statePath = defaultState
+	}
+	if cmd.state, err = openTranslateState(*statePath); err != nil {
+		fmt.Fprintf(os.Stderr, "doubao translate: %v\n", err)
+		return 1
+	}
+	defer cmd.state.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var stats translateStats
+	for i, job := range jobs {
+		if ctx.Err() != nil {
+			break
+		}
+		cmd.progress.startFile(i+1, len(jobs), job.display)
+		if !cmd.overwrite && job.output != "" && fileExists(job.output) {
+			stats.skipped++
+			cmd.progress.finishFile("skipped, output exists: " + job.output)
+			continue
+		}
+		segments, tokens, err := cmd.translateFile(ctx, job)
+		stats.segments += segments
+		stats.tokens += tokens
+		if err != nil {
+			stats.failed++
+			cmd.progress.finishFile("failed: " + err.Error())
+			continue
+		}
+		stats.files++
+		if job.output != "" {
+			cmd.progress.finishFile("-> " + job.output)
+		} else {
+			cmd.progress.finishFile("done")
+		}
+	}
+
+	if len(jobs) > 1 || stats.failed > 0 {
+		cmd.progress.summary(fmt.Sprintf("translated %d file(s), skipped %d, failed %d; %d segment(s), %d token(s)",
+			stats.files, stats.skipped, stats.failed, stats.segments, stats.tokens))
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "doubao translate: interrupted; rerun the same command to resume (state: %s)\n", cmd.state.path)
+		return 130
+	}
+	if stats.failed > 0 {
+		return 1
+	}
+	// 全部成功后状态文件不再需要；显式指定的 --state 保留，可作为翻译缓存复用。
+	if *statePath == defaultState {
+		cmd.state.Remove()
+	}
+	return 0
+}
+
+// parseInterspersed 允许参数与 flag 混排，例如 `doubao translate README.md --to ja`。
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		if args[0] == "--" {
+			return append(positional, args[1:]...), nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func usageError(format string, args ...interface{}) int {
+	fmt.Fprintf(os.Stderr, "doubao translate: "+format+"\n", args...)
+	fmt.Fprintln(os.Stderr, "Run 'doubao translate -h' for usage.")
+	return 2
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// planJobs 展开输入并确定输出位置：单个输入默认写到标准输出（或 -o 指定的文件），
+// 多个输入或目录按命名模板写到 -o 目录（缺省为输入所在目录）。
+func (cmd *translateCommand) planJobs(inputs []string, output string, recursive bool) ([]translateJob, string, error) {
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	type source struct {
+		path string
+		root string
+	}
+	var sources []source
+	stdin := false
+	for _, input := range inputs {
+		if input == "-" {
+			stdin = true
+			continue
+		}
+		info, err := os.Stat(input)
+		if err != nil {
+			return nil, "", err
+		}
+		if !info.IsDir() {
+			sources = append(sources, source{path: input, root: filepath.Dir(input)})
+			continue
+		}
+		if !recursive {
+			return nil, "", fmt.Errorf("%s is a directory (use -r)", input)
+		}
+		err = filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != input && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if _, ok := formatFromPath(path); ok && !strings.HasPrefix(d.Name(), ".") {
+				sources = append(sources, source{path: path, root: input})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	if stdin {
+		if len(sources) > 0 {
+			return nil, "", errors.New("stdin (-) cannot be combined with file inputs")
+		}
+		job := translateJob{output: output, display: "<stdin>"}
+		return []translateJob{job}, stateFileFor(output), nil
+	}
+	if len(sources) == 0 {
+		return nil, "", errors.New("no translatable files found (supported: .txt .md .html .srt .json)")
+	}
+
+	outputIsDir := output != "" && (strings.HasSuffix(output, string(filepath.Separator)) || isDir(output))
+	if len(sources) == 1 && cmd.nameTemplate == "" && len(inputs) == 1 && !isDir(inputs[0]) && !outputIsDir {
+		job := translateJob{input: sources[0].path, output: output, display: sources[0].path}
+		return []translateJob{job}, stateFileFor(output), nil
+	}
+
+	template := cmd.nameTemplate
+	if template == "" {
+		template = defaultNameTemplate
+	}
+	jobs := make([]translateJob, 0, len(sources))
+	outputs := map[string]bool{}
+	for _, src := range sources {
+		root := src.root
+		if output != "" {
+			root = output
+		}
+		rel, err := filepath.Rel(src.root, src.path)
+		if err != nil {
+			return nil, "", err
+		}
+		target := filepath.Join(root, renderNameTemplate(template, rel, cmd.lang))
+		jobs = append(jobs, translateJob{input: src.path, output: target, display: src.path})
+		outputs[filepath.Clean(target)] = true
+	}
+
+	// 重复执行时跳过上一轮生成的译文，避免把 a.ja.md 再翻译成 a.ja.ja.md。
+	filtered := jobs[:0]
+	for _, job := range jobs {
+		if !outputs[filepath.Clean(job.input)] {
+			filtered = append(filtered, job)
+		}
+	}
+
+	stateRoot := output
+	if stateRoot == "" {
+		stateRoot = sources[0].root
+	}
+	return filtered, filepath.Join(stateRoot, ".doubao-translate-state.jsonl"), nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func stateFileFor(output string) string {
+	if output == "" {
+		return ""
+	}
+	return output + ".doubao-state.jsonl"
+}
+
+// renderNameTemplate 替换 {dir}（相对输入根目录的子目录）、{name}（不含扩展名的文件名）、
+// {ext}（含点的扩展名）与 {lang}（目标语言编码）。
+func renderNameTemplate(template, rel, lang string) string {
+	dir := filepath.Dir(rel)
+	base := filepath.Base(rel)
+	ext := filepath.Ext(base)
+	replacer := strings.NewReplacer(
+		"{dir}", dir,
+		"{name}", strings.TrimSuffix(base, ext),
+		"{ext}", ext,
+		"{lang}", lang,
+	)
+	return filepath.Clean(filepath.FromSlash(replacer.Replace(template)))
+}
+
+// translateFile 翻译一个文件并返回片段数与消耗的 token 数；文件写入是原子的，失败时不留下半成品。
+func (cmd *translateCommand) translateFile(ctx context.Context, job translateJob) (int, int, error) {
+	var data []byte
+	var err error
+	if job.input == "" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(job.input)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	format := cmd.format
+	if format == "" {
+		var ok bool
+		if format, ok = formatFromPath(job.input); !ok {
+			format = sniffFormat(data)
+		}
+	}
+	parts, err := parseDocument(format, data)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var pending []int
+	for i, part := range parts {
+		if part.Translate && hasLetter(part.Text) {
+			pending = append(pending, i)
+		}
+	}
+	cmd.progress.setTotal(len(pending))
+
+	translations, tokens, err := cmd.translateSegments(ctx, parts, pending)
+	if err != nil {
+		return len(translations), tokens, err
+	}
+	rendered := renderDocument(parts, translations)
+
+	if job.output == "" {
+		_, err = os.Stdout.Write(rendered)
+		return len(pending), tokens, err
+	}
+	return len(pending), tokens, writeFileAtomic(job.output, rendered)
+}
+
+func (cmd *translateCommand) translateSegments(ctx context.Context, parts []docPart, pending []int) (map[int]string, int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	translations := make(map[int]string, len(pending))
+	tokens := 0
+	var firstErr error
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < cmd.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				translated, used, err := cmd.translateSegment(ctx, parts[i].Text)
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				} else {
+					translations[i] = translated
+					tokens += used
+				}
+				mu.Unlock()
+				if err == nil {
+					cmd.progress.segmentDone()
+				}
+			}
+		}()
+	}
+	for _, i := range pending {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	return translations, tokens, firstErr
+}
+
+// translateSegment 保留片段首尾空白，只翻译中间的正文；命中状态文件时不再请求上游。
+func (cmd *translateCommand) translateSegment(ctx context.Context, text string) (string, int, error) {
+	core := strings.TrimSpace(text)
+	start := strings.Index(text, core)
+	leading, trailing := text[:start], text[start+len(core):]
+
+	key := cmd.cacheKey(core)
+	if cached, ok := cmd.state.Get(key); ok {
+		return leading + cached + trailing, 0, nil
+	}
+
+	for attempt := 0; ; attempt++ {
+		result, err := cmd.client.Translate(ctx, core, &cmd.options)
+		if err == nil {
+			if err := cmd.state.Put(key, result.Text); err != nil {
+				return "", 0, err
+			}
+			return leading + result.Text + trailing, result.Usage.TotalTokens, nil
+		}
+		var apiErr *translator.Error
+		if attempt >= cmd.retries || !errors.As(err, &apiErr) || !retryableStatus(apiErr.Status) {
+			return "", 0, err
+		}
+		delay := time.Duration(1<<attempt) * time.Second
+		if seconds, convErr := strconv.Atoi(apiErr.RetryAfter); convErr == nil && seconds > 0 {
+			delay = time.Duration(seconds) * time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return "", 0, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (cmd *translateCommand) cacheKey(text string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{cmd.client.Model, cmd.options.SourceLanguage, cmd.lang, text}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func retryableStatus(status int) bool {
+	return status == 429 || status >= 500
+}
+
+// hasLetter 过滤只含数字、标点或符号的片段，它们无需翻译。
+func hasLetter(text string) bool {
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// translateState 以 JSONL 追加记录已完成的片段译文，键为模型、语言与原文的哈希。
+type translateState struct {
+	path    string
+	mu      sync.Mutex
+	file    *os.File
+	entries map[string]string
+}
+
+type translateStateEntry struct {
+	Key  string `json:"key"`
+	Text string `json:"text"`
+}
+
+// openTranslateState 加载已有记录；path 为空时只在内存中缓存（标准输出且未指定 --state）。
+func openTranslateState(path string) (*translateState, error) {
+	state := &translateState{path: path, entries: map[string]string{}}
+	if path == "" {
+		return state, nil
+	}
+	if existing, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(existing)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var entry translateStateEntry
+			// 中断时最后一行可能只写了一半，忽略无法解析的行即可。
+			if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.Key != "" {
+				state.entries[entry.Key] = entry.Text
+			}
+		}
+		existing.Close()
+	}
+	return state, nil
+}
+
+func (st *translateState) Get(key string) (string, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	text, ok := st.entries[key]
+	return text, ok
+}
+
+func (st *translateState) Put(key, text string) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.entries[key] = text
+	if st.path == "" {
+		return nil
+	}
+	if st.file == nil {
+		if err := os.MkdirAll(filepath.Dir(st.path), 0o755); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(st.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		st.file = file
+	}
+	line, err := json.Marshal(translateStateEntry{Key: key, Text: text})
+	if err != nil {
+		return err
+	}
+	_, err = st.file.Write(append(line, '\n'))
+	return err
+}
+
+func (st *translateState) Close() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.file != nil {
+		st.file.Close()
+		st.file = nil
+	}
+}
+
+func (st *translateState) Remove() {
+	st.Close()
+	if st.path != "" {
+		os.Remove(st.path)
+	}
+}
+
+// progressReporter 在 stderr 上报告进度：终端中原地刷新片段计数，重定向时只输出每个文件的结果。
+type progressReporter struct {
+	out   io.Writer
+	quiet bool
+	tty   bool
+
+	mu      sync.Mutex
+	prefix  string
+	done    int
+	total   int
+	lastLen int
+}
+
+func newProgressReporter(out *os.File, quiet bool) *progressReporter {
+	tty := false
+	if info, err := out.Stat(); err == nil {
+		tty = info.Mode()&os.ModeCharDevice != 0
+	}
+	return &progressReporter{out: out, quiet: quiet, tty: tty}
+}
+
+func (p *progressReporter) startFile(index, total int, name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prefix = fmt.Sprintf("[%d/%d] %s", index, total, name)
+	p.done, p.total = 0, 0
+}
+
+func (p *progressReporter) setTotal(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total = total
+	p.redraw()
+}
+
+func (p *progressReporter) segmentDone() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done++
+	p.redraw()
+}
+
+func (p *progressReporter) redraw() {
+	if p.quiet || !p.tty {
+		return
+	}
+	line := fmt.Sprintf("%s  %d/%d segments", p.prefix, p.done, p.total)
+	padding := ""
+	if p.lastLen > len(line) {
+		padding = strings.Repeat(" ", p.lastLen-len(line))
+	}
+	fmt.Fprintf(p.out, "\r%s%s", line, padding)
+	p.lastLen = len(line)
+}
+
+func (p *progressReporter) finishFile(status string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.quiet {
+		return
+	}
+	if p.tty && p.lastLen > 0 {
+		fmt.Fprintf(p.out, "\r%s\r", strings.Repeat(" ", p.lastLen))
+	}
+	p.lastLen = 0
+	if p.total == 0 {
+		fmt.Fprintf(p.out, "%s  %s\n", p.prefix, status)
+		return
+	}
+	fmt.Fprintf(p.out, "%s  %d/%d segments  %s\n", p.prefix, p.done, p.total, status)
+}
+
+func (p *progressReporter) summary(line string) {
+	if !p.quiet {
+		fmt.Fprintln(p.out, line)
+	}
+}
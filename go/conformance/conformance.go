@@ -0,0 +1,337 @@
+// Package conformance 用同一组请求用例检查各实现（cf-workers.js、edge-function.js、Go 服务）的行为是否一致：
+// 每个用例发送到各目标地址，目标统一指向 mockupstream 提供的上游替身；
+// 响应状态、Content-Type、响应体（或 SSE 事件序列）以及上游实际收到的翻译参数被展开为 "路径 = 值" 的观测结果，
+// 以第一个目标为基准逐项比较。id、时间戳等每次都会变化的字段在比较前被替换为占位符。
+package conformance
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"doubao/mockupstream"
+)
+
+//go:embed cases.json
+var defaultCases []byte
+
+// Case 为语料中的一个请求用例。
+type Case struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+	// Method 默认为 POST。
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path"`
+	// Headers 覆盖默认请求头（Bearer 鉴权、JSON 内容类型、X-Forwarded-Proto: https），值为空表示不发送该头。
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+	// RawBody 原样作为请求体发送，用于无效 JSON 等用例，优先于 Body。
+	RawBody string `json:"raw_body,omitempty"`
+	// PadBytes 在请求体末尾追加的空白字节数，用于请求体积限制。
+	PadBytes int `json:"pad_bytes,omitempty"`
+}
+
+// Target 为待测实现的名称与根地址（如 http://127.0.0.1:8787）。
+type Target struct {
+	Name    string
+	BaseURL string
+}
+
+// Observation 为一个用例在一个目标上的展开结果；Error 非空表示请求本身失败（连接错误等）。
+type Observation struct {
+	Case   string
+	Target string
+	Fields map[string]string
+	Error  string
+}
+
+// Difference 为某个字段在基准目标与另一目标上的取值差异，缺失的字段记为 "<absent>"。
+type Difference struct {
+	Case      string
+	Field     string
+	Reference string
+	Target    string
+	Value     string
+}
+
+const absent = "<absent>"
+
+func orNone(message string) string {
+	if message == "" {
+		return "<none>"
+	}
+	return strconv.Quote(message)
+}
+
+var defaultHeaders = map[string]string{
+	"Authorization":     "Bearer conformance",
+	"Content-Type":      "application/json",
+	"X-Forwarded-Proto": "https",
+}
+
+// DefaultCases 返回内置语料。
+func DefaultCases() []Case {
+	cases, err := ParseCases(defaultCases)
+	if err != nil {
+		panic("conformance: invalid embedded cases.json: " + err.Error())
+	}
+	return cases
+}
+
+// ParseCases 解析 JSON 数组格式的语料。
+func ParseCases(data []byte) ([]Case, error) {
+	var cases []Case
+	if err := json.Unmarshal(data, &cases); err != nil {
+		return nil, err
+	}
+	for i, c := range cases {
+		if c.Name == "" || c.Path == "" {
+			return nil, fmt.Errorf("case %d: name and path are required", i)
+		}
+	}
+	return cases, nil
+}
+
+// Runner 依次把用例发送到目标，并记录 mock 上游在该用例期间收到的请求。
+// 同一个 mock 被所有目标共用，因此用例只能串行执行。
+type Runner struct {
+	Client *http.Client
+	Mock   *mockupstream.Server
+}
+
+// Run 在目标上执行全部用例。
+func (r *Runner) Run(ctx context.Context, target Target, cases []Case) []Observation {
+	observations := make([]Observation, 0, len(cases))
+	for _, c := range cases {
+		observations = append(observations, r.runCase(ctx, target, c))
+	}
+	return observations
+}
+
+func (r *Runner) runCase(ctx context.Context, target Target, c Case) Observation {
+	obs := Observation{Case: c.Name, Target: target.Name, Fields: map[string]string{}}
+
+	body := []byte(c.RawBody)
+	if c.RawBody == "" && len(c.Body) > 0 {
+		body = append([]byte(nil), c.Body...)
+	}
+	if c.PadBytes > 0 {
+		body = append(body, bytes.Repeat([]byte(" "), c.PadBytes)...)
+	}
+	method := c.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(target.BaseURL, "/")+c.Path, bytes.NewReader(body))
+	if err != nil {
+		obs.Error = err.Error()
+		return obs
+	}
+	for name, value := range defaultHeaders {
+		req.Header.Set(name, value)
+	}
+	for name, value := range c.Headers {
+		if value == "" {
+			req.Header.Del(name)
+		} else {
+			req.Header.Set(name, value)
+		}
+	}
+
+	if r.Mock != nil {
+		r.Mock.Reset()
+	}
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		obs.Error = err.Error()
+		return obs
+	}
+	defer resp.Body.Close()
+
+	obs.Fields["status"] = strconv.Itoa(resp.StatusCode)
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	obs.Fields["content_type"] = mediaType
+	content, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		obs.Fields["read_error"] = "true"
+	}
+	if mediaType == "text/event-stream" {
+		flattenEvents(obs.Fields, content)
+	} else {
+		flattenBody(obs.Fields, "body", content)
+	}
+
+	if r.Mock != nil {
+		for i, upstream := range r.Mock.Requests() {
+			prefix := fmt.Sprintf("upstream[%d].", i)
+			obs.Fields[prefix+"model"] = upstream.Model
+			obs.Fields[prefix+"stream"] = strconv.FormatBool(upstream.Stream)
+			obs.Fields[prefix+"text"] = strconv.Quote(upstream.Text)
+			obs.Fields[prefix+"source_language"] = upstream.SourceLanguage
+			obs.Fields[prefix+"target_language"] = upstream.TargetLanguage
+		}
+	}
+	return obs
+}
+
+// flattenEvents 展开 SSE 响应：忽略注释行（心跳），按事件记录 event 名称与 data（JSON 时继续展开）。
+// 同时记录拼接后的增量文本，便于区分"分块方式不同"与"内容不同"。
+func flattenEvents(fields map[string]string, content []byte) {
+	var text strings.Builder
+	index := 0
+	for _, raw := range strings.Split(strings.ReplaceAll(string(content), "\r", ""), "\n\n") {
+		name, data, hasData := "", []string{}, false
+		scanner := bufio.NewScanner(strings.NewReader(raw))
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				name = strings.TrimSpace(line[6:])
+			case strings.HasPrefix(line, "data:"):
+				data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+				hasData = true
+			}
+		}
+		if !hasData {
+			continue
+		}
+		prefix := fmt.Sprintf("events[%d]", index)
+		index++
+		if name != "" {
+			fields[prefix+".event"] = name
+		}
+		payload := strings.Join(data, "\n")
+		flattenBody(fields, prefix+".data", []byte(payload))
+
+		var chunk struct {
+			Delta   string `json:"delta"`
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if json.Unmarshal([]byte(payload), &chunk) == nil {
+			text.WriteString(chunk.Delta)
+			for _, choice := range chunk.Choices {
+				text.WriteString(choice.Delta.Content)
+			}
+		}
+	}
+	fields["events.count"] = strconv.Itoa(index)
+	fields["events.text"] = strconv.Quote(text.String())
+}
+
+// volatileKeys 为每次请求都会变化的字段，比较前替换为占位符。
+var volatileKeys = map[string]bool{"id": true, "item_id": true, "created": true, "created_at": true}
+
+func flattenBody(fields map[string]string, prefix string, content []byte) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		fields[prefix] = strconv.Quote(string(content))
+		return
+	}
+	flattenValue(fields, prefix, value)
+}
+
+func flattenValue(fields map[string]string, path string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			fields[path] = "{}"
+		}
+		for key, child := range v {
+			if volatileKeys[key] {
+				if child == nil || child == "" {
+					fields[path+"."+key] = "<empty>"
+				} else {
+					fields[path+"."+key] = "<volatile>"
+				}
+				continue
+			}
+			flattenValue(fields, path+"."+key, child)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			fields[path] = "[]"
+		}
+		for i, child := range v {
+			flattenValue(fields, fmt.Sprintf("%s[%d]", path, i), child)
+		}
+	case string:
+		fields[path] = strconv.Quote(v)
+	case nil:
+		fields[path] = "null"
+	default:
+		fields[path] = fmt.Sprint(v)
+	}
+}
+
+var indexPattern = regexp.MustCompile(`\[\d+\]`)
+
+// Matcher 判断字段是否被忽略：模式与字段完全相同，或是字段的前缀（以 "." 或 "[" 分隔）；
+// 模式中的 "[*]" 匹配任意下标，例如 "events[*].data.detected_source_language"。
+type Matcher []string
+
+func (m Matcher) Match(field string) bool {
+	generic := indexPattern.ReplaceAllString(field, "[*]")
+	for _, pattern := range m {
+		for _, candidate := range []string{field, generic} {
+			if candidate == pattern || strings.HasPrefix(candidate, pattern+".") || strings.HasPrefix(candidate, pattern+"[") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Compare 以 reference 为基准比较同一用例的观测结果，按字段名排序返回差异。
+func Compare(reference, other Observation, ignore Matcher) []Difference {
+	var diffs []Difference
+	if reference.Error != "" || other.Error != "" {
+		if reference.Error != other.Error {
+			diffs = append(diffs, Difference{Case: reference.Case, Field: "error", Reference: orNone(reference.Error), Target: other.Target, Value: orNone(other.Error)})
+		}
+		return diffs
+	}
+	keys := map[string]bool{}
+	for key := range reference.Fields {
+		keys[key] = true
+	}
+	for key := range other.Fields {
+		keys[key] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		if !ignore.Match(key) {
+			sorted = append(sorted, key)
+		}
+	}
+	sort.Strings(sorted)
+	for _, key := range sorted {
+		want, ok := reference.Fields[key]
+		if !ok {
+			want = absent
+		}
+		got, ok := other.Fields[key]
+		if !ok {
+			got = absent
+		}
+		if want != got {
+			diffs = append(diffs, Difference{Case: reference.Case, Field: key, Reference: want, Target: other.Target, Value: got})
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// 命令行翻译支持的文档格式：按格式切分出需要翻译的文本片段，
+// 标记、时间轴、代码块、JSON 键等其余内容原样保留。
+
+type docFormat string
+
+const (
+	formatText     docFormat = "txt"
+	formatMarkdown docFormat = "md"
+	formatHTML     docFormat = "html"
+	formatSRT      docFormat = "srt"
+	formatJSON     docFormat = "json"
+)
+
+var docFormatExtensions = map[string]docFormat{
+	".txt":      formatText,
+	".text":     formatText,
+	".md":       formatMarkdown,
+	".markdown": formatMarkdown,
+	".html":     formatHTML,
+	".htm":      formatHTML,
+	".srt":      formatSRT,
+	".json":     formatJSON,
+}
+
+func parseDocFormat(value string) (docFormat, error) {
+	switch strings.ToLower(strings.TrimPrefix(value, ".")) {
+	case "txt", "text":
+		return formatText, nil
+	case "md", "markdown":
+		return formatMarkdown, nil
+	case "html", "htm":
+		return formatHTML, nil
+	case "srt":
+		return formatSRT, nil
+	case "json":
+		return formatJSON, nil
+	}
+	return "", fmt.Errorf("unsupported format %q (want txt, md, html, srt or json)", value)
+}
+
+func formatFromPath(path string) (docFormat, bool) {
+	format, ok := docFormatExtensions[strings.ToLower(filepath.Ext(path))]
+	return format, ok
+}
+
+var (
+	reSRTTiming       = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}[,.]\d{3}\s+-->\s+\d{2}:\d{2}:\d{2}[,.]\d{3}`)
+	reMarkdownSniff   = regexp.MustCompile(`(?m)^(#{1,6}\s|[-*+]\s|\d+\.\s|>\s|` + "```" + `)`)
+	reHTMLSniff       = regexp.MustCompile(`(?i)^\s*(<!doctype html|<html|<body|<div|<p[\s>]|<!--)`)
+	reSRTSniffIndex   = regexp.MustCompile(`^\d+\r?\n`)
+	utf8BOM           = []byte{0xEF, 0xBB, 0xBF}
+	markdownSkipLines = regexp.MustCompile(`^\s*(\[[^\]]+\]:\s|<[^>]+>\s*$|([-*_]\s*){3,}$)`)
+)
+
+// sniffFormat 用于标准输入等没有扩展名的场景，按内容特征猜测格式，无法判断时视为纯文本。
+func sniffFormat(data []byte) docFormat {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, utf8BOM))
+	switch {
+	case len(trimmed) == 0:
+		return formatText
+	case (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed):
+		return formatJSON
+	case reHTMLSniff.Match(trimmed):
+		return formatHTML
+	case reSRTSniffIndex.Match(trimmed):
+		lines := strings.SplitN(string(trimmed), "\n", 3)
+		if len(lines) > 1 && reSRTTiming.MatchString(strings.TrimSpace(lines[1])) {
+			return formatSRT
+		}
+	}
+	if reMarkdownSniff.Match(trimmed) {
+		return formatMarkdown
+	}
+	return formatText
+}
+
+// docPart 是文档的一个片段：Translate 为 false 时原样输出；Quote 表示渲染时按 JSON 字符串转义。
+type docPart struct {
+	Text      string
+	Translate bool
+	Quote     bool
+}
+
+type docBuilder struct {
+	parts []docPart
+}
+
+func (b *docBuilder) literal(text string) {
+	if text == "" {
+		return
+	}
+	if n := len(b.parts); n > 0 && !b.parts[n-1].Translate && !b.parts[n-1].Quote {
+		b.parts[n-1].Text += text
+		return
+	}
+	b.parts = append(b.parts, docPart{Text: text})
+}
+
+func (b *docBuilder) translatable(text string) {
+	if strings.TrimSpace(text) == "" {
+		b.literal(text)
+		return
+	}
+	b.parts = append(b.parts, docPart{Text: text, Translate: true})
+}
+
+func parseDocument(format docFormat, data []byte) ([]docPart, error) {
+	var b docBuilder
+	if bytes.HasPrefix(data, utf8BOM) {
+		b.literal(string(utf8BOM))
+		data = data[len(utf8BOM):]
+	}
+	text := string(data)
+	switch format {
+	case formatText:
+		parseTextDocument(&b, text)
+	case formatMarkdown:
+		parseMarkdownDocument(&b, text)
+	case formatHTML:
+		parseHTMLDocument(&b, text)
+	case formatSRT:
+		parseSRTDocument(&b, text)
+	case formatJSON:
+		if err := parseJSONDocument(&b, data); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	return b.parts, nil
+}
+
+// renderDocument 按顺序拼接片段；translations 中缺少的片段保留原文。
+func renderDocument(parts []docPart, translations map[int]string) []byte {
+	var out bytes.Buffer
+	for i, part := range parts {
+		text := part.Text
+		if translated, ok := translations[i]; ok {
+			text = translated
+		}
+		if part.Quote {
+			text = quoteJSON(text)
+		}
+		out.WriteString(text)
+	}
+	return out.Bytes()
+}
+
+// splitLines 按行切分并保留换行符，最后一行可能没有换行符。
+func splitLines(text string) []string {
+	var lines []string
+	for text != "" {
+		i := strings.IndexByte(text, '\n')
+		if i < 0 {
+			lines = append(lines, text)
+			break
+		}
+		lines = append(lines, text[:i+1])
+		text = text[i+1:]
+	}
+	return lines
+}
+
+func isBlankLine(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// addParagraph 将若干连续行作为一个片段翻译，末尾换行符单独保留以免被模型吞掉。
+func addParagraph(b *docBuilder, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	joined := strings.Join(lines, "")
+	body := strings.TrimRight(joined, "\r\n")
+	b.translatable(body)
+	b.literal(joined[len(body):])
+}
+
+// 纯文本：以空行分隔段落，逐段翻译。
+func parseTextDocument(b *docBuilder, text string) {
+	var paragraph []string
+	for _, line := range splitLines(text) {
+		if isBlankLine(line) {
+			addParagraph(b, paragraph)
+			paragraph = nil
+			b.literal(line)
+			continue
+		}
+		paragraph = append(paragraph, line)
+	}
+	addParagraph(b, paragraph)
+}
+
+var (
+	reMarkdownFence  = regexp.MustCompile("^ {0,3}(```+|~~~+)")
+	reMarkdownPrefix = regexp.MustCompile(`^(\s*(?:>\s?)*\s*(?:#{1,6}\s+|[-*+]\s+(?:\[[ xX]\]\s+)?|\d+[.)]\s+)?)`)
+	reMarkdownTable  = regexp.MustCompile(`^\s*\|`)
+	reMarkdownTSep   = regexp.MustCompile(`^[\s|:\-]+$`)
+)
+
+// Markdown：跳过 front matter 与围栏代码块；标题、列表、引用保留前缀只翻译正文；
+// 表格逐个单元格翻译；其余连续行作为段落翻译。
+func parseMarkdownDocument(b *docBuilder, text string) {
+	lines := splitLines(text)
+	var paragraph []string
+	flush := func() {
+		addParagraph(b, paragraph)
+		paragraph = nil
+	}
+
+	i := 0
+	if len(lines) > 0 && strings.TrimSpace(lines[0]) == "---" {
+		for j := 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) == "---" {
+				b.literal(strings.Join(lines[:j+1], ""))
+				i = j + 1
+				break
+			}
+		}
+	}
+
+	fence := ""
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if fence != "" {
+			b.literal(line)
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if m := reMarkdownFence.FindStringSubmatch(line); m != nil {
+			flush()
+			fence = m[1][:3]
+			b.literal(line)
+			continue
+		}
+		switch {
+		case isBlankLine(line), markdownSkipLines.MatchString(line):
+			flush()
+			b.literal(line)
+		case reMarkdownTable.MatchString(line):
+			flush()
+			addMarkdownTableRow(b, line)
+		default:
+			// 带引用、标题或列表标记的行单独翻译，标记本身原样保留。
+			if prefix := reMarkdownPrefix.FindString(line); strings.TrimSpace(prefix) != "" {
+				flush()
+				b.literal(prefix)
+				addParagraph(b, []string{line[len(prefix):]})
+				continue
+			}
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+}
+
+func addMarkdownTableRow(b *docBuilder, line string) {
+	body := strings.TrimRight(line, "\r\n")
+	if reMarkdownTSep.MatchString(body) {
+		b.literal(line)
+		return
+	}
+	cells := strings.Split(body, "|")
+	for i, cell := range cells {
+		if i > 0 {
+			b.literal("|")
+		}
+		b.translatable(cell)
+	}
+	b.literal(line[len(body):])
+}
+
+// htmlSkipElements 中的元素内容不翻译。
+var htmlSkipElements = map[string]bool{"script": true, "style": true, "pre": true, "code": true, "textarea": true}
+
+// HTML：翻译标签之间的文本节点，跳过注释与 script/style/pre/code/textarea 的内容；属性值不翻译。
+func parseHTMLDocument(b *docBuilder, text string) {
+	skipDepth := map[string]int{}
+	skipping := func() bool {
+		for _, depth := range skipDepth {
+			if depth > 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	for text != "" {
+		lt := strings.IndexByte(text, '<')
+		if lt < 0 {
+			lt = len(text)
+		}
+		if lt > 0 {
+			if skipping() {
+				b.literal(text[:lt])
+			} else {
+				b.translatable(text[:lt])
+			}
+			text = text[lt:]
+			continue
+		}
+
+		if strings.HasPrefix(text, "<!--") {
+			end := strings.Index(text, "-->")
+			if end < 0 {
+				b.literal(text)
+				return
+			}
+			b.literal(text[:end+3])
+			text = text[end+3:]
+			continue
+		}
+
+		end := htmlTagEnd(text)
+		if end < 0 {
+			b.literal(text)
+			return
+		}
+		tag := text[:end+1]
+		b.literal(tag)
+		text = text[end+1:]
+
+		name, closing := htmlTagName(tag)
+		if htmlSkipElements[name] && !strings.HasSuffix(tag, "/>") {
+			if closing {
+				if skipDepth[name] > 0 {
+					skipDepth[name]--
+				}
+			} else {
+				skipDepth[name]++
+			}
+		}
+	}
+}
+
+// htmlTagEnd 返回标签结束 '>' 的位置，忽略引号内的 '>'。
+func htmlTagEnd(text string) int {
+	var quote byte
+	for i := 1; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+func htmlTagName(tag string) (string, bool) {
+	inner := strings.TrimPrefix(tag, "<")
+	closing := strings.HasPrefix(inner, "/")
+	inner = strings.TrimPrefix(inner, "/")
+	end := strings.IndexAny(inner, " \t\r\n/>")
+	if end < 0 {
+		end = len(inner)
+	}
+	return strings.ToLower(inner[:end]), closing
+}
+
+// SRT：保留序号与时间轴，每条字幕的文本行作为一个片段翻译；无法识别的块原样保留。
+func parseSRTDocument(b *docBuilder, text string) {
+	var block []string
+	flush := func() {
+		defer func() { block = nil }()
+		if len(block) < 3 || !reSRTTiming.MatchString(strings.TrimSpace(block[1])) {
+			b.literal(strings.Join(block, ""))
+			return
+		}
+		b.literal(block[0] + block[1])
+		addParagraph(b, block[2:])
+	}
+	for _, line := range splitLines(text) {
+		if isBlankLine(line) {
+			flush()
+			b.literal(line)
+			continue
+		}
+		block = append(block, line)
+	}
+	flush()
+}
+
+// JSON：翻译所有字符串值（键保持不变），输出按两个空格缩进并保留原有键顺序。
+func parseJSONDocument(b *docBuilder, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := writeJSONValue(b, dec, 0); err != nil {
+		return fmt.Errorf("invalid JSON document: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("invalid JSON document: unexpected data after top-level value")
+	}
+	b.literal("\n")
+	return nil
+}
+
+func writeJSONValue(b *docBuilder, dec *json.Decoder, depth int) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch value := token.(type) {
+	case json.Delim:
+		closing := "}"
+		if value == '[' {
+			closing = "]"
+		}
+		b.literal(string(value))
+		count := 0
+		for dec.More() {
+			if count > 0 {
+				b.literal(",")
+			}
+			b.literal("\n" + strings.Repeat("  ", depth+1))
+			if value == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				b.literal(quoteJSON(fmt.Sprint(key)) + ": ")
+			}
+			if err := writeJSONValue(b, dec, depth+1); err != nil {
+				return err
+			}
+			count++
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		if count > 0 {
+			b.literal("\n" + strings.Repeat("  ", depth))
+		}
+		b.literal(closing)
+	case string:
+		b.parts = append(b.parts, docPart{Text: value, Translate: strings.TrimSpace(value) != "", Quote: true})
+	case json.Number:
+		b.literal(value.String())
+	case bool:
+		b.literal(fmt.Sprint(value))
+	case nil:
+		b.literal("null")
+	}
+	return nil
+}
+
+func quoteJSON(text string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(text); err != nil {
+		return `""`
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,397 @@
+// Package mockupstream 是方舟 /api/v3/responses 接口的离线替身，供测试与本地联调使用：
+// 支持非流式与 SSE 流式响应、可配置延迟、错误注入（429、500、畸形 JSON、截断的流），
+// 译文是确定性的（"[目标语言] 原文"），便于断言。
+package mockupstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Path 为模拟接口的路径，与方舟一致。
+const Path = "/api/v3/responses"
+
+// Fault 为注入的错误类型。
+type Fault string
+
+const (
+	FaultNone      Fault = ""
+	FaultRateLimit Fault = "429"
+	FaultServer    Fault = "500"
+	FaultMalformed Fault = "malformed"
+	FaultTruncated Fault = "truncated"
+)
+
+// ParseFault 解析命令行或模型名中的错误类型。
+func ParseFault(value string) (Fault, error) {
+	switch fault := Fault(strings.ToLower(strings.TrimSpace(value))); fault {
+	case FaultNone, FaultRateLimit, FaultServer, FaultMalformed, FaultTruncated:
+		return fault, nil
+	}
+	return FaultNone, fmt.Errorf("unknown fault %q (want 429, 500, malformed or truncated)", value)
+}
+
+type Options struct {
+	// Latency 为返回响应头之前的等待时间。
+	Latency time.Duration
+	// ChunkDelay 为流式响应中相邻事件之间的间隔。
+	ChunkDelay time.Duration
+	// ChunkRunes 为每个 delta 包含的字符数，默认 8。
+	ChunkRunes int
+	// Fault 按 FaultRate 的概率注入到每个请求（FaultRate 为 0 时视为 1）。
+	// 另外，模型名为 "mock-429"、"mock-500"、"mock-malformed"、"mock-truncated" 的请求总是注入对应错误。
+	Fault     Fault
+	FaultRate float64
+	// RetryAfter 为 429 响应携带的 Retry-After 秒数，默认 1。
+	RetryAfter int
+	// AllowAnonymous 为 true 时不校验 Authorization 头。
+	AllowAnonymous bool
+}
+
+// Request 记录模拟服务收到的一次请求，便于测试断言转换后的上游 payload。
+type Request struct {
+	Authorization  string
+	Model          string
+	Text           string
+	SourceLanguage string
+	TargetLanguage string
+	Stream         bool
+	Fault          Fault
+	Body           []byte
+}
+
+// Server 实现 http.Handler，可并发使用。
+type Server struct {
+	opts Options
+
+	mu       sync.Mutex
+	random   *rand.Rand
+	requests []Request
+	ids      int
+}
+
+func New(opts Options) *Server {
+	if opts.ChunkRunes <= 0 {
+		opts.ChunkRunes = 8
+	}
+	if opts.RetryAfter <= 0 {
+		opts.RetryAfter = 1
+	}
+	if opts.Fault != FaultNone && opts.FaultRate <= 0 {
+		opts.FaultRate = 1
+	}
+	return &Server{opts: opts, random: rand.New(rand.NewSource(1))}
+}
+
+// NewTestServer 启动一个监听本地随机端口的模拟服务；上游地址为 ts.URL + Path，用完调用 ts.Close()。
+func NewTestServer(opts Options) (*httptest.Server, *Server) {
+	mock := New(opts)
+	return httptest.NewServer(mock), mock
+}
+
+// Translation 返回模拟服务对给定文本与目标语言的译文。
+func Translation(text, targetLanguage string) string {
+	return "[" + targetLanguage + "] " + text
+}
+
+// Requests 返回迄今收到的请求副本。
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Reset 清空请求记录。
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+type responsesRequest struct {
+	Model  string `json:"model"`
+	Stream bool   `json:"stream"`
+	Input  []struct {
+		Role    string `json:"role"`
+		Content []struct {
+			Type               string `json:"type"`
+			Text               string `json:"text"`
+			TranslationOptions struct {
+				SourceLanguage string `json:"source_language"`
+				TargetLanguage string `json:"target_language"`
+			} `json:"translation_options"`
+		} `json:"content"`
+	} `json:"input"`
+}
+
+// arkError 与方舟的错误体一致：{"error":{"code","message","param","type"}}。
+type arkError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Param   string `json:"param"`
+	Type    string `json:"type"`
+}
+
+type arkUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type arkContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type arkOutput struct {
+	ID      string       `json:"id"`
+	Type    string       `json:"type"`
+	Role    string       `json:"role"`
+	Status  string       `json:"status"`
+	Content []arkContent `json:"content"`
+}
+
+type arkResponse struct {
+	ID        string      `json:"id"`
+	Object    string      `json:"object"`
+	CreatedAt int64       `json:"created_at"`
+	Model     string      `json:"model"`
+	Status    string      `json:"status"`
+	Output    []arkOutput `json:"output"`
+	Usage     *arkUsage   `json:"usage,omitempty"`
+}
+
+func writeArkError(w http.ResponseWriter, status int, err arkError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]arkError{"error": err})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != Path {
+		writeArkError(w, http.StatusNotFound, arkError{Code: "NotFound", Message: "The requested path does not exist", Type: "NotFound"})
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeArkError(w, http.StatusMethodNotAllowed, arkError{Code: "MethodNotAllowed", Message: "Only POST is supported", Type: "BadRequest"})
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+
+	var req responsesRequest
+	parseErr := json.Unmarshal(body, &req)
+	record := Request{
+		Authorization: r.Header.Get("Authorization"),
+		Model:         req.Model,
+		Stream:        req.Stream,
+		Body:          body,
+	}
+	if len(req.Input) > 0 && len(req.Input[0].Content) > 0 {
+		content := req.Input[0].Content[0]
+		record.Text = content.Text
+		record.SourceLanguage = content.TranslationOptions.SourceLanguage
+		record.TargetLanguage = content.TranslationOptions.TargetLanguage
+	}
+	record.Fault = s.pickFault(req.Model)
+	id := s.record(record)
+
+	if s.opts.Latency > 0 {
+		select {
+		case <-time.After(s.opts.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch {
+	case !s.opts.AllowAnonymous && !strings.HasPrefix(record.Authorization, "Bearer "):
+		writeArkError(w, http.StatusUnauthorized, arkError{Code: "AuthenticationError", Message: "The API key in the request is missing or invalid", Type: "Unauthorized"})
+		return
+	case parseErr != nil:
+		writeArkError(w, http.StatusBadRequest, arkError{Code: "InvalidParameter", Message: "The request body is not valid JSON", Type: "BadRequest"})
+		return
+	case req.Model == "":
+		writeArkError(w, http.StatusBadRequest, arkError{Code: "MissingParameter", Message: "The request failed because it is missing one or multiple required parameters", Param: "model", Type: "BadRequest"})
+		return
+	case record.Text == "":
+		writeArkError(w, http.StatusBadRequest, arkError{Code: "MissingParameter", Message: "The request failed because it is missing one or multiple required parameters", Param: "input", Type: "BadRequest"})
+		return
+	}
+
+	switch record.Fault {
+	case FaultRateLimit:
+		w.Header().Set("Retry-After", strconv.Itoa(s.opts.RetryAfter))
+		writeArkError(w, http.StatusTooManyRequests, arkError{Code: "RateLimitExceeded.EndpointRPMExceeded", Message: "The request has exceeded the RPM limit of the endpoint", Type: "TooManyRequests"})
+		return
+	case FaultServer:
+		writeArkError(w, http.StatusInternalServerError, arkError{Code: "InternalServiceError", Message: "The service encountered an unexpected internal error", Type: "InternalServerError"})
+		return
+	}
+
+	translation := Translation(record.Text, record.TargetLanguage)
+	usage := &arkUsage{InputTokens: utf8.RuneCountInString(record.Text), OutputTokens: utf8.RuneCountInString(translation)}
+	usage.TotalTokens = usage.InputTokens + usage.OutputTokens
+	response := arkResponse{
+		ID:        fmt.Sprintf("resp_mock_%d", id),
+		Object:    "response",
+		CreatedAt: time.Now().Unix(),
+		Model:     req.Model,
+		Status:    "completed",
+		Output: []arkOutput{{
+			ID:      fmt.Sprintf("msg_mock_%d", id),
+			Type:    "message",
+			Role:    "assistant",
+			Status:  "completed",
+			Content: []arkContent{{Type: "output_text", Text: translation}},
+		}},
+		Usage: usage,
+	}
+
+	if req.Stream {
+		s.writeStream(w, r, response, record.Fault)
+		return
+	}
+
+	data, _ := json.Marshal(response)
+	w.Header().Set("Content-Type", "application/json")
+	switch record.Fault {
+	case FaultMalformed:
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data[:len(data)/2])
+	case FaultTruncated:
+		// 声明完整长度但只写出一半，net/http 会在处理函数返回后断开连接，客户端读到 unexpected EOF。
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data[:len(data)/2])
+	default:
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data)
+	}
+}
+
+func (s *Server) pickFault(model string) Fault {
+	if strings.HasPrefix(model, "mock-") {
+		if fault, err := ParseFault(strings.TrimPrefix(model, "mock-")); err == nil && fault != FaultNone {
+			return fault
+		}
+	}
+	if s.opts.Fault == FaultNone {
+		return FaultNone
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.random.Float64() < s.opts.FaultRate {
+		return s.opts.Fault
+	}
+	return FaultNone
+}
+
+func (s *Server) record(req Request) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids++
+	s.requests = append(s.requests, req)
+	return s.ids
+}
+
+// writeStream 依次发送 response.created、若干 response.output_text.delta、response.output_text.done
+// 与 response.completed；malformed 会在中途插入无法解析的 data 行，truncated 在一半 delta 后直接断开连接。
+func (s *Server) writeStream(w http.ResponseWriter, r *http.Request, response arkResponse, fault Fault) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	sequence := 0
+	send := func(name string, payload map[string]interface{}) bool {
+		if sequence > 0 && s.opts.ChunkDelay > 0 {
+			select {
+			case <-time.After(s.opts.ChunkDelay):
+			case <-r.Context().Done():
+				return false
+			}
+		}
+		payload["type"] = name
+		payload["sequence_number"] = sequence
+		sequence++
+		data, _ := json.Marshal(payload)
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	text := response.Output[0].Content[0].Text
+	item := response.Output[0]
+	created := response
+	created.Status = "in_progress"
+	created.Output = []arkOutput{}
+	created.Usage = nil
+	if !send("response.created", map[string]interface{}{"response": created}) {
+		return
+	}
+
+	chunks := splitRunes(text, s.opts.ChunkRunes)
+	for i, chunk := range chunks {
+		if fault == FaultTruncated && i >= (len(chunks)+1)/2 {
+			// 中止处理函数会让 net/http 直接关闭连接，不发送结束的空 chunk。
+			panic(http.ErrAbortHandler)
+		}
+		if fault == FaultMalformed && i == len(chunks)/2 {
+			if _, err := fmt.Fprintf(w, "event: response.output_text.delta\ndata: {\"delta\": \"unterminated\n\n"); err != nil {
+				return
+			}
+		}
+		if !send("response.output_text.delta", map[string]interface{}{
+			"item_id":       item.ID,
+			"output_index":  0,
+			"content_index": 0,
+			"delta":         chunk,
+		}) {
+			return
+		}
+	}
+	if fault == FaultTruncated {
+		panic(http.ErrAbortHandler)
+	}
+
+	if !send("response.output_text.done", map[string]interface{}{
+		"item_id":       item.ID,
+		"output_index":  0,
+		"content_index": 0,
+		"text":          text,
+	}) {
+		return
+	}
+	send("response.completed", map[string]interface{}{"response": response})
+}
+
+func splitRunes(text string, size int) []string {
+	var chunks []string
+	runes := []rune(text)
+	for len(runes) > 0 {
+		n := size
+		if n > len(runes) {
+			n = len(runes)
+		}
+		chunks = append(chunks, string(runes[:n]))
+		runes = runes[n:]
+	}
+	return chunks
+}
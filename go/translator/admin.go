@@ -0,0 +1,417 @@
+package translator
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 管理接口：/admin/ 下的路径使用独立的 CONFIG.AdminAPIKey 鉴权（Authorization: Bearer <key>），
+// 与转发给上游的 API Key 无关。未配置管理密钥时整个 /admin/ 返回 404。
+// 可管理的内容：上游地址与启停、虚拟密钥（virtualkey.go）、术语表（glossary.go）、模型别名（alias.go）、
+// 限流（ratelimit.go）与译文缓存（cache.go）。运行时修改先写入 CONFIG.AdminStateFile 再生效，重启后恢复；
+// 每次修改都写入审计日志（CONFIG.AdminAuditFile 与进程日志）。
+
+func (s *Handler) serveAdmin(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if CONFIG.AdminAPIKey == "" {
+		writeAPIError(ctx, w, newAPIError("notFound"))
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(CONFIG.AdminAPIKey)) != 1 {
+		writeAPIError(ctx, w, newAPIError("adminAuth"))
+		return
+	}
+	spanFromContext(ctx).setAttr("enduser.id", "admin")
+	// 修改类请求串行处理，保证“读取当前状态 → 保存 → 换入”之间不会穿插其他修改。
+	if r.Method != http.MethodGet {
+		s.adminStateMu.Lock()
+		defer s.adminStateMu.Unlock()
+	}
+
+	switch {
+	case r.URL.Path == "/admin/usage" && r.Method == http.MethodGet && s.ledger != nil:
+		s.handleAdminUsage(ctx, w, r)
+	case r.URL.Path == "/admin/requests" && r.Method == http.MethodGet:
+		requests := s.inflight.snapshot()
+		writeJSON(w, http.StatusOK, listResponse[inflightRequest]{Object: "list", Data: requests})
+	case r.URL.Path == "/admin/metrics" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, metricsResponse{
+			StartedAt:     s.metrics.started.UTC(),
+			UptimeSeconds: int64(time.Since(s.metrics.started).Seconds()),
+			InFlight:      len(s.inflight.snapshot()),
+			Upstream:      s.upstream.settings(),
+			Routes:        s.metrics.snapshot(),
+		})
+	case r.URL.Path == "/admin/errors" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, listResponse[recentError]{Object: "list", Data: s.recentErrors.snapshot(r.URL.Query().Get("identity"))})
+	case r.URL.Path == "/admin/upstream" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.upstream.settings())
+	case r.URL.Path == "/admin/upstream" && r.Method == http.MethodPatch:
+		s.handleAdminUpstream(ctx, w, r)
+	case r.URL.Path == "/admin/rate-limits":
+		s.handleAdminRateLimits(ctx, w, r)
+	case adminResource(r.URL.Path, "/admin/keys") != nil:
+		s.handleAdminKeys(ctx, w, r, *adminResource(r.URL.Path, "/admin/keys"))
+	case adminResource(r.URL.Path, "/admin/glossary") != nil:
+		s.handleAdminGlossary(ctx, w, r, *adminResource(r.URL.Path, "/admin/glossary"))
+	case adminResource(r.URL.Path, "/admin/aliases") != nil:
+		s.handleAdminAliases(ctx, w, r, *adminResource(r.URL.Path, "/admin/aliases"))
+	case adminResource(r.URL.Path, "/admin/cache") != nil:
+		s.handleAdminCache(ctx, w, r, *adminResource(r.URL.Path, "/admin/cache"))
+	default:
+		writeAPIError(ctx, w, newAPIError("notFound"))
+	}
+}
+
+// adminResource 匹配集合路径 prefix 或其下的单个条目 prefix/{id}，返回 id（集合本身为空字符串）；不匹配时返回 nil。
+func adminResource(urlPath, prefix string) *string {
+	if urlPath == prefix {
+		id := ""
+		return &id
+	}
+	id, ok := strings.CutPrefix(urlPath, prefix+"/")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		return nil
+	}
+	return &id
+}
+
+// adminName 为虚拟密钥名称的格式，名称会出现在身份 "vk:<name>" 中。
+var adminName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._\-]{0,63}$`)
+
+// upstreamSettings 为可在运行时调整的上游设置：BaseURL 为空时使用 CONFIG.DoubaoBaseURL（或 Client 指定的地址）。
+type upstreamSettings struct {
+	BaseURL string `json:"base_url,omitempty"`
+	Enabled bool   `json:"enabled"`
+}
+
+type upstreamControl struct {
+	mu       sync.RWMutex
+	current  upstreamSettings
+	fallback string
+}
+
+func newUpstreamControl(fallback string) *upstreamControl {
+	return &upstreamControl{current: upstreamSettings{Enabled: true}, fallback: fallback}
+}
+
+func (c *upstreamControl) settings() upstreamSettings {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	settings := c.current
+	if settings.BaseURL == "" {
+		settings.BaseURL = c.fallback
+	}
+	return settings
+}
+
+// target 返回本次请求使用的上游地址；override 为 Handler 自身的地址（Client 可单独指定）。
+func (c *upstreamControl) target(override string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.current.BaseURL != "" {
+		return c.current.BaseURL, c.current.Enabled
+	}
+	return override, c.current.Enabled
+}
+
+type upstreamPatch struct {
+	BaseURL *string `json:"base_url"`
+	Enabled *bool   `json:"enabled"`
+}
+
+// handleAdminUpstream 启用/停用上游，或切换上游地址；base_url 为空字符串时恢复为 DOUBAO_BASE_URL。
+// 停用期间翻译请求直接返回 503 upstream_disabled。
+func (s *Handler) handleAdminUpstream(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	var patch upstreamPatch
+	if err := readAdminJSON(r, &patch); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+	if patch.BaseURL != nil && *patch.BaseURL != "" {
+		parsed, err := url.Parse(*patch.BaseURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			writeAPIError(ctx, w, newAPIError("invalidValue", "base_url", *patch.BaseURL).withParam("base_url"))
+			return
+		}
+	}
+
+	state := s.adminSnapshot()
+	before := state.Upstream
+	if patch.BaseURL != nil {
+		state.Upstream.BaseURL = *patch.BaseURL
+	}
+	if patch.Enabled != nil {
+		state.Upstream.Enabled = *patch.Enabled
+	}
+	if !s.commitAdminState(ctx, w, state) {
+		return
+	}
+	s.upstream.mu.Lock()
+	s.upstream.current = state.Upstream
+	s.upstream.mu.Unlock()
+	s.auditAdmin(r, "upstream.update", before, state.Upstream)
+	writeJSON(w, http.StatusOK, s.upstream.settings())
+}
+
+// readAdminJSON 解析管理接口的请求体，大小限制与翻译接口相同。
+func readAdminJSON(r *http.Request, v interface{}) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, CONFIG.MaxRequestSize+1))
+	if err != nil {
+		return newTransportError(err)
+	}
+	if int64(len(body)) > CONFIG.MaxRequestSize {
+		return newAPIError("tooLarge")
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return newAPIError("invalidJson")
+	}
+	return nil
+}
+
+// adminState 为持久化到 CONFIG.AdminStateFile 的运行时设置。虚拟密钥中包含上游 API Key，文件权限为 0600。
+type adminState struct {
+	Upstream     upstreamSettings  `json:"upstream"`
+	VirtualKeys  []virtualKey      `json:"virtual_keys,omitempty"`
+	Glossary     []glossaryEntry   `json:"glossary,omitempty"`
+	ModelAliases map[string]string `json:"model_aliases,omitempty"`
+	RateLimits   rateLimitSettings `json:"rate_limits"`
+	Cache        *cacheSettings    `json:"cache,omitempty"`
+}
+
+// LoadAdminState 从 path 恢复之前通过管理接口做出的修改，之后的修改也保存到该文件；文件不存在时从默认设置开始。
+func (s *Handler) LoadAdminState(path string) error {
+	s.adminStateFile = path
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	state := adminState{Upstream: upstreamSettings{Enabled: true}}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	s.upstream.mu.Lock()
+	s.upstream.current = state.Upstream
+	s.upstream.mu.Unlock()
+	s.virtualKeys.replace(state.VirtualKeys)
+	s.glossary.replace(state.Glossary)
+	s.aliases.replace(state.ModelAliases)
+	s.rateLimits.replace(state.RateLimits)
+	if state.Cache != nil {
+		s.cache.configure(*state.Cache)
+	}
+	return nil
+}
+
+// adminSnapshot 返回当前运行时设置的副本，修改类管理接口在副本上修改后经 commitAdminState 保存，再换入内存。
+func (s *Handler) adminSnapshot() adminState {
+	s.upstream.mu.RLock()
+	state := adminState{Upstream: s.upstream.current}
+	s.upstream.mu.RUnlock()
+	state.VirtualKeys = s.virtualKeys.snapshot()
+	state.Glossary = s.glossary.snapshot()
+	state.ModelAliases = s.aliases.snapshot()
+	state.RateLimits = s.rateLimits.current()
+	cache := s.cache.current()
+	state.Cache = &cache
+	return state
+}
+
+// commitAdminState 保存修改后的设置；保存失败时写出 500 并返回 false，调用方不再换入，运行中的设置保持不变。
+func (s *Handler) commitAdminState(ctx context.Context, w http.ResponseWriter, state adminState) bool {
+	if err := s.saveAdminState(state); err != nil {
+		log.Printf("failed to save admin state: %v", err)
+		writeAPIError(ctx, w, newAPIError("serverError"))
+		return false
+	}
+	return true
+}
+
+// saveAdminState 先写临时文件再重命名，避免进程中途退出留下半个文件。
+func (s *Handler) saveAdminState(state adminState) error {
+	if s.adminStateFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.adminStateFile + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.adminStateFile)
+}
+
+type adminAuditEntry struct {
+	Time          time.Time   `json:"time"`
+	Actor         string      `json:"actor"`
+	ClientAddress string      `json:"client_address"`
+	Action        string      `json:"action"`
+	Before        interface{} `json:"before,omitempty"`
+	After         interface{} `json:"after,omitempty"`
+}
+
+type adminAuditLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenAdminAudit 将管理接口的修改记录以 JSONL 追加写入 path。
+func (s *Handler) OpenAdminAudit(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	s.adminAudit = &adminAuditLog{file: file}
+	return nil
+}
+
+func (s *Handler) auditAdmin(r *http.Request, action string, before, after interface{}) {
+	address := r.RemoteAddr
+	if ip := clientIP(r); ip != nil {
+		address = ip.String()
+	}
+	entry := adminAuditEntry{
+		Time:          time.Now().UTC(),
+		Actor:         "admin",
+		ClientAddress: address,
+		Action:        action,
+		Before:        before,
+		After:         after,
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("failed to encode admin audit entry: %v", err)
+		return
+	}
+	log.Printf("admin audit: %s", data)
+	if s.adminAudit == nil {
+		return
+	}
+	s.adminAudit.mu.Lock()
+	defer s.adminAudit.mu.Unlock()
+	if _, err := s.adminAudit.file.Write(append(data, '\n')); err != nil {
+		log.Printf("failed to write admin audit entry: %v", err)
+	}
+}
+
+type usageResponse struct {
+	Object   string      `json:"object"`
+	Currency string      `json:"currency"`
+	GroupBy  []string    `json:"group_by"`
+	Data     []usageRow  `json:"data"`
+	Total    usageTotals `json:"total"`
+}
+
+// handleAdminUsage 返回用量汇总。查询参数：group_by（day,key,model,language_pair 的组合，默认 day,key,model）、
+// from / to（UTC 日期，包含两端）、key、model，以及 format=csv 导出 CSV。
+func (s *Handler) handleAdminUsage(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	q := usageQuery{
+		GroupBy: []string{"day", "key", "model"},
+		From:    params.Get("from"),
+		To:      params.Get("to"),
+		Key:     params.Get("key"),
+		Model:   params.Get("model"),
+	}
+	if v := params.Get("group_by"); v != "" {
+		q.GroupBy = nil
+		for _, dimension := range splitList(v) {
+			if !slices.Contains(usageDimensions, dimension) {
+				writeAPIError(ctx, w, newAPIError("invalidValue", "group_by", dimension).withParam("group_by"))
+				return
+			}
+			if !slices.Contains(q.GroupBy, dimension) {
+				q.GroupBy = append(q.GroupBy, dimension)
+			}
+		}
+	}
+	for name, value := range map[string]string{"from": q.From, "to": q.To} {
+		if _, err := time.Parse(time.DateOnly, value); value != "" && err != nil {
+			writeAPIError(ctx, w, newAPIError("invalidValue", name, value).withParam(name))
+			return
+		}
+	}
+	format := params.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		writeAPIError(ctx, w, newAPIError("invalidValue", "format", format).withParam("format"))
+		return
+	}
+
+	rows, total := s.ledger.query(q)
+	if format == "csv" {
+		writeUsageCSV(w, q.GroupBy, rows)
+		return
+	}
+	writeJSON(w, http.StatusOK, usageResponse{
+		Object:   "list",
+		Currency: currentPriceTable().Currency,
+		GroupBy:  q.GroupBy,
+		Data:     rows,
+		Total:    total,
+	})
+}
+
+func writeUsageCSV(w http.ResponseWriter, groupBy []string, rows []usageRow) {
+	var header []string
+	for _, dimension := range groupBy {
+		if dimension == "language_pair" {
+			header = append(header, "source_language", "target_language")
+		} else {
+			header = append(header, dimension)
+		}
+	}
+	header = append(header, "requests", "input_tokens", "output_tokens", "total_tokens",
+		"input_characters", "output_characters", "cost", "currency")
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="usage.csv"`)
+	w.WriteHeader(http.StatusOK)
+	out := csv.NewWriter(w)
+	out.Write(header)
+	for _, row := range rows {
+		var record []string
+		for _, dimension := range groupBy {
+			switch dimension {
+			case "day":
+				record = append(record, row.Day)
+			case "key":
+				record = append(record, row.Key)
+			case "model":
+				record = append(record, row.Model)
+			case "language_pair":
+				record = append(record, row.SourceLanguage, row.TargetLanguage)
+			}
+		}
+		record = append(record,
+			strconv.Itoa(row.Requests),
+			strconv.Itoa(row.InputTokens),
+			strconv.Itoa(row.OutputTokens),
+			strconv.Itoa(row.TotalTokens),
+			strconv.Itoa(row.InputChars),
+			strconv.Itoa(row.OutputChars),
+			strconv.FormatFloat(row.Cost, 'f', 6, 64),
+			currentPriceTable().Currency,
+		)
+		out.Write(record)
+	}
+	out.Flush()
+}
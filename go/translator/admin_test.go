@@ -0,0 +1,231 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"doubao/mockupstream"
+)
+
+// useAdminKey 开启管理接口，测试结束后恢复原设置。
+func useAdminKey(t *testing.T) {
+	t.Helper()
+	previous := CONFIG.AdminAPIKey
+	t.Cleanup(func() { CONFIG.AdminAPIKey = previous })
+	CONFIG.AdminAPIKey = "admin-secret"
+}
+
+func serveAdmin(handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdminVirtualKeys(t *testing.T) {
+	useAdminKey(t)
+	handler, mock := newMockHandler(t, mockupstream.Options{})
+
+	rec := serveAdmin(handler, http.MethodPost, "/admin/keys", `{"name":"billing","api_key":"sk-upstream-1234"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var created virtualKeyView
+	decodeJSON(t, rec.Body.String(), &created)
+	if !strings.HasPrefix(created.Key, virtualKeyPrefix) || created.Identity != "vk:billing" || created.APIKeyHint != "…1234" {
+		t.Fatalf("created = %+v", created)
+	}
+	if rec := serveAdmin(handler, http.MethodGet, "/admin/keys", ""); strings.Contains(rec.Body.String(), created.Key) || strings.Contains(rec.Body.String(), "sk-upstream") {
+		t.Errorf("list leaks secrets: %s", rec.Body.String())
+	}
+
+	translate := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(chatBody("m", "Hello", false)))
+		req.Header.Set("Authorization", "Bearer "+created.Key)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := translate(); code != http.StatusOK {
+		t.Fatalf("translate with virtual key = %d", code)
+	}
+	if got := mock.Requests()[0].Authorization; got != "Bearer sk-upstream-1234" {
+		t.Errorf("upstream authorization = %q", got)
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		status    int
+		translate int
+	}{
+		{name: "duplicate name", method: http.MethodPost, path: "/admin/keys", body: `{"name":"billing","api_key":"sk-2"}`, status: http.StatusConflict, translate: http.StatusOK},
+		{name: "invalid name", method: http.MethodPost, path: "/admin/keys", body: `{"name":"a b","api_key":"sk-2"}`, status: http.StatusBadRequest, translate: http.StatusOK},
+		{name: "missing api key", method: http.MethodPost, path: "/admin/keys", body: `{"name":"other"}`, status: http.StatusBadRequest, translate: http.StatusOK},
+		{name: "unknown id", method: http.MethodPatch, path: "/admin/keys/vk-missing", body: `{"disabled":true}`, status: http.StatusNotFound, translate: http.StatusOK},
+		{name: "disable", method: http.MethodPatch, path: "/admin/keys/" + created.ID, body: `{"disabled":true}`, status: http.StatusOK, translate: http.StatusUnauthorized},
+		{name: "enable", method: http.MethodPatch, path: "/admin/keys/" + created.ID, body: `{"disabled":false}`, status: http.StatusOK, translate: http.StatusOK},
+		{name: "delete", method: http.MethodDelete, path: "/admin/keys/" + created.ID, status: http.StatusNoContent, translate: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serveAdmin(handler, tt.method, tt.path, tt.body); rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if code := translate(); code != tt.translate {
+				t.Errorf("translate status = %d, want %d", code, tt.translate)
+			}
+		})
+	}
+}
+
+func TestAdminResources(t *testing.T) {
+	useAdminKey(t)
+	handler, mock := newMockHandler(t, mockupstream.Options{})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		want   string
+	}{
+		{name: "set alias", method: http.MethodPut, path: "/admin/aliases/fast", body: `{"model":"ep-123"}`, status: http.StatusOK, want: `"model":"ep-123"`},
+		{name: "alias to itself", method: http.MethodPut, path: "/admin/aliases/fast", body: `{"model":"fast"}`, status: http.StatusBadRequest},
+		{name: "list aliases", method: http.MethodGet, path: "/admin/aliases", status: http.StatusOK, want: `"fast":"ep-123"`},
+		{name: "add term", method: http.MethodPost, path: "/admin/glossary", body: `{"source":"Acme","target":"アクメ","target_language":"Japanese"}`, status: http.StatusCreated, want: `"target_language":"ja"`},
+		{name: "duplicate term", method: http.MethodPost, path: "/admin/glossary", body: `{"source":"Acme","target":"x","target_language":"ja"}`, status: http.StatusConflict},
+		{name: "unknown language", method: http.MethodPost, path: "/admin/glossary", body: `{"source":"Acme","target":"x","target_language":"klingon"}`, status: http.StatusBadRequest},
+		{name: "filter terms", method: http.MethodGet, path: "/admin/glossary?target_language=fr", status: http.StatusOK, want: `"data":[]`},
+		{name: "set rate limits", method: http.MethodPut, path: "/admin/rate-limits", body: `{"identities":{"anonymous":{"requests_per_minute":60}}}`, status: http.StatusOK},
+		{name: "negative rate", method: http.MethodPut, path: "/admin/rate-limits", body: `{"default":{"requests_per_minute":-1}}`, status: http.StatusBadRequest},
+		{name: "enable cache", method: http.MethodPatch, path: "/admin/cache", body: `{"max_entries":10}`, status: http.StatusOK, want: `"ttl_seconds":3600`},
+		{name: "negative ttl", method: http.MethodPatch, path: "/admin/cache", body: `{"ttl_seconds":-1}`, status: http.StatusBadRequest},
+		{name: "unknown cache key", method: http.MethodDelete, path: "/admin/cache/missing", status: http.StatusNotFound},
+		{name: "unknown alias", method: http.MethodDelete, path: "/admin/aliases/slow", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveAdmin(handler, tt.method, tt.path, tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("body %s does not contain %s", rec.Body.String(), tt.want)
+			}
+		})
+	}
+
+	// 别名在发往上游前替换，术语替换为占位符，相同的非流式请求第二次命中缓存。
+	for i := 0; i < 2; i++ {
+		if rec := serveJSON(handler, "/v1/chat/completions", chatBody("fast", "Acme", false)); rec.Code != http.StatusOK ||
+			!strings.Contains(rec.Body.String(), `"model":"fast"`) || !strings.Contains(rec.Body.String(), "アクメ") {
+			t.Fatalf("translate %d = %d: %s", i+1, rec.Code, rec.Body.String())
+		}
+	}
+	requests := mock.Requests()
+	if len(requests) != 1 || requests[0].Model != "ep-123" || requests[0].Text != "[[GLOSSARY_1]]" {
+		t.Errorf("upstream requests = %+v, want one request to ep-123 with a placeholder", requests)
+	}
+	if rec := serveAdmin(handler, http.MethodDelete, "/admin/cache", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"purged":1`) {
+		t.Errorf("purge = %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAdminRateLimitEnforced(t *testing.T) {
+	useAdminKey(t)
+	handler, _ := newMockHandler(t, mockupstream.Options{})
+	if rec := serveAdmin(handler, http.MethodPut, "/admin/rate-limits", `{"default":{"requests_per_minute":1}}`); rec.Code != http.StatusOK {
+		t.Fatalf("set rate limits = %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := serveJSON(handler, "/v1/chat/completions", chatBody("m", "Hello", false)); rec.Code != http.StatusOK {
+		t.Fatalf("first request = %d", rec.Code)
+	}
+	rec := serveJSON(handler, "/v1/chat/completions", chatBody("m", "Hello", false))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "60" {
+		t.Errorf("second request = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+// TestAdminStateSaveFailure 确认保存失败时返回 500，运行中的设置保持不变。
+func TestAdminStateSaveFailure(t *testing.T) {
+	useAdminKey(t)
+	handler, _ := newMockHandler(t, mockupstream.Options{})
+	handler.adminStateFile = filepath.Join(t.TempDir(), "missing", "state.json")
+	handler.aliases.replace(map[string]string{"fast": "ep-1"})
+	handler.glossary.replace([]glossaryEntry{{ID: "term-1", Source: "Acme", Target: "アクメ", TargetLanguage: "ja"}})
+	handler.virtualKeys.replace([]virtualKey{{ID: "vk-1", Name: "billing", KeyHash: hashVirtualKey("vk-test"), APIKey: "sk-1"}})
+	before := handler.adminSnapshot()
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: http.MethodPatch, path: "/admin/upstream", body: `{"enabled":false}`},
+		{method: http.MethodPatch, path: "/admin/cache", body: `{"max_entries":10}`},
+		{method: http.MethodPost, path: "/admin/keys", body: `{"name":"other","api_key":"sk-2"}`},
+		{method: http.MethodPatch, path: "/admin/keys/vk-1", body: `{"disabled":true}`},
+		{method: http.MethodDelete, path: "/admin/keys/vk-1"},
+		{method: http.MethodPut, path: "/admin/aliases/fast", body: `{"model":"ep-2"}`},
+		{method: http.MethodDelete, path: "/admin/aliases/fast"},
+		{method: http.MethodPost, path: "/admin/glossary", body: `{"source":"Beta","target":"ベータ","target_language":"ja"}`},
+		{method: http.MethodDelete, path: "/admin/glossary/term-1"},
+		{method: http.MethodPut, path: "/admin/rate-limits", body: `{"default":{"requests_per_minute":1}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if rec := serveAdmin(handler, tt.method, tt.path, tt.body); rec.Code != http.StatusInternalServerError {
+				t.Fatalf("status = %d, want 500: %s", rec.Code, rec.Body.String())
+			}
+			if after := handler.adminSnapshot(); !reflect.DeepEqual(after, before) {
+				t.Errorf("state changed after a failed save:\n got %+v\nwant %+v", after, before)
+			}
+		})
+	}
+}
+
+func TestAdminStatePersistence(t *testing.T) {
+	useAdminKey(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+	handler, _ := newMockHandler(t, mockupstream.Options{})
+	if err := handler.LoadAdminState(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, req := range []struct{ method, path, body string }{
+		{http.MethodPatch, "/admin/upstream", `{"enabled":false}`},
+		{http.MethodPost, "/admin/keys", `{"name":"billing","api_key":"sk-1"}`},
+		{http.MethodPut, "/admin/aliases/fast", `{"model":"ep-1"}`},
+		{http.MethodPost, "/admin/glossary", `{"source":"Acme","target":"アクメ","target_language":"ja"}`},
+		{http.MethodPut, "/admin/rate-limits", `{"default":{"requests_per_minute":5}}`},
+		{http.MethodPatch, "/admin/cache", `{"max_entries":3,"ttl_seconds":60}`},
+	} {
+		if rec := serveAdmin(handler, req.method, req.path, req.body); rec.Code >= 300 {
+			t.Fatalf("%s %s = %d: %s", req.method, req.path, rec.Code, rec.Body.String())
+		}
+	}
+
+	reloaded := newHandler(nil)
+	if err := reloaded.LoadAdminState(path); err != nil {
+		t.Fatal(err)
+	}
+	want, got := handler.adminSnapshot(), reloaded.adminSnapshot()
+	// 时间经 JSON 往返后丢失单调时钟读数，按序列化结果比较。
+	wantJSON, _ := json.Marshal(want)
+	gotJSON, _ := json.Marshal(got)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("reloaded state = %s, want %s", gotJSON, wantJSON)
+	}
+	if got.Upstream.Enabled || got.Cache.MaxEntries != 3 || len(got.VirtualKeys) != 1 {
+		t.Errorf("reloaded state = %+v", got)
+	}
+}
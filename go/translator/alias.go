@@ -0,0 +1,107 @@
+package translator
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// 模型别名：调用方使用的模型名到上游模型（方舟 endpoint ID 或模型名）的映射，在发往上游前替换。
+// 响应、用量台账与日志中仍使用调用方请求的模型名。
+
+type modelAliases struct {
+	mu      sync.RWMutex
+	aliases map[string]string
+}
+
+func newModelAliases() *modelAliases {
+	return &modelAliases{aliases: map[string]string{}}
+}
+
+// resolve 返回 model 对应的上游模型，没有别名时原样返回。
+func (a *modelAliases) resolve(model string) string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if target, ok := a.aliases[model]; ok {
+		return target
+	}
+	return model
+}
+
+func (a *modelAliases) snapshot() map[string]string {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return maps.Clone(a.aliases)
+}
+
+func (a *modelAliases) replace(aliases map[string]string) {
+	if aliases == nil {
+		aliases = map[string]string{}
+	}
+	a.mu.Lock()
+	a.aliases = aliases
+	a.mu.Unlock()
+}
+
+type aliasRequest struct {
+	Model string `json:"model"`
+}
+
+type aliasView struct {
+	Alias string `json:"alias"`
+	Model string `json:"model"`
+}
+
+// handleAdminAliases 管理模型别名：GET /admin/aliases 列出，PUT /admin/aliases/{alias} 设置（{"model":"..."}），
+// DELETE /admin/aliases/{alias} 删除。
+func (s *Handler) handleAdminAliases(ctx context.Context, w http.ResponseWriter, r *http.Request, alias string) {
+	switch {
+	case alias == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.aliases.snapshot())
+	case alias != "" && r.Method == http.MethodPut:
+		var req aliasRequest
+		if err := readAdminJSON(r, &req); err != nil {
+			writeAPIError(ctx, w, err)
+			return
+		}
+		req.Model = strings.TrimSpace(req.Model)
+		if req.Model == "" || req.Model == alias {
+			writeAPIError(ctx, w, newAPIError("invalidValue", "model", req.Model).withParam("model"))
+			return
+		}
+		state := s.adminSnapshot()
+		var before interface{}
+		if model, ok := state.ModelAliases[alias]; ok {
+			before = aliasView{Alias: alias, Model: model}
+		}
+		if state.ModelAliases == nil {
+			state.ModelAliases = map[string]string{}
+		}
+		state.ModelAliases[alias] = req.Model
+		if !s.commitAdminState(ctx, w, state) {
+			return
+		}
+		s.aliases.replace(state.ModelAliases)
+		after := aliasView{Alias: alias, Model: req.Model}
+		s.auditAdmin(r, "alias.set", before, after)
+		writeJSON(w, http.StatusOK, after)
+	case alias != "" && r.Method == http.MethodDelete:
+		state := s.adminSnapshot()
+		model, ok := state.ModelAliases[alias]
+		if !ok {
+			writeAPIError(ctx, w, newAPIError("notFound"))
+			return
+		}
+		delete(state.ModelAliases, alias)
+		if !s.commitAdminState(ctx, w, state) {
+			return
+		}
+		s.aliases.replace(state.ModelAliases)
+		s.auditAdmin(r, "alias.delete", aliasView{Alias: alias, Model: model}, nil)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeAPIError(ctx, w, newAPIError("notFound"))
+	}
+}
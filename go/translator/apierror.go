@@ -0,0 +1,316 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAI 规范的错误对象：{"error":{"message","type","param","code"}}。
+// 文案按 Accept-Language 本地化，默认使用中文以保持与边缘函数版本一致。
+
+type errorTemplate struct {
+	Status   int
+	Type     string
+	Code     string
+	Param    string
+	Messages map[string]string
+}
+
+var errorTemplates = map[string]errorTemplate{
+	"https": {Status: http.StatusForbidden, Type: "security_error", Code: "https_required",
+		Messages: map[string]string{"zh": "需要 HTTPS", "en": "HTTPS is required"}},
+	"unknownClientCertificate": {Status: http.StatusForbidden, Type: "permission_error", Code: "unknown_client_certificate",
+		Messages: map[string]string{"zh": "客户端证书未映射到任何身份：%s", "en": "Client certificate is not mapped to an identity: %s"}},
+	"ipDenied": {Status: http.StatusForbidden, Type: "permission_error", Code: "ip_not_allowed",
+		Messages: map[string]string{"zh": "客户端 IP 不允许访问：%s", "en": "Client IP is not allowed: %s"}},
+	"invalidVirtualKey": {Status: http.StatusUnauthorized, Type: "invalid_request_error", Code: "invalid_api_key",
+		Messages: map[string]string{"zh": "虚拟密钥无效或已停用", "en": "Virtual key is invalid or disabled"}},
+	"rateLimited": {Status: http.StatusTooManyRequests, Type: "rate_limit_error", Code: "rate_limit_exceeded",
+		Messages: map[string]string{"zh": "请求过于频繁（每分钟最多 %d 次），请稍后重试", "en": "Too many requests (limit %d per minute), please retry later"}},
+	"rateLimitBatch": {Status: http.StatusTooManyRequests, Type: "rate_limit_error", Code: "rate_limit_exceeded",
+		Messages: map[string]string{"zh": "批量请求包含 %d 条文本，超过限流容量 %d", "en": "Batch of %d texts exceeds the rate limit burst of %d"}},
+	"adminConflict": {Status: http.StatusConflict, Type: "invalid_request_error", Code: "already_exists",
+		Messages: map[string]string{"zh": "已存在：%s", "en": "Already exists: %s"}},
+	"notFound": {Status: http.StatusNotFound, Type: "invalid_request_error", Code: "not_found",
+		Messages: map[string]string{"zh": "Not Found", "en": "Not Found"}},
+	"adminAuth": {Status: http.StatusUnauthorized, Type: "invalid_request_error", Code: "invalid_admin_key",
+		Messages: map[string]string{"zh": "管理接口密钥无效", "en": "Invalid admin API key"}},
+	"noAuth": {Status: http.StatusUnauthorized, Type: "invalid_request_error", Code: "invalid_api_key",
+		Messages: map[string]string{"zh": "缺少 API 密钥", "en": "Missing API key"}},
+	"tooLarge": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "request_too_large",
+		Messages: map[string]string{"zh": "请求过大", "en": "Request is too large"}},
+	"noMessage": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "missing_user_message", Param: "messages",
+		Messages: map[string]string{"zh": "无用户消息", "en": "No user message found"}},
+	"noModel": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "missing_required_parameter", Param: "model",
+		Messages: map[string]string{"zh": "缺少 model", "en": "Missing required parameter: model"}},
+	"invalidJson": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "invalid_json",
+		Messages: map[string]string{"zh": "无效 JSON", "en": "Invalid JSON body"}},
+	"serverError": {Status: http.StatusInternalServerError, Type: "api_error", Code: "internal_error",
+		Messages: map[string]string{"zh": "内部服务错误", "en": "Internal server error"}},
+	"unknownLanguage": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "unsupported_language",
+		Messages: map[string]string{"zh": "无法识别的语言：%s", "en": "Unrecognized language: %s"}},
+	"unsupportedLanguage": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "unsupported_language",
+		Messages: map[string]string{"zh": "豆包翻译模型暂不支持该语言：%s", "en": "Language not supported by the Doubao translation model: %s"}},
+	"invalidType": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "invalid_type",
+		Messages: map[string]string{"zh": "参数 %s 类型无效，应为 %s", "en": "Invalid type for %s: expected %s"}},
+	"invalidValue": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "invalid_value",
+		Messages: map[string]string{"zh": "参数 %s 的取值无效：%s", "en": "Invalid value for %s: %s"}},
+	"unsupportedContentPart": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "unsupported_content_part",
+		Messages: map[string]string{"zh": "不支持的内容类型：%s，仅支持文本", "en": "Unsupported content part type: %s (only text is supported)"}},
+	"streamOptionsWithoutStream": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "invalid_value", Param: "stream_options",
+		Messages: map[string]string{"zh": "仅在 stream 为 true 时允许设置 stream_options", "en": "The 'stream_options' parameter is only allowed when 'stream' is enabled"}},
+	"inputTooLong": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "input_too_long",
+		Messages: map[string]string{"zh": "输入文本过长：%d 字符，上限 %d", "en": "Input is too long: %d characters, limit is %d"}},
+	"upstream": {Status: http.StatusBadGateway, Type: "api_error", Code: "upstream_error",
+		Messages: map[string]string{"zh": "上游 API 错误：%s", "en": "Upstream API error: %s"}},
+	"upstreamAuth": {Status: http.StatusUnauthorized, Type: "invalid_request_error", Code: "invalid_api_key",
+		Messages: map[string]string{"zh": "上游 API 错误：%s", "en": "Upstream API error: %s"}},
+	"upstreamForbidden": {Status: http.StatusForbidden, Type: "invalid_request_error", Code: "permission_denied",
+		Messages: map[string]string{"zh": "上游 API 错误：%s", "en": "Upstream API error: %s"}},
+	"upstreamNotFound": {Status: http.StatusNotFound, Type: "invalid_request_error", Code: "model_not_found",
+		Messages: map[string]string{"zh": "上游 API 错误：%s", "en": "Upstream API error: %s"}},
+	"upstreamRateLimit": {Status: http.StatusTooManyRequests, Type: "rate_limit_error", Code: "rate_limit_exceeded",
+		Messages: map[string]string{"zh": "上游 API 错误：%s", "en": "Upstream API error: %s"}},
+	"upstreamBadRequest": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "upstream_bad_request",
+		Messages: map[string]string{"zh": "上游 API 错误：%s", "en": "Upstream API error: %s"}},
+	"upstreamTimeout": {Status: http.StatusGatewayTimeout, Type: "api_error", Code: "upstream_timeout",
+		Messages: map[string]string{"zh": "上游 API 超时：%s", "en": "Upstream API timed out: %s"}},
+	"streamFirstTokenTimeout": {Status: http.StatusGatewayTimeout, Type: "api_error", Code: "upstream_timeout",
+		Messages: map[string]string{"zh": "上游在 %s 内未返回首个 token", "en": "Upstream did not return the first token within %s"}},
+	"streamIdleTimeout": {Status: http.StatusGatewayTimeout, Type: "api_error", Code: "upstream_timeout",
+		Messages: map[string]string{"zh": "上游流式响应超过 %s 没有新数据", "en": "Upstream stream was idle for more than %s"}},
+	"streamInterrupted": {Status: http.StatusBadGateway, Type: "api_error", Code: "stream_error",
+		Messages: map[string]string{"zh": "上游流式响应中断：%s", "en": "Upstream stream was interrupted: %s"}},
+	"websocketRequired": {Status: http.StatusUpgradeRequired, Type: "invalid_request_error", Code: "websocket_required",
+		Messages: map[string]string{"zh": "该接口需要 WebSocket 连接", "en": "This endpoint requires a WebSocket connection"}},
+	"websocketOrigin": {Status: http.StatusForbidden, Type: "security_error", Code: "origin_not_allowed",
+		Messages: map[string]string{"zh": "不允许来自 %s 的 WebSocket 连接", "en": "WebSocket connections from %s are not allowed"}},
+	"segmentQueueFull": {Status: http.StatusTooManyRequests, Type: "rate_limit_error", Code: "segment_queue_full",
+		Messages: map[string]string{"zh": "待翻译片段过多（上限 %d），请稍后再发送", "en": "Too many pending segments (limit %d), please retry later"}},
+	"batchTooLarge": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "batch_too_large",
+		Messages: map[string]string{"zh": "批量翻译最多 %d 条", "en": "Batch size exceeds the limit of %d"}},
+	"upstreamDisabled": {Status: http.StatusServiceUnavailable, Type: "api_error", Code: "upstream_disabled",
+		Messages: map[string]string{"zh": "上游已被管理员停用", "en": "The upstream endpoint has been disabled by an administrator"}},
+	"piiRejected": {Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "pii_rejected",
+		Messages: map[string]string{"zh": "请求包含不允许发送到上游的个人信息：%s", "en": "Request contains personal information that may not be sent upstream: %s"}},
+	"placeholderMismatch": {Status: http.StatusBadGateway, Type: "api_error", Code: "placeholder_mismatch",
+		Messages: map[string]string{"zh": "译文中的占位符与原文不一致（实际/应有次数）：%s", "en": "Placeholders in the translation do not match the source (found/expected): %s"}},
+	"upstreamNoResult": {Status: http.StatusBadGateway, Type: "api_error", Code: "upstream_empty_result",
+		Messages: map[string]string{"zh": "上游 API 错误：未找到有效的翻译结果", "en": "Upstream API error: no translation found in response"}},
+}
+
+var supportedLocales = []string{"zh", "en"}
+
+type apiError struct {
+	Template string
+	Param    string
+	Args     []interface{}
+	Header   http.Header
+}
+
+func newAPIError(template string, args ...interface{}) *apiError {
+	return &apiError{Template: template, Args: args}
+}
+
+func (e *apiError) withParam(param string) *apiError {
+	e.Param = param
+	return e
+}
+
+func (e *apiError) Error() string {
+	return e.message(CONFIG.DefaultLocale)
+}
+
+func (e *apiError) template() errorTemplate {
+	if tmpl, ok := errorTemplates[e.Template]; ok {
+		return tmpl
+	}
+	return errorTemplates["serverError"]
+}
+
+func (e *apiError) status() int {
+	return e.template().Status
+}
+
+func (e *apiError) message(locale string) string {
+	tmpl := e.template()
+	format, ok := tmpl.Messages[locale]
+	if !ok {
+		format = tmpl.Messages["zh"]
+	}
+	if len(e.Args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, e.Args...)
+}
+
+type openAIErrorBody struct {
+	Error openAIErrorObject `json:"error"`
+}
+
+type openAIErrorObject struct {
+	Message string  `json:"message"`
+	Type    string  `json:"type"`
+	Param   *string `json:"param"`
+	Code    *string `json:"code"`
+}
+
+// object 构造 OpenAI 错误对象；param 与 code 缺省时序列化为 null。
+func (e *apiError) object(locale string) openAIErrorObject {
+	tmpl := e.template()
+	obj := openAIErrorObject{Message: e.message(locale), Type: tmpl.Type}
+	param := e.Param
+	if param == "" {
+		param = tmpl.Param
+	}
+	if param != "" {
+		obj.Param = &param
+	}
+	if tmpl.Code != "" {
+		code := tmpl.Code
+		obj.Code = &code
+	}
+	return obj
+}
+
+// body 渲染完整的 {"error":{...}} 响应体。
+func (e *apiError) body(locale string) string {
+	data, err := json.Marshal(openAIErrorBody{Error: e.object(locale)})
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// upstreamError 描述 Ark 返回的非 2xx 响应或网络错误，保留上游状态码以便映射。
+type upstreamError struct {
+	Status     int
+	Message    string
+	RetryAfter string
+	Timeout    bool
+}
+
+func (e *upstreamError) Error() string {
+	return e.Message
+}
+
+func (e *upstreamError) apiError() *apiError {
+	message := e.Message
+	if message == "" {
+		message = "未知错误"
+	}
+	var apiErr *apiError
+	switch {
+	case e.Timeout:
+		apiErr = newAPIError("upstreamTimeout", message)
+	case e.Status == http.StatusUnauthorized:
+		apiErr = newAPIError("upstreamAuth", message)
+	case e.Status == http.StatusForbidden:
+		apiErr = newAPIError("upstreamForbidden", message)
+	case e.Status == http.StatusNotFound:
+		apiErr = newAPIError("upstreamNotFound", message).withParam("model")
+	case e.Status == http.StatusTooManyRequests:
+		apiErr = newAPIError("upstreamRateLimit", message)
+	case e.Status >= 400 && e.Status < 500:
+		apiErr = newAPIError("upstreamBadRequest", message)
+	default:
+		apiErr = newAPIError("upstream", message)
+	}
+	if e.RetryAfter != "" {
+		apiErr.Header = http.Header{"Retry-After": []string{e.RetryAfter}}
+	}
+	return apiErr
+}
+
+func newTransportError(err error) *upstreamError {
+	var netErr net.Error
+	timeout := errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+	return &upstreamError{Message: err.Error(), Timeout: timeout}
+}
+
+// toAPIError 将任意错误归一为 *apiError：上游错误按状态码映射，其余视为内部错误。
+func toAPIError(err error) *apiError {
+	var apiErr *apiError
+	var upErr *upstreamError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.As(err, &upErr):
+		return upErr.apiError()
+	default:
+		return newAPIError("serverError")
+	}
+}
+
+// writeAPIError 将任意错误渲染为本地化的 OpenAI 错误响应。
+func writeAPIError(ctx context.Context, w http.ResponseWriter, err error) {
+	apiErr := toAPIError(err)
+	for key, values := range apiErr.Header {
+		for _, v := range values {
+			w.Header().Add(key, v)
+		}
+	}
+	status := apiErr.status()
+	spanFromContext(ctx).setAttr("error.code", apiErr.template().Code)
+	recordRecentError(ctx, apiErr)
+	writeError(w, status, apiErr.body(localeFromContext(ctx)))
+}
+
+type localeKey struct{}
+
+func withLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+func localeFromContext(ctx context.Context) string {
+	if ctx != nil {
+		if locale, ok := ctx.Value(localeKey{}).(string); ok && locale != "" {
+			return locale
+		}
+	}
+	return CONFIG.DefaultLocale
+}
+
+// negotiateLocale 解析 Accept-Language（含 q 权重），返回支持的最佳语言。
+func negotiateLocale(header string) string {
+	type candidate struct {
+		tag string
+		q   float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, candidate{tag: tag, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	for _, c := range candidates {
+		primary, _, _ := strings.Cut(c.tag, "-")
+		for _, locale := range supportedLocales {
+			if primary == locale {
+				return locale
+			}
+		}
+	}
+	return CONFIG.DefaultLocale
+}
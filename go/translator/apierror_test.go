@@ -0,0 +1,131 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNegotiateLocale(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{header: "", want: "zh"},
+		{header: "en", want: "en"},
+		{header: "en-US,en;q=0.9", want: "en"},
+		{header: "fr-FR,en;q=0.8,zh;q=0.9", want: "zh"},
+		{header: "zh-TW;q=0.2, en-GB;q=0.7", want: "en"},
+		{header: "en;q=0, zh", want: "zh"},
+		{header: "de, fr", want: "zh"},
+		{header: "EN", want: "en"},
+	}
+	for _, tt := range tests {
+		if got := negotiateLocale(tt.header); got != tt.want {
+			t.Errorf("negotiateLocale(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestAPIErrorBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    *apiError
+		locale string
+		want   string
+	}{
+		{
+			name: "template param", err: newAPIError("noModel"), locale: "en",
+			want: `{"error":{"message":"Missing required parameter: model","type":"invalid_request_error","param":"model","code":"missing_required_parameter"}}`,
+		},
+		{
+			name: "null param", err: newAPIError("invalidJson"), locale: "zh",
+			want: `{"error":{"message":"无效 JSON","type":"invalid_request_error","param":null,"code":"invalid_json"}}`,
+		},
+		{
+			name: "explicit param and args", err: newAPIError("invalidType", "stream", "boolean").withParam("stream"), locale: "en",
+			want: `{"error":{"message":"Invalid type for stream: expected boolean","type":"invalid_request_error","param":"stream","code":"invalid_type"}}`,
+		},
+		{
+			name: "unsupported locale falls back to zh", err: newAPIError("noAuth"), locale: "fr",
+			want: `{"error":{"message":"缺少 API 密钥","type":"invalid_request_error","param":null,"code":"invalid_api_key"}}`,
+		},
+		{
+			name: "unknown template", err: newAPIError("doesNotExist"), locale: "en",
+			want: `{"error":{"message":"Internal server error","type":"api_error","param":null,"code":"internal_error"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.body(tt.locale); got != tt.want {
+				t.Errorf("body = %s\nwant   %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorTemplatesAreLocalized(t *testing.T) {
+	for name, tmpl := range errorTemplates {
+		for _, locale := range supportedLocales {
+			if tmpl.Messages[locale] == "" {
+				t.Errorf("template %s has no %s message", name, locale)
+			}
+		}
+		if tmpl.Status == 0 || tmpl.Type == "" || tmpl.Code == "" {
+			t.Errorf("template %s is missing status, type or code: %+v", name, tmpl)
+		}
+	}
+}
+
+func TestUpstreamErrorMapping(t *testing.T) {
+	tests := []struct {
+		err        *upstreamError
+		wantStatus int
+		wantCode   string
+	}{
+		{err: &upstreamError{Status: http.StatusUnauthorized, Message: "bad key"}, wantStatus: http.StatusUnauthorized, wantCode: "invalid_api_key"},
+		{err: &upstreamError{Status: http.StatusForbidden}, wantStatus: http.StatusForbidden, wantCode: "permission_denied"},
+		{err: &upstreamError{Status: http.StatusNotFound}, wantStatus: http.StatusNotFound, wantCode: "model_not_found"},
+		{err: &upstreamError{Status: http.StatusTooManyRequests, RetryAfter: "7"}, wantStatus: http.StatusTooManyRequests, wantCode: "rate_limit_exceeded"},
+		{err: &upstreamError{Status: http.StatusUnprocessableEntity}, wantStatus: http.StatusBadRequest, wantCode: "upstream_bad_request"},
+		{err: &upstreamError{Status: http.StatusServiceUnavailable}, wantStatus: http.StatusBadGateway, wantCode: "upstream_error"},
+		{err: newTransportError(context.DeadlineExceeded), wantStatus: http.StatusGatewayTimeout, wantCode: "upstream_timeout"},
+		{err: newTransportError(errors.New("connection refused")), wantStatus: http.StatusBadGateway, wantCode: "upstream_error"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeAPIError(withLocale(context.Background(), "en"), rec, tt.err)
+		var body openAIErrorBody
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%+v: %v", tt.err, err)
+		}
+		if rec.Code != tt.wantStatus || body.Error.Code == nil || *body.Error.Code != tt.wantCode {
+			t.Errorf("%+v: status %d code %v, want %d %s", tt.err, rec.Code, body.Error.Code, tt.wantStatus, tt.wantCode)
+		}
+		if tt.err.RetryAfter != "" && rec.Header().Get("Retry-After") != tt.err.RetryAfter {
+			t.Errorf("Retry-After = %q, want %q", rec.Header().Get("Retry-After"), tt.err.RetryAfter)
+		}
+	}
+}
+
+func TestLocalizedErrorResponse(t *testing.T) {
+	for _, tt := range []struct{ acceptLanguage, want string }{
+		{acceptLanguage: "en-US", want: "Missing API key"},
+		{acceptLanguage: "zh-CN", want: "缺少 API 密钥"},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		req.Header.Set("Accept-Language", tt.acceptLanguage)
+		rec := httptest.NewRecorder()
+		newHandler(nil).ServeHTTP(rec, req)
+		var body openAIErrorBody
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusUnauthorized || body.Error.Message != tt.want {
+			t.Errorf("Accept-Language %s: status %d message %q, want 401 %q", tt.acceptLanguage, rec.Code, body.Error.Message, tt.want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package translator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 翻译审计日志：每次把文本发往上游模型时记录调用方、时间、模型、语言对与（按策略脱敏后的）原文，
+// 以 JSONL 追加写入 CONFIG.AuditLogDir 下按天与大小轮转的文件，并按 CONFIG.AuditRetention 清理过期文件。
+// 请求路径只把原始条目放入有界队列，脱敏、编码与写盘都在后台 goroutine 中完成；队列满时丢弃并计数，不阻塞翻译。
+
+const (
+	AuditRedactNone     = "none"
+	AuditRedactHash     = "hash"
+	AuditRedactTruncate = "truncate"
+	AuditRedactMask     = "mask"
+)
+
+// ParseAuditRedaction 解析逗号分隔的脱敏策略：hash 只保留 SHA-256 与长度，不能与其他策略组合；
+// truncate 与 mask 可以组合（先遮盖 PII 再截断）；none 保留原文。
+func ParseAuditRedaction(value string) ([]string, error) {
+	modes := splitList(strings.ToLower(value))
+	if len(modes) == 0 {
+		return nil, fmt.Errorf("empty redaction mode")
+	}
+	for _, mode := range modes {
+		switch mode {
+		case AuditRedactNone, AuditRedactHash:
+			if len(modes) > 1 {
+				return nil, fmt.Errorf("%q cannot be combined with other modes", mode)
+			}
+		case AuditRedactTruncate, AuditRedactMask:
+		default:
+			return nil, fmt.Errorf("unknown redaction mode %q", mode)
+		}
+	}
+	return modes, nil
+}
+
+// auditQueueSize 为后台写入队列的容量。
+const auditQueueSize = 1024
+
+type auditEvent struct {
+	time      time.Time
+	requestID string
+	identity  Identity
+	client    string
+	path      string
+	model     string
+	source    string
+	target    string
+	stream    bool
+	text      string
+}
+
+type auditText struct {
+	Text      string `json:"text,omitempty"`
+	SHA256    string `json:"sha256"`
+	Chars     int    `json:"characters"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Masked    bool   `json:"masked,omitempty"`
+}
+
+type auditEntry struct {
+	Time           time.Time `json:"time"`
+	RequestID      string    `json:"request_id,omitempty"`
+	Identity       string    `json:"identity"`
+	IdentityMethod string    `json:"identity_method,omitempty"`
+	Subject        string    `json:"subject,omitempty"`
+	ClientAddress  string    `json:"client_address,omitempty"`
+	Endpoint       string    `json:"endpoint,omitempty"`
+	Model          string    `json:"model"`
+	SourceLanguage string    `json:"source_language"`
+	TargetLanguage string    `json:"target_language"`
+	Stream         bool      `json:"stream"`
+	Input          auditText `json:"input"`
+}
+
+type auditLogger struct {
+	dir       string
+	redaction []string
+	truncate  int
+	retention time.Duration
+	maxBytes  int64
+
+	// mu 保护 closed 与 dropped，保证关闭之后不再向 events 发送。
+	mu      sync.Mutex
+	events  chan auditEvent
+	done    chan struct{}
+	closed  bool
+	dropped int64
+
+	file     *os.File
+	fileDay  string
+	fileSize int64
+}
+
+// OpenAuditLog 按 CONFIG 中的审计设置开启翻译审计日志。
+func (s *Handler) OpenAuditLog(dir string) error {
+	redaction, err := ParseAuditRedaction(CONFIG.AuditRedaction)
+	if err != nil {
+		return fmt.Errorf("AUDIT_REDACTION: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	a := &auditLogger{
+		dir:       dir,
+		redaction: redaction,
+		truncate:  CONFIG.AuditTruncateChars,
+		retention: CONFIG.AuditRetention,
+		maxBytes:  CONFIG.AuditMaxFileBytes,
+		events:    make(chan auditEvent, auditQueueSize),
+		done:      make(chan struct{}),
+	}
+	a.prune(time.Now())
+	go a.run()
+	s.audit = a
+	return nil
+}
+
+// auditUpstreamRequest 在请求发往上游前登记审计事件；只做一次非阻塞的入队。
+func (s *Handler) auditUpstreamRequest(ctx context.Context, payload doubaoRequest) {
+	if s.audit == nil || len(payload.Input) == 0 || len(payload.Input[0].Content) == 0 {
+		return
+	}
+	content := payload.Input[0].Content[0]
+	event := auditEvent{
+		time:   time.Now().UTC(),
+		model:  payload.Model,
+		target: content.TranslationOptions.TargetLanguage,
+		stream: payload.Stream,
+		text:   content.Text,
+		source: "auto",
+	}
+	if content.TranslationOptions.SourceLanguage != nil && *content.TranslationOptions.SourceLanguage != "" {
+		event.source = *content.TranslationOptions.SourceLanguage
+	}
+	if identity, ok := IdentityFromContext(ctx); ok {
+		event.identity = identity
+	}
+	updateInflight(ctx, func(req *inflightRequest) {
+		event.requestID, event.client, event.path = req.ID, req.ClientAddress, req.Path
+	})
+	s.audit.enqueue(event)
+}
+
+func (a *auditLogger) enqueue(event auditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return
+	}
+	select {
+	case a.events <- event:
+	default:
+		a.dropped++
+		if a.dropped == 1 || a.dropped%1000 == 0 {
+			log.Printf("audit log queue is full, %d entries dropped so far", a.dropped)
+		}
+	}
+}
+
+func (a *auditLogger) run() {
+	defer close(a.done)
+	for event := range a.events {
+		a.write(event)
+	}
+	if a.file != nil {
+		a.file.Close()
+	}
+}
+
+// close 停止接收新条目，等待队列中已有的条目写完。
+func (a *auditLogger) close() {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.events)
+	}
+	a.mu.Unlock()
+	<-a.done
+}
+
+func (a *auditLogger) redact(text string) auditText {
+	sum := sha256.Sum256([]byte(text))
+	result := auditText{SHA256: hex.EncodeToString(sum[:]), Chars: utf8.RuneCountInString(text)}
+	if slices.Contains(a.redaction, AuditRedactHash) {
+		return result
+	}
+	if slices.Contains(a.redaction, AuditRedactMask) {
+		if masked := maskPII(text); masked != text {
+			text, result.Masked = masked, true
+		}
+	}
+	if slices.Contains(a.redaction, AuditRedactTruncate) && a.truncate > 0 && utf8.RuneCountInString(text) > a.truncate {
+		text, result.Truncated = string([]rune(text)[:a.truncate]), true
+	}
+	result.Text = text
+	return result
+}
+
+func (a *auditLogger) write(event auditEvent) {
+	entry := auditEntry{
+		Time:           event.time,
+		RequestID:      event.requestID,
+		Identity:       event.identity.Name,
+		IdentityMethod: event.identity.Method,
+		Subject:        event.identity.Subject,
+		ClientAddress:  event.client,
+		Endpoint:       event.path,
+		Model:          event.model,
+		SourceLanguage: event.source,
+		TargetLanguage: event.target,
+		Stream:         event.stream,
+		Input:          a.redact(event.text),
+	}
+	if entry.Identity == "" {
+		entry.Identity = "anonymous"
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("failed to encode audit entry: %v", err)
+		return
+	}
+	data = append(data, '\n')
+	if err := a.rotate(event.time, int64(len(data))); err != nil {
+		log.Printf("failed to open audit log file: %v", err)
+		return
+	}
+	n, err := a.file.Write(data)
+	a.fileSize += int64(n)
+	if err != nil {
+		log.Printf("failed to write audit entry: %v", err)
+	}
+}
+
+// rotate 在日期变化或文件超过大小上限时切换到新文件：audit-YYYYMMDD.jsonl、audit-YYYYMMDD.1.jsonl……
+func (a *auditLogger) rotate(now time.Time, next int64) error {
+	day := now.UTC().Format("20060102")
+	if a.file != nil && a.fileDay == day && (a.maxBytes <= 0 || a.fileSize+next <= a.maxBytes || a.fileSize == 0) {
+		return nil
+	}
+	if a.file != nil {
+		a.file.Close()
+		a.file = nil
+	}
+	for i := 0; ; i++ {
+		name := "audit-" + day + ".jsonl"
+		if i > 0 {
+			name = fmt.Sprintf("audit-%s.%d.jsonl", day, i)
+		}
+		path := filepath.Join(a.dir, name)
+		info, err := os.Stat(path)
+		if err == nil && a.maxBytes > 0 && info.Size()+next > a.maxBytes && info.Size() > 0 {
+			continue
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return err
+		}
+		a.file, a.fileDay, a.fileSize = file, day, 0
+		if info != nil {
+			a.fileSize = info.Size()
+		}
+		break
+	}
+	a.prune(now)
+	return nil
+}
+
+// prune 删除修改时间早于保留期限的审计文件；保留期限为 0 表示永久保留。
+func (a *auditLogger) prune(now time.Time) {
+	if a.retention <= 0 {
+		return
+	}
+	paths, err := filepath.Glob(filepath.Join(a.dir, "audit-*.jsonl"))
+	if err != nil {
+		return
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if a.file != nil && path == a.file.Name() {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || now.Sub(info.ModTime()) <= a.retention {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("failed to remove expired audit log %s: %v", path, err)
+		} else {
+			log.Printf("removed expired audit log %s", path)
+		}
+	}
+}
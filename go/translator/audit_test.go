@@ -0,0 +1,130 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"doubao/mockupstream"
+)
+
+func TestParseAuditRedaction(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{value: "hash", want: []string{AuditRedactHash}},
+		{value: "None", want: []string{AuditRedactNone}},
+		{value: "mask, truncate", want: []string{AuditRedactMask, AuditRedactTruncate}},
+		{value: "", wantErr: true},
+		{value: "hash,mask", wantErr: true},
+		{value: "none,truncate", wantErr: true},
+		{value: "encrypt", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseAuditRedaction(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("modes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuditRedact(t *testing.T) {
+	text := "mail a@b.cn about the 这份合同"
+	tests := []struct {
+		redaction []string
+		want      auditText
+	}{
+		{redaction: []string{AuditRedactHash}, want: auditText{}},
+		{redaction: []string{AuditRedactNone}, want: auditText{Text: text}},
+		{redaction: []string{AuditRedactMask}, want: auditText{Text: "mail [EMAIL] about the 这份合同", Masked: true}},
+		{redaction: []string{AuditRedactTruncate}, want: auditText{Text: "mail a@b.c", Truncated: true}},
+		{redaction: []string{AuditRedactMask, AuditRedactTruncate}, want: auditText{Text: "mail [EMAI", Masked: true, Truncated: true}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.redaction, ","), func(t *testing.T) {
+			got := (&auditLogger{redaction: tt.redaction, truncate: 10}).redact(text)
+			tt.want.Chars = len([]rune(text))
+			tt.want.SHA256 = got.SHA256
+			if got != tt.want {
+				t.Errorf("redact = %+v, want %+v", got, tt.want)
+			}
+			if len(got.SHA256) != 64 {
+				t.Errorf("sha256 = %q", got.SHA256)
+			}
+		})
+	}
+}
+
+func TestAuditRotateAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	expired := filepath.Join(dir, "audit-20250101.jsonl")
+	if err := os.WriteFile(expired, []byte("{}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(expired, now.AddDate(0, 0, -100), now.AddDate(0, 0, -100)); err != nil {
+		t.Fatal(err)
+	}
+
+	a := &auditLogger{dir: dir, redaction: []string{AuditRedactHash}, retention: 90 * 24 * time.Hour, maxBytes: 1}
+	for i := 0; i < 3; i++ {
+		a.write(auditEvent{time: now, model: "m", target: "en", text: "hello"})
+	}
+	a.write(auditEvent{time: now.Add(24 * time.Hour), model: "m", target: "en", text: "hello"})
+	a.file.Close()
+
+	names, _ := filepath.Glob(filepath.Join(dir, "audit-*.jsonl"))
+	for i := range names {
+		names[i] = filepath.Base(names[i])
+	}
+	// maxBytes 小于一条记录时每个文件只写一条；过期文件在打开新文件时被清理。
+	want := []string{"audit-20260301.1.jsonl", "audit-20260301.2.jsonl", "audit-20260301.jsonl", "audit-20260302.jsonl"}
+	if !slices.Equal(names, want) {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+}
+
+func TestAuditLogUpstreamRequests(t *testing.T) {
+	previous := CONFIG.AuditRedaction
+	defer func() { CONFIG.AuditRedaction = previous }()
+	CONFIG.AuditRedaction = "mask"
+
+	dir := t.TempDir()
+	handler, _ := newMockHandler(t, mockupstream.Options{})
+	if err := handler.OpenAuditLog(dir); err != nil {
+		t.Fatal(err)
+	}
+	if rec := serveJSON(handler, "/v1/chat/completions", chatBody("m", "Email a@b.cn", false)); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	handler.Close()
+
+	names, _ := filepath.Glob(filepath.Join(dir, "audit-*.jsonl"))
+	if len(names) != 1 {
+		t.Fatalf("audit files = %v", names)
+	}
+	data, err := os.ReadFile(names[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry auditEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	if entry.Model != "m" || entry.TargetLanguage != "ja" || entry.Endpoint != "/v1/chat/completions" ||
+		entry.Input.Text != "Email [EMAIL]" || !entry.Input.Masked || !strings.HasPrefix(entry.Identity, "key:") {
+		t.Errorf("entry = %+v", entry)
+	}
+}
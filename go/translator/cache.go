@@ -0,0 +1,291 @@
+package translator
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 译文缓存：缓存非流式请求校验通过的上游响应，键为调用方身份与发往上游的完整 payload（已替换占位符、解析模型别名）
+// 的 SHA-256。按身份隔离，命中缓存不会绕过各调用方自己的上游鉴权结果。命中时不请求上游，响应中不带 usage，
+// 台账按 0 token 记录。缓存只保存在内存中，按最近使用淘汰；容量与有效期可通过管理接口调整。
+
+type cacheSettings struct {
+	MaxEntries int   `json:"max_entries"`
+	TTLSeconds int64 `json:"ttl_seconds"`
+}
+
+type cacheEntry struct {
+	key            string
+	identity       string
+	model          string
+	targetLanguage string
+	body           []byte
+	created        time.Time
+	expires        time.Time
+	hits           int
+}
+
+// cacheEntryView 为管理接口返回的缓存条目，不含译文内容。
+type cacheEntryView struct {
+	Key            string     `json:"key"`
+	Identity       string     `json:"identity"`
+	Model          string     `json:"model"`
+	TargetLanguage string     `json:"target_language"`
+	Bytes          int        `json:"bytes"`
+	Hits           int        `json:"hits"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+type responseCache struct {
+	mu       sync.Mutex
+	settings cacheSettings
+	// order 中最近使用的条目在前。
+	order   *list.List
+	entries map[string]*list.Element
+	hits    int64
+	misses  int64
+}
+
+func newResponseCache(settings cacheSettings) *responseCache {
+	return &responseCache{settings: settings, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+func cacheSettingsFromConfig() cacheSettings {
+	return cacheSettings{MaxEntries: CONFIG.ResponseCacheMaxEntries, TTLSeconds: int64(CONFIG.ResponseCacheTTL / time.Second)}
+}
+
+// key 返回请求的缓存键；缓存关闭或为流式请求时返回空字符串。payload.Model 应已解析别名。
+func (c *responseCache) key(ctx context.Context, payload doubaoRequest) string {
+	c.mu.Lock()
+	enabled := c.settings.MaxEntries > 0
+	c.mu.Unlock()
+	if !enabled || payload.Stream {
+		return ""
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return ""
+	}
+	identity, _ := IdentityFromContext(ctx)
+	sum := sha256.Sum256(append([]byte(identity.Name+"\x00"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *responseCache) get(key string, now time.Time) ([]byte, bool) {
+	if key == "" {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if ok && element.Value.(*cacheEntry).expired(now) {
+		c.remove(element)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	entry.hits++
+	c.hits++
+	c.order.MoveToFront(element)
+	return entry.body, true
+}
+
+func (e *cacheEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// put 保存一条校验通过的上游响应，去掉 usage 后缓存，命中时不会重复计费。
+func (c *responseCache) put(ctx context.Context, key string, payload doubaoRequest, body []byte, now time.Time) {
+	if key == "" {
+		return
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return
+	}
+	delete(raw, "usage")
+	stripped, err := json.Marshal(raw)
+	if err != nil {
+		return
+	}
+	identity, _ := IdentityFromContext(ctx)
+	entry := &cacheEntry{key: key, identity: identity.Name, model: payload.Model, body: stripped, created: now}
+	if len(payload.Input) > 0 && len(payload.Input[0].Content) > 0 {
+		entry.targetLanguage = payload.Input[0].Content[0].TranslationOptions.TargetLanguage
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.settings.MaxEntries <= 0 {
+		return
+	}
+	if c.settings.TTLSeconds > 0 {
+		entry.expires = now.Add(time.Duration(c.settings.TTLSeconds) * time.Second)
+	}
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	c.trim()
+}
+
+// trim 按最近使用淘汰超出容量的条目。
+func (c *responseCache) trim() {
+	for c.order.Len() > c.settings.MaxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *responseCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*cacheEntry).key)
+}
+
+// purge 删除 key 对应的条目，key 为空时清空缓存；返回删除的条目数。
+func (c *responseCache) purge(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key == "" {
+		n := c.order.Len()
+		c.order.Init()
+		c.entries = map[string]*list.Element{}
+		return n
+	}
+	element, ok := c.entries[key]
+	if !ok {
+		return 0
+	}
+	c.remove(element)
+	return 1
+}
+
+func (c *responseCache) current() cacheSettings {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.settings
+}
+
+func (c *responseCache) configure(settings cacheSettings) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settings = settings
+	c.trim()
+}
+
+type cacheResponse struct {
+	Object   string           `json:"object"`
+	Settings cacheSettings    `json:"settings"`
+	Entries  int              `json:"entries"`
+	Hits     int64            `json:"hits"`
+	Misses   int64            `json:"misses"`
+	Data     []cacheEntryView `json:"data"`
+}
+
+func (c *responseCache) snapshot(now time.Time) cacheResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp := cacheResponse{Object: "list", Settings: c.settings, Hits: c.hits, Misses: c.misses, Data: []cacheEntryView{}}
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		entry := element.Value.(*cacheEntry)
+		if entry.expired(now) {
+			continue
+		}
+		view := cacheEntryView{
+			Key:            entry.key,
+			Identity:       entry.identity,
+			Model:          entry.model,
+			TargetLanguage: entry.targetLanguage,
+			Bytes:          len(entry.body),
+			Hits:           entry.hits,
+			CreatedAt:      entry.created,
+		}
+		if !entry.expires.IsZero() {
+			expires := entry.expires
+			view.ExpiresAt = &expires
+		}
+		resp.Data = append(resp.Data, view)
+	}
+	resp.Entries = len(resp.Data)
+	return resp
+}
+
+func cachedResponse(body []byte) *http.Response {
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+type cachePatch struct {
+	MaxEntries *int   `json:"max_entries"`
+	TTLSeconds *int64 `json:"ttl_seconds"`
+}
+
+// handleAdminCache 管理译文缓存：GET /admin/cache 查看设置、命中统计与条目（不含译文），
+// PATCH /admin/cache 调整 max_entries（0 表示关闭）与 ttl_seconds（0 表示不过期），
+// DELETE /admin/cache 清空，DELETE /admin/cache/{key} 删除单条。
+func (s *Handler) handleAdminCache(ctx context.Context, w http.ResponseWriter, r *http.Request, key string) {
+	switch {
+	case key == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.cache.snapshot(time.Now()))
+	case key == "" && r.Method == http.MethodPatch:
+		var patch cachePatch
+		if err := readAdminJSON(r, &patch); err != nil {
+			writeAPIError(ctx, w, err)
+			return
+		}
+		if patch.MaxEntries != nil && *patch.MaxEntries < 0 {
+			writeAPIError(ctx, w, newAPIError("invalidValue", "max_entries", *patch.MaxEntries).withParam("max_entries"))
+			return
+		}
+		if patch.TTLSeconds != nil && *patch.TTLSeconds < 0 {
+			writeAPIError(ctx, w, newAPIError("invalidValue", "ttl_seconds", *patch.TTLSeconds).withParam("ttl_seconds"))
+			return
+		}
+		state := s.adminSnapshot()
+		before := *state.Cache
+		after := before
+		if patch.MaxEntries != nil {
+			after.MaxEntries = *patch.MaxEntries
+		}
+		if patch.TTLSeconds != nil {
+			after.TTLSeconds = *patch.TTLSeconds
+		}
+		state.Cache = &after
+		if !s.commitAdminState(ctx, w, state) {
+			return
+		}
+		s.cache.configure(after)
+		s.auditAdmin(r, "cache.update", before, after)
+		writeJSON(w, http.StatusOK, after)
+	case r.Method == http.MethodDelete:
+		purged := s.cache.purge(key)
+		if key != "" && purged == 0 {
+			writeAPIError(ctx, w, newAPIError("notFound"))
+			return
+		}
+		action := "cache.purge"
+		var target interface{}
+		if key != "" {
+			action, target = "cache.delete", map[string]string{"key": key}
+		}
+		s.auditAdmin(r, action, target, map[string]int{"purged": purged})
+		writeJSON(w, http.StatusOK, map[string]int{"purged": purged})
+	default:
+		writeAPIError(ctx, w, newAPIError("notFound"))
+	}
+}
@@ -0,0 +1,229 @@
+package translator
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// 供其他 Go 服务内嵌使用的翻译客户端：与 HTTP/gRPC 接口共用语言解析、同语种直通、
+// 上游请求与流式解析逻辑，只是把结果以类型化结构返回，而不是渲染成 OpenAI 响应。
+
+// Client 的零值即可使用；APIKey 与 Model 也可以通过 NewClient 设置。
+// 字段应在首次调用前设置完毕，之后 Client 可被多个 goroutine 并发使用。
+type Client struct {
+	// APIKey 为方舟 API Key，以 Bearer 方式发送给上游。
+	APIKey string
+	// Model 为默认模型（推理接入点 ID），可被 Options.Model 覆盖。
+	Model string
+	// BaseURL 为上游 Responses 接口地址，为空时使用 CONFIG.DoubaoBaseURL。
+	BaseURL string
+	// HTTPClient 为空时使用与服务端相同的客户端：非流式 60 秒超时，流式仅限制等待响应头的时间。
+	HTTPClient *http.Client
+
+	once sync.Once
+	base *Handler
+}
+
+func NewClient(apiKey, model string) *Client {
+	return &Client{APIKey: apiKey, Model: model}
+}
+
+// Options 为单次翻译的参数；零值表示自动识别源语言并翻译为 CONFIG.DefaultTargetLanguage。
+// 语言字段支持与 HTTP 接口相同的写法（豆包编码、中英文名称、BCP-47 标签）。
+type Options struct {
+	Model          string
+	SourceLanguage string
+	TargetLanguage string
+	// SkipSameLanguage 为 true 时强制开启同语种直通，否则沿用 CONFIG.SkipSameLanguage。
+	SkipSameLanguage bool
+}
+
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type Result struct {
+	Text  string `json:"text"`
+	Model string `json:"model"`
+	// SourceLanguage 为调用方指定并解析后的源语言编码，自动识别时为空。
+	SourceLanguage         string `json:"source_language,omitempty"`
+	TargetLanguage         string `json:"target_language"`
+	DetectedSourceLanguage string `json:"detected_source_language,omitempty"`
+	Passthrough            bool   `json:"passthrough,omitempty"`
+	Usage                  Usage  `json:"usage"`
+}
+
+// Error 是 Client 返回的错误，字段与 HTTP 接口的 OpenAI 错误对象一致，
+// 文案使用 CONFIG.DefaultLocale。上下文被取消时直接返回 ctx.Err()。
+type Error struct {
+	Status     int
+	Type       string
+	Code       string
+	Param      string
+	Message    string
+	RetryAfter string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func newClientError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	apiErr := toAPIError(err)
+	obj := apiErr.object(CONFIG.DefaultLocale)
+	clientErr := &Error{Status: apiErr.status(), Type: obj.Type, Message: obj.Message}
+	if obj.Code != nil {
+		clientErr.Code = *obj.Code
+	}
+	if obj.Param != nil {
+		clientErr.Param = *obj.Param
+	}
+	clientErr.RetryAfter = apiErr.Header.Get("Retry-After")
+	return clientErr
+}
+
+// handler 返回按 BaseURL/HTTPClient 定制的 Handler 副本；Client 不创建 tracer，也不导出 span。
+func (c *Client) handler() *Handler {
+	c.once.Do(func() {
+		c.base = newHandler(nil)
+	})
+	h := *c.base
+	if c.BaseURL != "" {
+		h.baseURL = c.BaseURL
+	}
+	if c.HTTPClient != nil {
+		h.client = c.HTTPClient
+		h.streamClient = c.HTTPClient
+	}
+	return &h
+}
+
+func (c *Client) prepare(ctx context.Context, text string, opts *Options) (*Handler, translationRequest, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	model := opts.Model
+	if model == "" {
+		model = c.Model
+	}
+	if model == "" {
+		return nil, translationRequest{}, newAPIError("noModel")
+	}
+	if err := validateSegmentText(text, "text"); err != nil {
+		return nil, translationRequest{}, err
+	}
+	h := c.handler()
+	options, overrides, err := h.resolveLanguageFields(ctx, opts.SourceLanguage, opts.TargetLanguage, opts.SkipSameLanguage)
+	if err != nil {
+		return nil, translationRequest{}, err
+	}
+	return h, translationRequest{
+		Auth:      "Bearer " + c.APIKey,
+		Model:     model,
+		Text:      text,
+		Options:   options,
+		Overrides: overrides,
+	}, nil
+}
+
+func newResult(req translationRequest, result translationResult) *Result {
+	out := &Result{
+		Text:                   result.Text,
+		Model:                  req.Model,
+		TargetLanguage:         req.Options.TargetLanguage,
+		DetectedSourceLanguage: result.DetectedSourceLanguage,
+		Passthrough:            result.Passthrough,
+		Usage: Usage{
+			InputTokens:  result.InputTokens,
+			OutputTokens: result.OutputTokens,
+			TotalTokens:  result.TotalTokens,
+		},
+	}
+	if req.Options.SourceLanguage != nil {
+		out.SourceLanguage = *req.Options.SourceLanguage
+	}
+	return out
+}
+
+// Translate 以非流式请求翻译一段文本。
+func (c *Client) Translate(ctx context.Context, text string, opts *Options) (*Result, error) {
+	h, req, err := c.prepare(ctx, text, opts)
+	if err != nil {
+		return nil, newClientError(ctx, err)
+	}
+	result, err := h.translateText(ctx, req, nil)
+	if err != nil {
+		return nil, newClientError(ctx, err)
+	}
+	return newResult(req, result), nil
+}
+
+// TranslateStream 以流式请求翻译一段文本。参数错误立即返回；上游错误由 Stream.Next 返回。
+// 调用方读完或放弃读取后都应调用 Close 释放上游连接。
+func (c *Client) TranslateStream(ctx context.Context, text string, opts *Options) (*Stream, error) {
+	h, req, err := c.prepare(ctx, text, opts)
+	if err != nil {
+		return nil, newClientError(ctx, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stream := &Stream{deltas: make(chan string), cancel: cancel}
+	go func() {
+		defer close(stream.deltas)
+		result, err := h.translateText(ctx, req, func(delta string) error {
+			select {
+			case stream.deltas <- delta:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			stream.err = newClientError(ctx, err)
+			return
+		}
+		stream.result = newResult(req, result)
+	}()
+	return stream, nil
+}
+
+// Stream 按到达顺序返回增量译文。
+type Stream struct {
+	deltas chan string
+	cancel context.CancelFunc
+	// result 与 err 在 deltas 关闭前写入，Next 读到关闭后即可安全读取。
+	result *Result
+	err    error
+}
+
+// Next 返回下一段增量；正常结束时返回 io.EOF，此后可通过 Result 取得完整结果。
+func (st *Stream) Next() (string, error) {
+	delta, ok := <-st.deltas
+	if ok {
+		return delta, nil
+	}
+	if st.err != nil {
+		return "", st.err
+	}
+	return "", io.EOF
+}
+
+// Result 返回完整结果，只能在 Next 返回 io.EOF 之后调用。
+func (st *Stream) Result() *Result {
+	return st.result
+}
+
+// Close 取消尚未完成的上游请求并等待后台 goroutine 退出，可重复调用。
+func (st *Stream) Close() error {
+	st.cancel()
+	for range st.deltas {
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package translator
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config 为 Handler 与 Client 共享的运行参数，默认值见 CONFIG，可由 LoadConfigFromEnv 从环境变量覆盖。
+// CONFIG 只应在启动阶段（创建 Handler 或 Client 之前）修改，之后视为只读；
+// 运行期间需要整体替换的数据（如语言注册表）通过 atomic.Pointer 换入。
+type Config struct {
+	DoubaoBaseURL         string
+	DefaultTargetLanguage string
+	MaxRequestSize        int64
+	ServiceName           string
+	OTLPTracesEndpoint    string
+	OTLPHeaders           map[string]string
+	SkipSameLanguage      bool
+	LanguageRegistryFile  string
+	DefaultLocale         string
+	StrictValidation      bool
+	MaxInputChars         int
+
+	StreamHeartbeatInterval time.Duration
+	StreamFirstTokenTimeout time.Duration
+	StreamIdleTimeout       time.Duration
+
+	GRPCPort string
+
+	// HTTPSPolicy 为 reject、redirect 或 allow，见 https.go；TrustedProxies 为允许设置 X-Forwarded-Proto 等转发头的代理网段。
+	HTTPSPolicy    string
+	TrustedProxies []*net.IPNet
+	TLSCertFile    string
+	TLSKeyFile     string
+	// TLSClientCAFile 设置后开启 mTLS：TLSClientAuth 为 optional（提供证书时校验）或 require（必须提供），
+	// 证书到身份的映射见 identity.go 与 TLSClientIdentitiesFile。
+	TLSClientCAFile         string
+	TLSClientAuth           string
+	TLSClientIdentitiesFile string
+
+	// IPAllowList / IPDenyList 为逗号分隔的 CIDR 列表，由 LoadIPFilter 解析，见 ipfilter.go。
+	IPAllowList string
+	IPDenyList  string
+
+	// Dashboard 控制是否在 /ui/ 提供内置控制台（默认关闭），见 dashboard.go。
+	Dashboard bool
+
+	// AuditLogDir 开启翻译审计日志；AuditRedaction 为脱敏策略（none、hash、truncate、mask，truncate 与 mask 可组合），
+	// AuditRetention 为文件保留时长（0 表示永久），见 audit.go。
+	AuditLogDir        string
+	AuditRedaction     string
+	AuditTruncateChars int
+	AuditRetention     time.Duration
+	AuditMaxFileBytes  int64
+
+	// PIIMasking / PIIReject 为逗号分隔的 PII 类别（或 all），分别在发往上游前替换为占位符、直接拒绝请求；
+	// PIIPatternsFile 为自定义检测器，均由 LoadPIIPolicy 解析，见 pii.go。
+	PIIMasking      string
+	PIIReject       string
+	PIIPatternsFile string
+	// ProtectedSpans 为发往上游前替换为占位符的不翻译片段类别（variable、tag、url、notranslate），见 protect.go；
+	// PlaceholderRetries 为非流式请求的译文占位符不一致时重新请求上游的次数。
+	ProtectedSpans     []string
+	PlaceholderRetries int
+
+	// CORSAllowedOrigins 为允许跨域调用的 Origin（支持 "*" 与 "https://*.example.com" 形式的通配），为空时关闭 CORS，见 cors.go。
+	// CORSAllowedHeaders 为 "*" 时回显预检请求声明的请求头。
+	CORSAllowedOrigins   []string
+	CORSAllowedHeaders   []string
+	CORSAllowedMethods   []string
+	CORSExposeHeaders    []string
+	CORSAllowCredentials bool
+	CORSMaxAge           time.Duration
+
+	// AdminAPIKey 为 /admin/ 管理接口的独立密钥，为空时关闭管理接口，见 admin.go。
+	AdminAPIKey string
+	// AdminStateFile 保存管理接口做出的运行时修改，AdminAuditFile 为修改记录（JSONL）。
+	AdminStateFile string
+	AdminAuditFile string
+	// UsageLedgerFile 开启用量台账，PriceTableFile 为计算费用的价格表，见 ledger.go。
+	UsageLedgerFile string
+	PriceTableFile  string
+
+	// ResponseCacheMaxEntries 为非流式译文缓存的条目上限（0 表示关闭），ResponseCacheTTL 为每条的有效期，
+	// 均可通过管理接口调整，见 cache.go。
+	ResponseCacheMaxEntries int
+	ResponseCacheTTL        time.Duration
+
+	// UpstreamRecordFile / UpstreamReplayFile 分别开启上游流量录制与回放，见 recording.go。
+	UpstreamRecordFile string
+	UpstreamReplayFile string
+}
+
+var CONFIG = Config{
+	DoubaoBaseURL:         "https://ark.cn-beijing.volces.com/api/v3/responses",
+	DefaultTargetLanguage: "zh",
+	MaxRequestSize:        24 * 1024,
+	ServiceName:           "doubao-translation-proxy",
+	DefaultLocale:         "zh",
+	MaxInputChars:         8000,
+
+	StreamHeartbeatInterval: 15 * time.Second,
+	StreamFirstTokenTimeout: 120 * time.Second,
+	StreamIdleTimeout:       60 * time.Second,
+	HTTPSPolicy:             HTTPSPolicyAllow,
+	TLSClientAuth:           ClientAuthOptional,
+	TrustedProxies:          defaultTrustedProxies,
+	CORSAllowedHeaders:      []string{"Authorization", "Content-Type", "Accept-Language", "traceparent"},
+	CORSAllowedMethods:      []string{http.MethodGet, http.MethodPost},
+	CORSExposeHeaders:       []string{"Retry-After"},
+	CORSMaxAge:              10 * time.Minute,
+	ResponseCacheTTL:        time.Hour,
+	AuditRedaction:          AuditRedactHash,
+	AuditTruncateChars:      200,
+	AuditRetention:          90 * 24 * time.Hour,
+	AuditMaxFileBytes:       100 << 20,
+}
+
+// LoadConfigFromEnv 使用环境变量覆盖 CONFIG 中的默认值。
+func LoadConfigFromEnv() {
+	if v := os.Getenv("OTEL_SERVICE_NAME"); v != "" {
+		CONFIG.ServiceName = v
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); v != "" {
+		CONFIG.OTLPTracesEndpoint = v
+	} else if v := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); v != "" {
+		CONFIG.OTLPTracesEndpoint = strings.TrimRight(v, "/") + "/v1/traces"
+	}
+	if v := os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"); v != "" {
+		CONFIG.OTLPHeaders = parseOTLPHeaders(v)
+	}
+	if v := os.Getenv("SKIP_SAME_LANGUAGE"); v != "" {
+		CONFIG.SkipSameLanguage = parseStreamFlag(v)
+	}
+	CONFIG.LanguageRegistryFile = os.Getenv("LANGUAGE_REGISTRY_FILE")
+	if v := os.Getenv("DEFAULT_LOCALE"); v != "" {
+		CONFIG.DefaultLocale = negotiateLocale(v)
+	}
+	if v := os.Getenv("STRICT_VALIDATION"); v != "" {
+		CONFIG.StrictValidation = parseStreamFlag(v)
+	}
+	if v := os.Getenv("MAX_INPUT_CHARS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil {
+			CONFIG.MaxInputChars = parsed
+		}
+	}
+	if v, ok := durationFromEnv("STREAM_HEARTBEAT_INTERVAL"); ok {
+		CONFIG.StreamHeartbeatInterval = v
+	}
+	if v, ok := durationFromEnv("STREAM_FIRST_TOKEN_TIMEOUT"); ok {
+		CONFIG.StreamFirstTokenTimeout = v
+	}
+	if v, ok := durationFromEnv("STREAM_IDLE_TIMEOUT"); ok {
+		CONFIG.StreamIdleTimeout = v
+	}
+	CONFIG.GRPCPort = os.Getenv("GRPC_PORT")
+	if v := os.Getenv("DOUBAO_BASE_URL"); v != "" {
+		CONFIG.DoubaoBaseURL = v
+	}
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("HTTPS_POLICY"))); v != "" {
+		switch v {
+		case HTTPSPolicyReject, HTTPSPolicyRedirect, HTTPSPolicyAllow:
+			CONFIG.HTTPSPolicy = v
+		default:
+			log.Printf("ignoring invalid HTTPS_POLICY=%q: want reject, redirect or allow", v)
+		}
+	}
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		if nets, err := parseCIDRs(v); err != nil {
+			log.Printf("ignoring invalid TRUSTED_PROXIES=%q: %v", v, err)
+		} else {
+			CONFIG.TrustedProxies = nets
+		}
+	}
+	CONFIG.TLSCertFile = os.Getenv("TLS_CERT_FILE")
+	CONFIG.TLSKeyFile = os.Getenv("TLS_KEY_FILE")
+	CONFIG.TLSClientCAFile = os.Getenv("TLS_CLIENT_CA_FILE")
+	if v := strings.ToLower(strings.TrimSpace(os.Getenv("TLS_CLIENT_AUTH"))); v != "" {
+		if v == ClientAuthOptional || v == ClientAuthRequire {
+			CONFIG.TLSClientAuth = v
+		} else {
+			log.Printf("ignoring invalid TLS_CLIENT_AUTH=%q: want optional or require", v)
+		}
+	}
+	CONFIG.TLSClientIdentitiesFile = os.Getenv("TLS_CLIENT_IDENTITIES_FILE")
+	CONFIG.IPAllowList = os.Getenv("IP_ALLOWLIST")
+	CONFIG.IPDenyList = os.Getenv("IP_DENYLIST")
+	if v := os.Getenv("DASHBOARD"); v != "" {
+		CONFIG.Dashboard = parseStreamFlag(v)
+	}
+	CONFIG.AuditLogDir = os.Getenv("AUDIT_LOG_DIR")
+	if v := os.Getenv("AUDIT_REDACTION"); v != "" {
+		CONFIG.AuditRedaction = v
+	}
+	if v := os.Getenv("AUDIT_TRUNCATE_CHARS"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			CONFIG.AuditTruncateChars = parsed
+		} else {
+			log.Printf("ignoring invalid AUDIT_TRUNCATE_CHARS=%q", v)
+		}
+	}
+	if v, ok := durationFromEnv("AUDIT_RETENTION"); ok {
+		CONFIG.AuditRetention = v
+	}
+	if v := os.Getenv("AUDIT_MAX_FILE_BYTES"); v != "" {
+		if parsed, err := strconv.ParseInt(v, 10, 64); err == nil && parsed >= 0 {
+			CONFIG.AuditMaxFileBytes = parsed
+		} else {
+			log.Printf("ignoring invalid AUDIT_MAX_FILE_BYTES=%q", v)
+		}
+	}
+	CONFIG.PIIMasking = os.Getenv("PII_MASKING")
+	CONFIG.PIIReject = os.Getenv("PII_REJECT")
+	CONFIG.PIIPatternsFile = os.Getenv("PII_PATTERNS_FILE")
+	if v := os.Getenv("PROTECTED_SPANS"); v != "" {
+		classes, invalid := parseProtectedSpans(v)
+		for _, class := range invalid {
+			log.Printf("ignoring invalid PROTECTED_SPANS entry %q: want variable, tag, url, notranslate or all", class)
+		}
+		CONFIG.ProtectedSpans = classes
+	}
+	if v := os.Getenv("PLACEHOLDER_RETRIES"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			CONFIG.PlaceholderRetries = parsed
+		} else {
+			log.Printf("ignoring invalid PLACEHOLDER_RETRIES=%q", v)
+		}
+	}
+	CONFIG.CORSAllowedOrigins = splitList(os.Getenv("CORS_ALLOWED_ORIGINS"))
+	if v := os.Getenv("CORS_ALLOWED_HEADERS"); v != "" {
+		CONFIG.CORSAllowedHeaders = splitList(v)
+	}
+	if v := os.Getenv("CORS_ALLOWED_METHODS"); v != "" {
+		CONFIG.CORSAllowedMethods = splitList(strings.ToUpper(v))
+	}
+	if v := os.Getenv("CORS_EXPOSE_HEADERS"); v != "" {
+		CONFIG.CORSExposeHeaders = splitList(v)
+	}
+	if v := os.Getenv("CORS_ALLOW_CREDENTIALS"); v != "" {
+		CONFIG.CORSAllowCredentials = parseStreamFlag(v)
+	}
+	if v, ok := durationFromEnv("CORS_MAX_AGE"); ok {
+		CONFIG.CORSMaxAge = v
+	}
+	CONFIG.AdminAPIKey = os.Getenv("ADMIN_API_KEY")
+	CONFIG.AdminStateFile = os.Getenv("ADMIN_STATE_FILE")
+	CONFIG.AdminAuditFile = os.Getenv("ADMIN_AUDIT_FILE")
+	CONFIG.UsageLedgerFile = os.Getenv("USAGE_LEDGER_FILE")
+	CONFIG.PriceTableFile = os.Getenv("PRICE_TABLE_FILE")
+	if v := os.Getenv("RESPONSE_CACHE_MAX_ENTRIES"); v != "" {
+		if parsed, err := strconv.Atoi(v); err == nil && parsed >= 0 {
+			CONFIG.ResponseCacheMaxEntries = parsed
+		} else {
+			log.Printf("ignoring invalid RESPONSE_CACHE_MAX_ENTRIES=%q", v)
+		}
+	}
+	if v, ok := durationFromEnv("RESPONSE_CACHE_TTL"); ok {
+		CONFIG.ResponseCacheTTL = v
+	}
+	CONFIG.UpstreamRecordFile = os.Getenv("UPSTREAM_RECORD_FILE")
+	CONFIG.UpstreamReplayFile = os.Getenv("UPSTREAM_REPLAY_FILE")
+}
+
+// durationFromEnv 接受 Go 时长格式（如 "30s"）或纯数字秒数，"0" 表示关闭。
+func durationFromEnv(name string) (time.Duration, bool) {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("ignoring invalid %s=%q: %v", name, v, err)
+		return 0, false
+	}
+	return parsed, true
+}
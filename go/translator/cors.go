@@ -0,0 +1,99 @@
+package translator
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// CORS：浏览器直接调用代理时，为 CONFIG.CORSAllowedOrigins 中的 Origin 写入 Access-Control-* 响应头。
+// 响应头在路由之前设置，因此错误响应与 SSE 流式响应同样带有 CORS 头，浏览器端可以读取错误内容。
+// 未配置允许的 Origin 时不做任何处理，OPTIONS 请求仍返回 404；未知路径的预检同样返回 404。
+
+// originAllowed 判断 Origin 是否匹配允许列表："*" 匹配任意来源，其余按 path.Match 通配（如 "https://*.example.com"）。
+func originAllowed(origin string) bool {
+	for _, pattern := range CONFIG.CORSAllowedOrigins {
+		if pattern == "*" || strings.EqualFold(pattern, origin) {
+			return true
+		}
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(origin)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// websocketOriginAllowed 检查 WebSocket 握手的 Origin：浏览器跨站发起的握手不受 CORS 约束，
+// 因此只接受同源或 CORS 允许列表中的来源；不带 Origin 的非浏览器客户端不受限制。
+func websocketOriginAllowed(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return originAllowed(origin)
+}
+
+// handleCORS 写入 CORS 响应头；对于预检请求直接以 204 应答并返回 true。
+// 始终回显具体的 Origin（而不是 "*"），这样允许携带凭据时也符合规范。
+func handleCORS(w http.ResponseWriter, r *http.Request) bool {
+	if len(CONFIG.CORSAllowedOrigins) == 0 {
+		return false
+	}
+	header := w.Header()
+	header.Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	_, known := routeMethods[r.URL.Path]
+	preflight := known && r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+	if origin == "" || !originAllowed(origin) {
+		if preflight {
+			// 不带 CORS 头的 204 会让浏览器拒绝后续请求，同时不向未授权来源暴露任何信息。
+			w.WriteHeader(http.StatusNoContent)
+			return true
+		}
+		return false
+	}
+
+	header.Set("Access-Control-Allow-Origin", origin)
+	if CONFIG.CORSAllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		if len(CONFIG.CORSExposeHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(CONFIG.CORSExposeHeaders, ", "))
+		}
+		return false
+	}
+
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	header.Set("Access-Control-Allow-Methods", strings.Join(CONFIG.CORSAllowedMethods, ", "))
+	if len(CONFIG.CORSAllowedHeaders) == 1 && CONFIG.CORSAllowedHeaders[0] == "*" {
+		// 允许任意请求头时回显预检中声明的请求头，携带凭据时浏览器不接受字面量 "*"。
+		if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+	} else if len(CONFIG.CORSAllowedHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(CONFIG.CORSAllowedHeaders, ", "))
+	}
+	if CONFIG.CORSMaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(CONFIG.CORSMaxAge.Seconds())))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// splitList 解析逗号分隔的环境变量，去掉空白与空项。
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,171 @@
+package translator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// withCORS 开启 CORS 并在测试结束后恢复 CONFIG。
+func withCORS(t *testing.T, origins ...string) {
+	t.Helper()
+	previous := CONFIG
+	t.Cleanup(func() { CONFIG = previous })
+	CONFIG.CORSAllowedOrigins = origins
+}
+
+func TestOriginAllowed(t *testing.T) {
+	withCORS(t, "https://app.example.com", "https://*.example.org", "HTTP://LOCALHOST:3000")
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{origin: "https://app.example.com", want: true},
+		{origin: "https://APP.example.com", want: true},
+		{origin: "http://app.example.com", want: false},
+		{origin: "https://app.example.com.evil.test", want: false},
+		{origin: "https://a.example.org", want: true},
+		{origin: "https://example.org", want: false},
+		{origin: "http://localhost:3000", want: true},
+		{origin: "null", want: false},
+	}
+	for _, tt := range tests {
+		if got := originAllowed(tt.origin); got != tt.want {
+			t.Errorf("originAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+
+	withCORS(t, "*")
+	if !originAllowed("https://anything.test") {
+		t.Error(`"*" does not allow every origin`)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		headers     []string
+		credentials bool
+		method      string
+		path        string
+		origin      string
+		requestHdrs string
+		wantStatus  int
+		wantHeaders map[string]string
+	}{
+		{
+			name: "allowed preflight", origins: []string{"https://app.example.com"},
+			method: http.MethodOptions, path: "/v1/chat/completions", origin: "https://app.example.com",
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":  "https://app.example.com",
+				"Access-Control-Allow-Methods": "GET, POST",
+				"Access-Control-Allow-Headers": "Authorization, Content-Type, Accept-Language, traceparent",
+				"Access-Control-Max-Age":       "600",
+				"Vary":                         "Origin",
+			},
+		},
+		{
+			name: "echo requested headers with credentials", origins: []string{"*"}, headers: []string{"*"}, credentials: true,
+			method: http.MethodOptions, path: "/v1/responses", origin: "https://other.test", requestHdrs: "authorization, x-custom",
+			wantStatus: http.StatusNoContent,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":      "https://other.test",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Allow-Headers":     "authorization, x-custom",
+			},
+		},
+		{
+			name: "disallowed origin gets no CORS headers", origins: []string{"https://app.example.com"},
+			method: http.MethodOptions, path: "/v1/chat/completions", origin: "https://evil.test",
+			wantStatus:  http.StatusNoContent,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			name: "unknown path", origins: []string{"*"},
+			method: http.MethodOptions, path: "/v1/unknown", origin: "https://app.example.com",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "cors disabled", method: http.MethodOptions, path: "/v1/chat/completions", origin: "https://app.example.com",
+			wantStatus:  http.StatusNotFound,
+			wantHeaders: map[string]string{"Access-Control-Allow-Origin": "", "Vary": ""},
+		},
+		{
+			name: "error response carries CORS headers", origins: []string{"https://app.example.com"},
+			method: http.MethodPost, path: "/v1/chat/completions", origin: "https://app.example.com",
+			wantStatus: http.StatusUnauthorized,
+			wantHeaders: map[string]string{
+				"Access-Control-Allow-Origin":   "https://app.example.com",
+				"Access-Control-Expose-Headers": "Retry-After",
+				"Access-Control-Allow-Methods":  "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withCORS(t, tt.origins...)
+			if tt.headers != nil {
+				CONFIG.CORSAllowedHeaders = tt.headers
+			}
+			CONFIG.CORSAllowCredentials = tt.credentials
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			if tt.requestHdrs != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.requestHdrs)
+			}
+			rec := httptest.NewRecorder()
+			newHandler(nil).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			for key, want := range tt.wantHeaders {
+				if got := rec.Header().Get(key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	withCORS(t, "https://app.example.com")
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{origin: "", allowed: true},
+		{origin: "https://proxy.test", allowed: true},
+		{origin: "https://app.example.com", allowed: true},
+		{origin: "https://evil.test", allowed: false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "https://proxy.test/v1/realtime/translate", nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		req.Header.Set("Connection", "Upgrade")
+		req.Header.Set("Upgrade", "websocket")
+		req.Header.Set("Sec-WebSocket-Version", "13")
+		req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := websocketOriginAllowed(req); got != tt.allowed {
+			t.Errorf("websocketOriginAllowed(%q) = %v, want %v", tt.origin, got, tt.allowed)
+		}
+		if tt.allowed {
+			continue
+		}
+		rec := httptest.NewRecorder()
+		newHandler(nil).ServeHTTP(rec, req)
+		if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), `"code":"origin_not_allowed"`) {
+			t.Errorf("origin %q: status = %d, body %s; want 403 origin_not_allowed", tt.origin, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package translator
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// 内置控制台：/ui/ 下提供打包进二进制的静态页面（翻译试用、指标、用量、进行中的请求与最近错误）。
+// 页面本身不含任何数据，翻译试用以用户填写的 API Key 调用 /v1 接口，其余页面以管理密钥调用 /admin 接口。
+
+//go:embed web
+var embeddedWeb embed.FS
+
+var dashboardFiles = func() http.Handler {
+	sub, err := fs.Sub(embeddedWeb, "web")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/ui", http.FileServerFS(sub))
+}()
+
+// serveDashboard 提供控制台静态文件；/ui 重定向到 /ui/。
+func (s *Handler) serveDashboard(w http.ResponseWriter, r *http.Request) bool {
+	if !CONFIG.Dashboard || (r.URL.Path != "/ui" && !strings.HasPrefix(r.URL.Path, "/ui/")) {
+		return false
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if r.URL.Path == "/ui" {
+		http.Redirect(w, r, "/ui/", http.StatusMovedPermanently)
+		return true
+	}
+	header := w.Header()
+	header.Set("Content-Security-Policy", "default-src 'self'; style-src 'self'; img-src 'self' data:; frame-ancestors 'none'")
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("Referrer-Policy", "no-referrer")
+	dashboardFiles.ServeHTTP(w, r)
+	return true
+}
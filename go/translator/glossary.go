@@ -0,0 +1,185 @@
+package translator
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 术语表：按目标语言配置的原文术语到固定译法的映射。发往上游前把原文中的术语替换为 [[GLOSSARY_1]] 形式的占位符，
+// 译文返回后与其他占位符一样校验次数，再替换为固定译法（见 placeholder.go）。术语区分大小写；
+// 以字母或数字开头、结尾的术语只匹配完整的词。
+
+const SpanGlossary = "glossary"
+
+type glossaryEntry struct {
+	ID             string `json:"id"`
+	Source         string `json:"source"`
+	Target         string `json:"target"`
+	TargetLanguage string `json:"target_language"`
+}
+
+type glossaryStore struct {
+	mu      sync.RWMutex
+	entries []glossaryEntry
+	// detectors 缓存按目标语言编译好的检测器，术语表变化时清空。
+	detectors map[string]*glossaryDetector
+}
+
+type glossaryDetector struct {
+	detector spanDetector
+	targets  map[string]string
+}
+
+func newGlossaryStore() *glossaryStore {
+	return &glossaryStore{detectors: map[string]*glossaryDetector{}}
+}
+
+func (g *glossaryStore) snapshot() []glossaryEntry {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return slices.Clone(g.entries)
+}
+
+func (g *glossaryStore) replace(entries []glossaryEntry) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.entries = entries
+	g.detectors = map[string]*glossaryDetector{}
+}
+
+// detectorFor 返回目标语言的术语检测器，该语言没有术语时返回 nil。
+func (g *glossaryStore) detectorFor(targetLanguage string) *glossaryDetector {
+	g.mu.RLock()
+	detector, ok := g.detectors[targetLanguage]
+	g.mu.RUnlock()
+	if ok {
+		return detector
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if detector, ok := g.detectors[targetLanguage]; ok {
+		return detector
+	}
+	targets := map[string]string{}
+	var terms []string
+	for _, entry := range g.entries {
+		if entry.TargetLanguage == targetLanguage {
+			if _, ok := targets[entry.Source]; !ok {
+				terms = append(terms, entry.Source)
+			}
+			targets[entry.Source] = entry.Target
+		}
+	}
+	if len(terms) > 0 {
+		// 较长的术语排在前面，"Acme Cloud" 优先于 "Acme"。
+		sort.SliceStable(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+		patterns := make([]string, len(terms))
+		for i, term := range terms {
+			patterns[i] = glossaryTermPattern(term)
+		}
+		detector = &glossaryDetector{
+			detector: spanDetector{class: SpanGlossary, pattern: regexp.MustCompile(strings.Join(patterns, "|"))},
+			targets:  targets,
+		}
+	}
+	g.detectors[targetLanguage] = detector
+	return detector
+}
+
+func glossaryTermPattern(term string) string {
+	pattern := regexp.QuoteMeta(term)
+	first, _ := utf8.DecodeRuneInString(term)
+	last, _ := utf8.DecodeLastRuneInString(term)
+	if first < utf8.RuneSelf && isWordRune(first) {
+		pattern = `\b` + pattern
+	}
+	if last < utf8.RuneSelf && isWordRune(last) {
+		pattern += `\b`
+	}
+	return pattern
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+type glossaryRequest struct {
+	Source         string `json:"source"`
+	Target         string `json:"target"`
+	TargetLanguage string `json:"target_language"`
+}
+
+// handleAdminGlossary 管理术语表：GET /admin/glossary 列出（可用 ?target_language= 过滤），
+// POST /admin/glossary 添加（{"source","target","target_language"}），DELETE /admin/glossary/{id} 删除。
+func (s *Handler) handleAdminGlossary(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) {
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		entries := s.glossary.snapshot()
+		if language := r.URL.Query().Get("target_language"); language != "" {
+			if resolved, err := ResolveLanguage(language); err == nil {
+				language = resolved
+			}
+			entries = slices.DeleteFunc(entries, func(e glossaryEntry) bool { return e.TargetLanguage != language })
+		}
+		writeJSON(w, http.StatusOK, listResponse[glossaryEntry]{Object: "list", Data: entries})
+	case id == "" && r.Method == http.MethodPost:
+		var req glossaryRequest
+		if err := readAdminJSON(r, &req); err != nil {
+			writeAPIError(ctx, w, err)
+			return
+		}
+		if strings.TrimSpace(req.Source) == "" {
+			writeAPIError(ctx, w, newAPIError("invalidValue", "source", req.Source).withParam("source"))
+			return
+		}
+		if strings.TrimSpace(req.Target) == "" {
+			writeAPIError(ctx, w, newAPIError("invalidValue", "target", req.Target).withParam("target"))
+			return
+		}
+		language, err := ResolveLanguage(req.TargetLanguage)
+		if err != nil {
+			writeAPIError(ctx, w, newAPIError("invalidValue", "target_language", req.TargetLanguage).withParam("target_language"))
+			return
+		}
+		entry := glossaryEntry{ID: genID("term"), Source: req.Source, Target: req.Target, TargetLanguage: language}
+		state := s.adminSnapshot()
+		if slices.ContainsFunc(state.Glossary, func(e glossaryEntry) bool {
+			return e.Source == entry.Source && e.TargetLanguage == entry.TargetLanguage
+		}) {
+			writeAPIError(ctx, w, newAPIError("adminConflict", entry.Source))
+			return
+		}
+		state.Glossary = append(state.Glossary, entry)
+		if !s.commitAdminState(ctx, w, state) {
+			return
+		}
+		s.glossary.replace(state.Glossary)
+		s.auditAdmin(r, "glossary.create", nil, entry)
+		writeJSON(w, http.StatusCreated, entry)
+	case id != "" && r.Method == http.MethodDelete:
+		state := s.adminSnapshot()
+		index := slices.IndexFunc(state.Glossary, func(e glossaryEntry) bool { return e.ID == id })
+		if index < 0 {
+			writeAPIError(ctx, w, newAPIError("notFound"))
+			return
+		}
+		before := state.Glossary[index]
+		state.Glossary = slices.Delete(state.Glossary, index, index+1)
+		if !s.commitAdminState(ctx, w, state) {
+			return
+		}
+		s.glossary.replace(state.Glossary)
+		s.auditAdmin(r, "glossary.delete", before, nil)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeAPIError(ctx, w, newAPIError("notFound"))
+	}
+}
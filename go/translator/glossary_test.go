@@ -0,0 +1,143 @@
+package translator
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"doubao/mockupstream"
+)
+
+func TestGlossaryDetector(t *testing.T) {
+	store := newGlossaryStore()
+	store.replace([]glossaryEntry{
+		{ID: "1", Source: "Acme", Target: "艾克米", TargetLanguage: "zh"},
+		{ID: "2", Source: "Acme Cloud", Target: "艾克米云", TargetLanguage: "zh"},
+		{ID: "3", Source: "Acme", Target: "アクメ", TargetLanguage: "ja"},
+		{ID: "4", Source: "C++", Target: "C 加加", TargetLanguage: "zh"},
+	})
+
+	detector := store.detectorFor("zh")
+	if detector == nil {
+		t.Fatal("no detector for zh")
+	}
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{text: "Acme Cloud, Acme and Acmeville", want: []string{"Acme Cloud", "Acme"}},
+		{text: "acme is lowercase", want: nil},
+		{text: "使用Acme部署", want: []string{"Acme"}},
+		{text: "C++ and C++11", want: []string{"C++", "C++"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			var got []string
+			for _, span := range findSpans(tt.text, []spanDetector{detector.detector}, nil) {
+				if span.Class != SpanGlossary {
+					t.Errorf("span class = %q", span.Class)
+				}
+				got = append(got, tt.text[span.Start:span.End])
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("spans = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if target := detector.targets["Acme"]; target != "艾克米" {
+		t.Errorf("target for Acme = %q", target)
+	}
+	if store.detectorFor("fr") != nil {
+		t.Error("detector for a language without terms should be nil")
+	}
+
+	store.replace(nil)
+	if store.detectorFor("zh") != nil {
+		t.Error("detector survived replacing the glossary")
+	}
+}
+
+func TestGlossaryMockUpstream(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		stream bool
+	}{
+		{name: "chat", path: "/v1/chat/completions", body: chatBody("m", "Deploy Acme Cloud with Acme", false)},
+		{name: "chat stream", path: "/v1/chat/completions", body: chatBody("m", "Deploy Acme Cloud with Acme", true), stream: true},
+		{name: "responses", path: "/v1/responses", body: responsesBody("m", "Deploy Acme Cloud with Acme", false)},
+		{name: "responses stream", path: "/v1/responses", body: responsesBody("m", "Deploy Acme Cloud with Acme", true), stream: true},
+	}
+	// 模拟服务原样保留占位符，ChunkRunes 较小时占位符会被拆到多个增量里。
+	want := mockupstream.Translation("Deploy アクメクラウド with アクメ", "ja")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mock := newMockHandler(t, mockupstream.Options{ChunkRunes: 3})
+			handler.glossary.replace([]glossaryEntry{
+				{ID: "1", Source: "Acme", Target: "アクメ", TargetLanguage: "ja"},
+				{ID: "2", Source: "Acme Cloud", Target: "アクメクラウド", TargetLanguage: "ja"},
+			})
+			rec := serveJSON(handler, tt.path, tt.body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+			}
+			if got := mock.Requests()[0].Text; got != "Deploy [[GLOSSARY_1]] with [[GLOSSARY_2]]" {
+				t.Errorf("upstream text = %q", got)
+			}
+			body := rec.Body.String()
+			if strings.Contains(body, "GLOSSARY") {
+				t.Errorf("placeholder leaked into the response: %s", body)
+			}
+			if !tt.stream {
+				if !strings.Contains(body, want) {
+					t.Errorf("response %s does not contain %q", body, want)
+				}
+				return
+			}
+			var text strings.Builder
+			for _, event := range parseSSE(t, body) {
+				if event.Data == "[DONE]" {
+					continue
+				}
+				var chunk struct {
+					Type    string
+					Delta   string
+					Choices []struct{ Delta struct{ Content string } }
+				}
+				decodeJSON(t, event.Data, &chunk)
+				if chunk.Type == "response.output_text.delta" {
+					text.WriteString(chunk.Delta)
+				}
+				for _, choice := range chunk.Choices {
+					text.WriteString(choice.Delta.Content)
+				}
+			}
+			if text.String() != want {
+				t.Errorf("streamed text = %q, want %q", text.String(), want)
+			}
+		})
+	}
+}
+
+func TestPlaceholderRestorerSplitToken(t *testing.T) {
+	set := newPlaceholderSet("Acme")
+	set.glossary = map[string]string{"Acme": "アクメ"}
+	protected := set.replaceSpans("Acme", []textSpan{{Start: 0, End: 4, Class: SpanGlossary}})
+	restorer := &placeholderRestorer{set: set}
+
+	var out strings.Builder
+	for _, delta := range []string{"[ja] [", "[GLOSS", "ARY_1", "]", "] [x"} {
+		out.WriteString(restorer.push(delta))
+	}
+	out.WriteString(restorer.flush())
+	if out.String() != "[ja] アクメ [x" {
+		t.Errorf("restored = %q", out.String())
+	}
+	if err := restorer.verify(); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+	if err := set.verify("[ja] " + protected + protected); err == nil || err.Template != "placeholderMismatch" {
+		t.Errorf("duplicated placeholder verify = %v, want placeholderMismatch", err)
+	}
+}
@@ -0,0 +1,394 @@
+package translator
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gRPC TranslationService（proto/translation.proto）：基于标准库明文 HTTP/2（h2c）手写的服务端，
+// 与 HTTP 接口共享选项解析、上游客户端、同语种直通与链路追踪，监听在独立端口上。
+
+const grpcServicePrefix = "/doubao.translation.v1.TranslationService/"
+
+const (
+	grpcBatchConcurrency = 4
+	grpcMaxBatchSize     = 128
+)
+
+// gRPC 状态码，见 https://grpc.github.io/grpc/core/md_doc_statuscodes.html
+const (
+	grpcCodeOK                = 0
+	grpcCodeInvalidArgument   = 3
+	grpcCodeDeadlineExceeded  = 4
+	grpcCodeNotFound          = 5
+	grpcCodePermissionDenied  = 7
+	grpcCodeResourceExhausted = 8
+	grpcCodeUnimplemented     = 12
+	grpcCodeInternal          = 13
+	grpcCodeUnavailable       = 14
+	grpcCodeUnauthenticated   = 16
+)
+
+type grpcStatus struct {
+	Code    int
+	Message string
+}
+
+func (s *grpcStatus) Error() string {
+	return fmt.Sprintf("grpc status %d: %s", s.Code, s.Message)
+}
+
+// grpcStatusFromError 复用 HTTP 接口的错误映射，再按 HTTP 状态码转换为 gRPC 状态码。
+func grpcStatusFromError(ctx context.Context, err error) *grpcStatus {
+	if st, ok := err.(*grpcStatus); ok {
+		return st
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return &grpcStatus{Code: grpcCodeDeadlineExceeded, Message: ctx.Err().Error()}
+	}
+	apiErr := toAPIError(err)
+	return &grpcStatus{Code: grpcCodeForHTTPStatus(apiErr.status()), Message: apiErr.message(localeFromContext(ctx))}
+}
+
+func grpcCodeForHTTPStatus(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return grpcCodeInvalidArgument
+	case http.StatusUnauthorized:
+		return grpcCodeUnauthenticated
+	case http.StatusForbidden:
+		return grpcCodePermissionDenied
+	case http.StatusNotFound:
+		return grpcCodeNotFound
+	case http.StatusTooManyRequests:
+		return grpcCodeResourceExhausted
+	case http.StatusGatewayTimeout:
+		return grpcCodeDeadlineExceeded
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return grpcCodeUnavailable
+	default:
+		return grpcCodeInternal
+	}
+}
+
+// NewGRPCServer 创建只接受 HTTP/2 prior knowledge 明文连接的服务器；不设 WriteTimeout 以支持长时间的流。
+func NewGRPCServer(addr string, s *Handler) *http.Server {
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	// 配置 TLS 后由 main 以 ServeTLS 启动，此时走标准的 HTTP/2 over TLS。
+	protocols.SetHTTP2(true)
+	return &http.Server{
+		Addr:              addr,
+		Handler:           http.HandlerFunc(s.serveGRPC),
+		Protocols:         protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+}
+
+func (s *Handler) serveGRPC(w http.ResponseWriter, r *http.Request) {
+	ctx := contextWithRemoteParent(r.Context(), r.Header.Get("traceparent"))
+	ctx = withLocale(ctx, negotiateLocale(r.Header.Get("Accept-Language")))
+	method := strings.TrimPrefix(r.URL.Path, grpcServicePrefix)
+	ctx, rootSpan := s.tracer.start(ctx, strings.TrimPrefix(r.URL.Path, "/"), spanKindServer)
+	defer rootSpan.end()
+	rootSpan.setAttr("rpc.system", "grpc")
+	rootSpan.setAttr("rpc.service", "doubao.translation.v1.TranslationService")
+	rootSpan.setAttr("rpc.method", method)
+
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		writeAPIError(ctx, w, newAPIError("notFound"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+
+	status := s.dispatchGRPC(ctx, w, r, method)
+	rootSpan.setAttr("rpc.grpc.status_code", status.Code)
+	if status.Code != grpcCodeOK {
+		rootSpan.setError(status.Message)
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(status.Code))
+	if status.Message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", grpcPercentEncode(status.Message))
+	}
+}
+
+func (s *Handler) dispatchGRPC(ctx context.Context, w http.ResponseWriter, r *http.Request, method string) *grpcStatus {
+	if !strings.HasPrefix(r.URL.Path, grpcServicePrefix) {
+		return &grpcStatus{Code: grpcCodeUnimplemented, Message: "unknown service " + r.URL.Path}
+	}
+	if timeout, ok := parseGRPCTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	address, err := checkClientIP(r)
+	spanFromContext(ctx).setAttr("client.address", address)
+	if err != nil {
+		return grpcStatusFromError(ctx, err)
+	}
+	ctx, done := s.inflight.start(ctx, r, address)
+	defer done()
+	identity, auth, err := s.authorize(ctx, r)
+	if err != nil {
+		return grpcStatusFromError(ctx, err)
+	}
+	ctx = withIdentity(ctx, identity)
+	updateInflight(ctx, func(req *inflightRequest) { req.Identity = identity.Name })
+	spanFromContext(ctx).setAttr("enduser.id", identity.Name)
+
+	payload, status := readGRPCMessage(r.Body)
+	if status != nil {
+		return status
+	}
+
+	switch method {
+	case "Translate":
+		err = s.grpcTranslate(ctx, w, auth, payload)
+	case "TranslateStream":
+		err = s.grpcTranslateStream(ctx, w, auth, payload)
+	case "BatchTranslate":
+		err = s.grpcBatchTranslate(ctx, w, auth, payload)
+	default:
+		return &grpcStatus{Code: grpcCodeUnimplemented, Message: "unknown method " + method}
+	}
+	if err != nil {
+		return grpcStatusFromError(ctx, err)
+	}
+	return &grpcStatus{Code: grpcCodeOK}
+}
+
+// readGRPCMessage 读取一条长度前缀消息（1 字节压缩标记 + 4 字节大端长度）。
+func readGRPCMessage(body io.Reader) ([]byte, *grpcStatus) {
+	var header [5]byte
+	if _, err := io.ReadFull(body, header[:]); err != nil {
+		return nil, &grpcStatus{Code: grpcCodeInvalidArgument, Message: "missing request message"}
+	}
+	if header[0] != 0 {
+		return nil, &grpcStatus{Code: grpcCodeUnimplemented, Message: "compressed messages are not supported"}
+	}
+	length := binary.BigEndian.Uint32(header[1:])
+	if int64(length) > CONFIG.MaxRequestSize {
+		return nil, &grpcStatus{Code: grpcCodeResourceExhausted, Message: newAPIError("tooLarge").message(CONFIG.DefaultLocale)}
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(body, payload); err != nil {
+		return nil, &grpcStatus{Code: grpcCodeInvalidArgument, Message: "truncated request message"}
+	}
+	return payload, nil
+}
+
+func writeGRPCMessage(w http.ResponseWriter, payload []byte) error {
+	frame := make([]byte, 5, 5+len(payload))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	if _, err := w.Write(append(frame, payload...)); err != nil {
+		return err
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// resolveLanguageFields 将 gRPC 与 Go 客户端的独立语言字段交给与 HTTP 接口相同的选项解析流程。
+func (s *Handler) resolveLanguageFields(ctx context.Context, source, target string, skipSame bool) (translationOptions, []interface{}, error) {
+	overrides := map[string]interface{}{}
+	if source != "" {
+		overrides["source_language"] = source
+	}
+	if target != "" {
+		overrides["target_language"] = target
+	}
+	if skipSame {
+		overrides["skip_same_language"] = true
+	}
+	options, err := s.resolveTranslationOptions(ctx, "", overrides)
+	return options, []interface{}{overrides}, err
+}
+
+func (s *Handler) grpcTranslationRequest(ctx context.Context, auth string, in pbTranslateRequest) (translationRequest, error) {
+	if in.Model == "" {
+		return translationRequest{}, newAPIError("noModel")
+	}
+	if err := validateSegmentText(in.Text, "text"); err != nil {
+		return translationRequest{}, err
+	}
+	options, overrides, err := s.resolveLanguageFields(ctx, in.SourceLanguage, in.TargetLanguage, in.SkipSameLanguage)
+	if err != nil {
+		return translationRequest{}, err
+	}
+	return translationRequest{Auth: auth, Model: in.Model, Text: in.Text, Options: options, Overrides: overrides}, nil
+}
+
+func newPBTranslateResponse(req translationRequest, result translationResult) *pbTranslateResponse {
+	response := &pbTranslateResponse{
+		Text:                   result.Text,
+		Model:                  req.Model,
+		TargetLanguage:         req.Options.TargetLanguage,
+		DetectedSourceLanguage: result.DetectedSourceLanguage,
+		Passthrough:            result.Passthrough,
+		Usage: pbUsage{
+			InputTokens:  int32(result.InputTokens),
+			OutputTokens: int32(result.OutputTokens),
+			TotalTokens:  int32(result.TotalTokens),
+		},
+	}
+	if req.Options.SourceLanguage != nil {
+		response.SourceLanguage = *req.Options.SourceLanguage
+	}
+	return response
+}
+
+func (s *Handler) grpcTranslate(ctx context.Context, w http.ResponseWriter, auth string, payload []byte) error {
+	var in pbTranslateRequest
+	if err := in.unmarshal(payload); err != nil {
+		return &grpcStatus{Code: grpcCodeInvalidArgument, Message: err.Error()}
+	}
+	if err := s.checkRateLimit(ctx, 1); err != nil {
+		return err
+	}
+	req, err := s.grpcTranslationRequest(ctx, auth, in)
+	if err != nil {
+		return err
+	}
+	result, err := s.translateText(ctx, req, nil)
+	if err != nil {
+		return err
+	}
+	return writeGRPCMessage(w, newPBTranslateResponse(req, result).marshal())
+}
+
+func (s *Handler) grpcTranslateStream(ctx context.Context, w http.ResponseWriter, auth string, payload []byte) error {
+	var in pbTranslateRequest
+	if err := in.unmarshal(payload); err != nil {
+		return &grpcStatus{Code: grpcCodeInvalidArgument, Message: err.Error()}
+	}
+	if err := s.checkRateLimit(ctx, 1); err != nil {
+		return err
+	}
+	req, err := s.grpcTranslationRequest(ctx, auth, in)
+	if err != nil {
+		return err
+	}
+	result, err := s.translateText(ctx, req, func(delta string) error {
+		return writeGRPCMessage(w, (&pbTranslateStreamResponse{Delta: delta}).marshal())
+	})
+	if err != nil {
+		return err
+	}
+	return writeGRPCMessage(w, (&pbTranslateStreamResponse{Result: newPBTranslateResponse(req, result)}).marshal())
+}
+
+// grpcBatchTranslate 以有限并发逐条翻译；单条失败只记录在对应结果中，不影响其他条目。
+func (s *Handler) grpcBatchTranslate(ctx context.Context, w http.ResponseWriter, auth string, payload []byte) error {
+	var in pbBatchTranslateRequest
+	if err := in.unmarshal(payload); err != nil {
+		return &grpcStatus{Code: grpcCodeInvalidArgument, Message: err.Error()}
+	}
+	if in.Model == "" {
+		return newAPIError("noModel")
+	}
+	if len(in.Texts) == 0 {
+		return newAPIError("noMessage").withParam("texts")
+	}
+	if len(in.Texts) > grpcMaxBatchSize {
+		return newAPIError("batchTooLarge", grpcMaxBatchSize).withParam("texts")
+	}
+	// 批量请求按文本条数消耗限流令牌，超过剩余令牌时整批拒绝。
+	if err := s.checkRateLimit(ctx, len(in.Texts)); err != nil {
+		return err
+	}
+	// 语言选项对整批只解析一次，无效时整个调用失败。
+	options, overrides, err := s.resolveLanguageFields(ctx, in.SourceLanguage, in.TargetLanguage, in.SkipSameLanguage)
+	if err != nil {
+		return err
+	}
+
+	response := pbBatchTranslateResponse{Results: make([]pbBatchTranslateResult, len(in.Texts))}
+	locale := localeFromContext(ctx)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, grpcBatchConcurrency)
+	for i, text := range in.Texts {
+		wg.Add(1)
+		go func(i int, text string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			outcome := pbBatchTranslateResult{Index: int32(i)}
+			req := translationRequest{Auth: auth, Model: in.Model, Text: text, Options: options, Overrides: overrides}
+			err := validateSegmentText(text, fmt.Sprintf("texts[%d]", i))
+			var result translationResult
+			if err == nil {
+				result, err = s.translateText(ctx, req, nil)
+			}
+			if err != nil {
+				apiErr := toAPIError(err)
+				outcome.Error = &pbBatchError{Code: apiErr.template().Code, Message: apiErr.message(locale)}
+			} else {
+				outcome.Response = newPBTranslateResponse(req, result)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			response.Results[i] = outcome
+			if outcome.Response != nil {
+				response.Usage.InputTokens += outcome.Response.Usage.InputTokens
+				response.Usage.OutputTokens += outcome.Response.Usage.OutputTokens
+				response.Usage.TotalTokens += outcome.Response.Usage.TotalTokens
+			}
+		}(i, text)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return writeGRPCMessage(w, response.marshal())
+}
+
+// parseGRPCTimeout 解析 grpc-timeout 头，例如 "500m"、"10S"。
+func parseGRPCTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 || len(value) > 9 {
+		return 0, false
+	}
+	amount, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || amount < 0 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+	unit, ok := units[value[len(value)-1]]
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(amount) * unit, true
+}
+
+// grpcPercentEncode 按 gRPC 规范对 grpc-message 中的非可打印 ASCII 与 '%' 做百分号编码。
+func grpcPercentEncode(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c >= 0x20 && c <= 0x7E && c != '%' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,296 @@
+package translator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newGRPCTestServer 以 h2c 启动 gRPC 服务，上游指向 Ark 桩服务；返回服务地址与只走明文 HTTP/2 的客户端。
+func newGRPCTestServer(t *testing.T) (string, *http.Client) {
+	t.Helper()
+	srv, _, _ := newTracedHandler(t)
+	return serveGRPCHandler(t, srv)
+}
+
+func serveGRPCHandler(t *testing.T, srv *Handler) (string, *http.Client) {
+	t.Helper()
+	grpcSrv := NewGRPCServer("", srv)
+	ts := httptest.NewUnstartedServer(grpcSrv.Handler)
+	ts.Config.Protocols = grpcSrv.Protocols
+	ts.Start()
+	t.Cleanup(ts.Close)
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	return ts.URL, &http.Client{Transport: &http.Transport{Protocols: protocols}}
+}
+
+type grpcResult struct {
+	messages [][]byte
+	code     int
+	message  string
+}
+
+func grpcFrame(payload []byte) []byte {
+	frame := make([]byte, 5, 5+len(payload))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+func callGRPC(t *testing.T, baseURL string, client *http.Client, method string, body []byte, header http.Header) grpcResult {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, baseURL+grpcServicePrefix+method, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("TE", "trailers")
+	req.Header.Set("Accept-Language", "en")
+	req.Header.Set("Authorization", "Bearer test-key")
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 || resp.StatusCode != http.StatusOK {
+		t.Fatalf("response %s %d, want HTTP/2 200", resp.Proto, resp.StatusCode)
+	}
+
+	var result grpcResult
+	for {
+		var prefix [5]byte
+		if _, err := io.ReadFull(resp.Body, prefix[:]); err != nil {
+			break
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+		if _, err := io.ReadFull(resp.Body, payload); err != nil {
+			t.Fatal(err)
+		}
+		result.messages = append(result.messages, payload)
+	}
+	result.code, err = strconv.Atoi(resp.Trailer.Get("Grpc-Status"))
+	if err != nil {
+		t.Fatalf("missing grpc-status trailer: %v", resp.Trailer)
+	}
+	result.message, _ = url.PathUnescape(resp.Trailer.Get("Grpc-Message"))
+	return result
+}
+
+func TestGRPCTranslate(t *testing.T) {
+	baseURL, client := newGRPCTestServer(t)
+	request := pbTranslateRequest{Model: "m", Text: "Good morning", SourceLanguage: "English", TargetLanguage: "fr-FR"}
+
+	result := callGRPC(t, baseURL, client, "Translate", grpcFrame(request.marshal()), nil)
+	if result.code != grpcCodeOK || len(result.messages) != 1 {
+		t.Fatalf("Translate: status %d %q, %d messages", result.code, result.message, len(result.messages))
+	}
+	var response pbTranslateResponse
+	if err := response.unmarshal(result.messages[0]); err != nil {
+		t.Fatal(err)
+	}
+	want := pbTranslateResponse{
+		Text: stubTranslation, Model: "m", SourceLanguage: "en", TargetLanguage: "fr",
+		Usage: pbUsage{InputTokens: 12, OutputTokens: int32(len(stubTranslation)), TotalTokens: int32(12 + len(stubTranslation))},
+	}
+	if response != want {
+		t.Errorf("Translate = %+v, want %+v", response, want)
+	}
+
+	result = callGRPC(t, baseURL, client, "TranslateStream", grpcFrame(request.marshal()), nil)
+	if result.code != grpcCodeOK || len(result.messages) < 2 {
+		t.Fatalf("TranslateStream: status %d %q, %d messages", result.code, result.message, len(result.messages))
+	}
+	var deltas strings.Builder
+	for i, data := range result.messages {
+		var event pbTranslateStreamResponse
+		if err := event.unmarshal(data); err != nil {
+			t.Fatal(err)
+		}
+		last := i == len(result.messages)-1
+		if (event.Result != nil) != last {
+			t.Fatalf("message %d: result %v, want the result only in the last message", i, event.Result)
+		}
+		if last && *event.Result != want {
+			t.Errorf("stream result = %+v, want %+v", *event.Result, want)
+		}
+		deltas.WriteString(event.Delta)
+	}
+	if deltas.String() != stubTranslation {
+		t.Errorf("deltas = %q, want %q", deltas.String(), stubTranslation)
+	}
+}
+
+func TestGRPCBatchTranslate(t *testing.T) {
+	baseURL, client := newGRPCTestServer(t)
+	texts := []string{"Good morning", stubFailText, "  ", "Good night"}
+	request := pbBatchTranslateRequest{Model: "m", Texts: texts, TargetLanguage: "fr"}
+
+	result := callGRPC(t, baseURL, client, "BatchTranslate", grpcFrame(request.marshal()), nil)
+	if result.code != grpcCodeOK || len(result.messages) != 1 {
+		t.Fatalf("BatchTranslate: status %d %q, %d messages", result.code, result.message, len(result.messages))
+	}
+	var response pbBatchTranslateResponse
+	if err := response.unmarshal(result.messages[0]); err != nil {
+		t.Fatal(err)
+	}
+	wantErrors := map[int]string{1: "rate_limit_exceeded", 2: "missing_user_message"}
+	if len(response.Results) != len(texts) {
+		t.Fatalf("%d results, want %d", len(response.Results), len(texts))
+	}
+	for i, r := range response.Results {
+		if int(r.Index) != i {
+			t.Errorf("result %d has index %d", i, r.Index)
+		}
+		if code, ok := wantErrors[i]; ok {
+			if r.Error == nil || r.Error.Code != code || r.Response != nil {
+				t.Errorf("result %d = %+v, want error %s", i, r, code)
+			}
+		} else if r.Response == nil || r.Response.Text != stubTranslation {
+			t.Errorf("result %d = %+v, want a translation", i, r)
+		}
+	}
+	if want := int32(len("Good morning") + len("Good night")); response.Usage.InputTokens != want {
+		t.Errorf("usage = %+v, want input_tokens %d from the successful texts only", response.Usage, want)
+	}
+}
+
+// TestGRPCBatchRateLimit 确认批量请求按文本条数消耗限流令牌，超过剩余令牌时整批拒绝且不扣令牌。
+func TestGRPCBatchRateLimit(t *testing.T) {
+	srv, _, _ := newTracedHandler(t)
+	srv.rateLimits.replace(rateLimitSettings{Default: &rateLimit{RequestsPerMinute: 1, Burst: 3}})
+	baseURL, client := serveGRPCHandler(t, srv)
+	batch := func(n int) []byte {
+		texts := make([]string, n)
+		for i := range texts {
+			texts[i] = "Good morning"
+		}
+		return grpcFrame((&pbBatchTranslateRequest{Model: "m", Texts: texts, TargetLanguage: "fr"}).marshal())
+	}
+	single := grpcFrame((&pbTranslateRequest{Model: "m", Text: "Good morning", TargetLanguage: "fr"}).marshal())
+
+	tests := []struct {
+		name     string
+		method   string
+		body     []byte
+		wantCode int
+		wantMsg  string
+	}{
+		{name: "larger than burst", method: "BatchTranslate", body: batch(4), wantCode: grpcCodeResourceExhausted, wantMsg: "Batch of 4 texts exceeds the rate limit burst of 3"},
+		{name: "two texts", method: "BatchTranslate", body: batch(2), wantCode: grpcCodeOK},
+		{name: "larger than remaining", method: "BatchTranslate", body: batch(2), wantCode: grpcCodeResourceExhausted},
+		{name: "last token", method: "Translate", body: single, wantCode: grpcCodeOK},
+		{name: "exhausted", method: "TranslateStream", body: single, wantCode: grpcCodeResourceExhausted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := callGRPC(t, baseURL, client, tt.method, tt.body, nil)
+			if result.code != tt.wantCode {
+				t.Errorf("grpc-status = %d (%q), want %d", result.code, result.message, tt.wantCode)
+			}
+			if tt.wantMsg != "" && result.message != tt.wantMsg {
+				t.Errorf("grpc-message = %q, want %q", result.message, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestGRPCErrorCodes(t *testing.T) {
+	baseURL, client := newGRPCTestServer(t)
+	valid := grpcFrame((&pbTranslateRequest{Model: "m", Text: "Good morning", TargetLanguage: "fr"}).marshal())
+	tooMany := pbBatchTranslateRequest{Model: "m", Texts: make([]string, grpcMaxBatchSize+1)}
+
+	tests := []struct {
+		name     string
+		method   string
+		body     []byte
+		header   http.Header
+		wantCode int
+		wantMsg  string
+	}{
+		{name: "missing auth", method: "Translate", body: valid, header: http.Header{"Authorization": {"Basic x"}}, wantCode: grpcCodeUnauthenticated, wantMsg: "Missing API key"},
+		{name: "unknown method", method: "Detect", body: valid, wantCode: grpcCodeUnimplemented},
+		{name: "compressed", method: "Translate", body: append([]byte{1}, valid[1:]...), wantCode: grpcCodeUnimplemented},
+		{name: "empty body", method: "Translate", wantCode: grpcCodeInvalidArgument},
+		{name: "truncated message", method: "Translate", body: valid[:len(valid)-1], wantCode: grpcCodeInvalidArgument},
+		{name: "malformed protobuf", method: "Translate", body: grpcFrame([]byte{0x12, 0x09}), wantCode: grpcCodeInvalidArgument},
+		{name: "missing model", method: "Translate", body: grpcFrame((&pbTranslateRequest{Text: "hi"}).marshal()), wantCode: grpcCodeInvalidArgument},
+		{name: "empty text", method: "TranslateStream", body: grpcFrame((&pbTranslateRequest{Model: "m"}).marshal()), wantCode: grpcCodeInvalidArgument},
+		{name: "unknown language", method: "Translate", body: grpcFrame((&pbTranslateRequest{Model: "m", Text: "hi", TargetLanguage: "klingon"}).marshal()), wantCode: grpcCodeInvalidArgument},
+		{name: "upstream rate limit", method: "Translate", body: grpcFrame((&pbTranslateRequest{Model: "m", Text: stubFailText}).marshal()), wantCode: grpcCodeResourceExhausted},
+		{name: "empty batch", method: "BatchTranslate", body: grpcFrame((&pbBatchTranslateRequest{Model: "m"}).marshal()), wantCode: grpcCodeInvalidArgument},
+		{name: "batch too large", method: "BatchTranslate", body: grpcFrame(tooMany.marshal()), wantCode: grpcCodeInvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := callGRPC(t, baseURL, client, tt.method, tt.body, tt.header)
+			if result.code != tt.wantCode {
+				t.Errorf("grpc-status = %d (%q), want %d", result.code, result.message, tt.wantCode)
+			}
+			if tt.wantMsg != "" && result.message != tt.wantMsg {
+				t.Errorf("grpc-message = %q, want %q", result.message, tt.wantMsg)
+			}
+			if len(result.messages) != 0 {
+				t.Errorf("failed call returned %d messages", len(result.messages))
+			}
+		})
+	}
+}
+
+func TestGRPCCodeForHTTPStatus(t *testing.T) {
+	tests := map[int]int{
+		http.StatusBadRequest:          grpcCodeInvalidArgument,
+		http.StatusUnauthorized:        grpcCodeUnauthenticated,
+		http.StatusForbidden:           grpcCodePermissionDenied,
+		http.StatusNotFound:            grpcCodeNotFound,
+		http.StatusTooManyRequests:     grpcCodeResourceExhausted,
+		http.StatusGatewayTimeout:      grpcCodeDeadlineExceeded,
+		http.StatusBadGateway:          grpcCodeUnavailable,
+		http.StatusServiceUnavailable:  grpcCodeUnavailable,
+		http.StatusInternalServerError: grpcCodeInternal,
+	}
+	for status, want := range tests {
+		if got := grpcCodeForHTTPStatus(status); got != want {
+			t.Errorf("grpcCodeForHTTPStatus(%d) = %d, want %d", status, got, want)
+		}
+	}
+}
+
+func TestParseGRPCTimeout(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   string
+		wantOK bool
+	}{
+		{value: "500m", want: "500ms", wantOK: true},
+		{value: "10S", want: "10s", wantOK: true},
+		{value: "2H", want: "2h0m0s", wantOK: true},
+		{value: "100u", want: "100µs", wantOK: true},
+		{value: "", wantOK: false},
+		{value: "5", wantOK: false},
+		{value: "5x", wantOK: false},
+		{value: "-1S", wantOK: false},
+		{value: "123456789S", wantOK: false},
+	}
+	for _, tt := range tests {
+		got, ok := parseGRPCTimeout(tt.value)
+		if ok != tt.wantOK || (ok && got.String() != tt.want) {
+			t.Errorf("parseGRPCTimeout(%q) = %v, %v; want %s, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestGRPCPercentEncode(t *testing.T) {
+	if got, want := grpcPercentEncode("rate 100% 超限\n"), "rate 100%25 %E8%B6%85%E9%99%90%0A"; got != want {
+		t.Errorf("grpcPercentEncode = %q, want %q", got, want)
+	}
+}
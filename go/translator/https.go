@@ -0,0 +1,230 @@
+package translator
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTPS 策略：请求经 TLS 直连到达，或来自可信代理且 X-Forwarded-Proto 为 https 时视为 HTTPS；
+// 其余请求按 CONFIG.HTTPSPolicy 拒绝、重定向到 https:// 或放行。
+// 不可信来源携带的 X-Forwarded-Proto 会被忽略，避免客户端伪造。
+
+const (
+	HTTPSPolicyReject   = "reject"
+	HTTPSPolicyRedirect = "redirect"
+	HTTPSPolicyAllow    = "allow"
+)
+
+// defaultTrustedProxies 只包含回环地址（同机反向代理）；内网中的负载均衡需通过 TRUSTED_PROXIES 显式配置，
+// 否则同一内网的任意主机都能用 X-Forwarded-For 伪造来源 IP 绕过 IP 访问控制。
+var defaultTrustedProxies = mustParseCIDRs("127.0.0.0/8,::1/128")
+
+// parseCIDRs 解析逗号分隔的 CIDR 列表，单个 IP 视为 /32 或 /128；"none" 表示不信任任何代理。
+func parseCIDRs(value string) ([]*net.IPNet, error) {
+	if strings.EqualFold(strings.TrimSpace(value), "none") {
+		return []*net.IPNet{}, nil
+	}
+	var nets []*net.IPNet
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP %q", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+func mustParseCIDRs(value string) []*net.IPNet {
+	nets, err := parseCIDRs(value)
+	if err != nil {
+		panic(err)
+	}
+	return nets
+}
+
+// remoteIP 返回 TCP 对端地址（不含端口），无法解析时返回 nil。
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// fromTrustedProxy 判断请求是否直接来自 CONFIG.TrustedProxies 中的代理。
+func fromTrustedProxy(r *http.Request) bool {
+	return ipInNets(remoteIP(r), CONFIG.TrustedProxies)
+}
+
+// isHTTPS 检查请求是否为 TLS 直连，或由可信代理通过 X-Forwarded-Proto 标记为 HTTPS。
+// 多级代理时 X-Forwarded-Proto 可能是逗号分隔的列表，以第一个（最靠近客户端的）为准。
+func isHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	if !fromTrustedProxy(r) {
+		return false
+	}
+	proto, _, _ := strings.Cut(r.Header.Get("X-Forwarded-Proto"), ",")
+	return strings.EqualFold(strings.TrimSpace(proto), "https")
+}
+
+// enforceHTTPS 按 CONFIG.HTTPSPolicy 处理非 HTTPS 请求，已写出响应时返回 false。
+func enforceHTTPS(ctx context.Context, w http.ResponseWriter, r *http.Request) bool {
+	if CONFIG.HTTPSPolicy == HTTPSPolicyAllow || isHTTPS(r) {
+		return true
+	}
+	if CONFIG.HTTPSPolicy == HTTPSPolicyRedirect && r.Host != "" {
+		// 308 保留原请求方法与请求体，POST 客户端可直接跟随。
+		http.Redirect(w, r, "https://"+r.Host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+		return false
+	}
+	writeAPIError(ctx, w, newAPIError("https"))
+	return false
+}
+
+// certCheckInterval 为检查证书文件是否变化的最小间隔。
+const certCheckInterval = 10 * time.Second
+
+// certReloader 在 TLS 握手时按需检查证书与私钥文件的修改时间，变化后重新加载；
+// 加载失败（例如证书与私钥只更新了一半）时继续使用旧证书，并在下次检查时重试。
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+	checked time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certReloader) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+func (c *certReloader) load() error {
+	certMod, keyMod, err := c.modTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.cert, c.certMod, c.keyMod = &cert, certMod, keyMod
+	return nil
+}
+
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now := time.Now(); now.Sub(c.checked) >= certCheckInterval {
+		c.checked = now
+		certMod, keyMod, err := c.modTimes()
+		if err != nil {
+			log.Printf("failed to check TLS certificate files: %v", err)
+		} else if !certMod.Equal(c.certMod) || !keyMod.Equal(c.keyMod) {
+			if err := c.load(); err != nil {
+				log.Printf("failed to reload TLS certificate, keeping the previous one: %v", err)
+			} else {
+				log.Printf("reloaded TLS certificate from %s", c.certFile)
+			}
+		}
+	}
+	return c.cert, nil
+}
+
+const (
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// NewTLSConfig 根据 CONFIG.TLSCertFile/TLSKeyFile 构造服务端 TLS 配置，证书文件变化后自动重新加载；
+// 设置了 CONFIG.TLSClientCAFile 时同时校验客户端证书。未配置证书时返回 nil。
+func NewTLSConfig() (*tls.Config, error) {
+	if CONFIG.TLSCertFile == "" && CONFIG.TLSKeyFile == "" {
+		return nil, nil
+	}
+	if CONFIG.TLSCertFile == "" || CONFIG.TLSKeyFile == "" {
+		return nil, fmt.Errorf("both TLS_CERT_FILE and TLS_KEY_FILE must be set")
+	}
+	reloader, err := newCertReloader(CONFIG.TLSCertFile, CONFIG.TLSKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if CONFIG.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(CONFIG.TLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no PEM certificates found", CONFIG.TLSClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if CONFIG.TLSClientAuth == ClientAuthRequire {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return config, nil
+}
@@ -0,0 +1,163 @@
+package translator
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// 调用方身份：Bearer 令牌或经过验证的客户端证书（mTLS）。ServeHTTP 与 gRPC 入口在鉴权时解析出 Identity
+// 并放入请求 context，配额、用量统计与日志等后续环节统一通过 IdentityFromContext 读取，而不是直接看 Authorization 头。
+
+const (
+	IdentityMethodBearer = "bearer"
+	IdentityMethodMTLS   = "mtls"
+)
+
+type Identity struct {
+	// Name 为身份名称：证书身份取身份文件中的 name；Bearer 令牌为 "key:" 加令牌 SHA-256 的前 12 位，不暴露令牌本身。
+	Name string
+	// Method 为 IdentityMethodBearer 或 IdentityMethodMTLS。
+	Method string
+	// Subject 为客户端证书的主题，仅 mTLS 身份有值。
+	Subject string
+}
+
+type identityKey struct{}
+
+func withIdentity(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext 返回当前请求的调用方身份。
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(Identity)
+	return identity, ok
+}
+
+func bearerIdentityName(auth string) string {
+	sum := sha256.Sum256([]byte(strings.TrimPrefix(auth, "Bearer ")))
+	return "key:" + hex.EncodeToString(sum[:6])
+}
+
+// ClientIdentity 为身份文件中的一项：客户端证书满足 Match 中任意一条即映射为该身份。
+// Match 的写法为 "CN:<通用名>"、"DNS:<SAN 域名>"、"URI:<SAN URI>"、"EMAIL:<SAN 邮箱>"、"IP:<SAN IP>"
+// 或 "SUBJECT:<完整主题，如 CN=billing,O=Acme>"，值支持 path.Match 通配符（如 "DNS:*.svc.internal"）。
+// APIKey 为该身份调用上游时使用的方舟 API Key；请求自带 Bearer 令牌时以请求中的为准。
+type ClientIdentity struct {
+	Name   string   `json:"name"`
+	Match  []string `json:"match"`
+	APIKey string   `json:"api_key,omitempty"`
+}
+
+// clientIdentities 为生效的证书身份映射，重新加载时整体替换。
+var clientIdentities atomic.Pointer[[]ClientIdentity]
+
+// LoadClientIdentities 从 JSON 数组文件加载客户端证书到身份的映射，原子地替换当前映射。
+func LoadClientIdentities(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var identities []ClientIdentity
+	if err := json.Unmarshal(data, &identities); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	for i, identity := range identities {
+		if identity.Name == "" || len(identity.Match) == 0 {
+			return fmt.Errorf("%s: identity %d needs a name and at least one match rule", file, i)
+		}
+		for _, rule := range identity.Match {
+			kind, pattern, ok := strings.Cut(rule, ":")
+			if !ok || certAttributes[strings.ToUpper(kind)] == nil {
+				return fmt.Errorf("%s: identity %q: invalid match rule %q", file, identity.Name, rule)
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%s: identity %q: invalid pattern %q: %w", file, identity.Name, pattern, err)
+			}
+		}
+	}
+	clientIdentities.Store(&identities)
+	return nil
+}
+
+// certAttributes 列出各匹配类型从证书中取出的候选值。
+var certAttributes = map[string]func(*x509.Certificate) []string{
+	"CN":      func(c *x509.Certificate) []string { return []string{c.Subject.CommonName} },
+	"SUBJECT": func(c *x509.Certificate) []string { return []string{c.Subject.String()} },
+	"DNS":     func(c *x509.Certificate) []string { return c.DNSNames },
+	"EMAIL":   func(c *x509.Certificate) []string { return c.EmailAddresses },
+	"URI": func(c *x509.Certificate) []string {
+		values := make([]string, 0, len(c.URIs))
+		for _, u := range c.URIs {
+			values = append(values, u.String())
+		}
+		return values
+	},
+	"IP": func(c *x509.Certificate) []string {
+		values := make([]string, 0, len(c.IPAddresses))
+		for _, ip := range c.IPAddresses {
+			values = append(values, ip.String())
+		}
+		return values
+	},
+}
+
+func matchClientIdentity(cert *x509.Certificate) (ClientIdentity, bool) {
+	identities := clientIdentities.Load()
+	if identities == nil {
+		return ClientIdentity{}, false
+	}
+	for _, identity := range *identities {
+		for _, rule := range identity.Match {
+			kind, pattern, _ := strings.Cut(rule, ":")
+			for _, value := range certAttributes[strings.ToUpper(kind)](cert) {
+				if ok, _ := path.Match(pattern, value); ok && value != "" {
+					return identity, true
+				}
+			}
+		}
+	}
+	return ClientIdentity{}, false
+}
+
+// verifiedClientCert 返回 TLS 握手中已通过 CA 校验的客户端证书。
+func verifiedClientCert(state *tls.ConnectionState) *x509.Certificate {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
+
+// authenticate 解析调用方身份并返回转发给上游的 Authorization 头。
+// 提供了已验证的客户端证书时按身份文件映射，未映射的证书返回 403；否则要求 Bearer 令牌。
+func authenticate(r *http.Request) (Identity, string, error) {
+	auth := r.Header.Get("Authorization")
+	hasBearer := strings.HasPrefix(auth, "Bearer ")
+	if cert := verifiedClientCert(r.TLS); cert != nil {
+		identity, ok := matchClientIdentity(cert)
+		if !ok {
+			return Identity{}, "", newAPIError("unknownClientCertificate", cert.Subject.String())
+		}
+		if !hasBearer {
+			if identity.APIKey == "" {
+				return Identity{}, "", newAPIError("noAuth")
+			}
+			auth = "Bearer " + identity.APIKey
+		}
+		return Identity{Name: identity.Name, Method: IdentityMethodMTLS, Subject: cert.Subject.String()}, auth, nil
+	}
+	if !hasBearer {
+		return Identity{}, "", newAPIError("noAuth")
+	}
+	return Identity{Name: bearerIdentityName(auth), Method: IdentityMethodBearer}, auth, nil
+}
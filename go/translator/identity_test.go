@@ -0,0 +1,137 @@
+package translator
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useClientIdentities 写出身份文件并加载，测试结束后恢复原映射。
+func useClientIdentities(t *testing.T, data string) error {
+	t.Helper()
+	previous := clientIdentities.Load()
+	t.Cleanup(func() { clientIdentities.Store(previous) })
+	file := filepath.Join(t.TempDir(), "identities.json")
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadClientIdentities(file)
+}
+
+func TestMatchClientIdentity(t *testing.T) {
+	err := useClientIdentities(t, `[
+		{"name": "billing", "match": ["URI:spiffe://acme/billing", "DNS:*.billing.internal"], "api_key": "ark-billing"},
+		{"name": "reports", "match": ["cn:reports-?"]},
+		{"name": "ops", "match": ["SUBJECT:CN=ops,O=Acme", "EMAIL:*@ops.acme.test", "IP:10.0.0.*"]}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spiffe, _ := url.Parse("spiffe://acme/billing")
+	tests := []struct {
+		name string
+		cert x509.Certificate
+		want string
+	}{
+		{name: "uri", cert: x509.Certificate{URIs: []*url.URL{spiffe}}, want: "billing"},
+		{name: "dns wildcard", cert: x509.Certificate{DNSNames: []string{"other.test", "api.billing.internal"}}, want: "billing"},
+		{name: "lowercase kind", cert: x509.Certificate{Subject: pkix.Name{CommonName: "reports-1"}}, want: "reports"},
+		{name: "common name mismatch", cert: x509.Certificate{Subject: pkix.Name{CommonName: "reports-10"}}},
+		{name: "full subject", cert: x509.Certificate{Subject: pkix.Name{CommonName: "ops", Organization: []string{"Acme"}}}, want: "ops"},
+		{name: "subject with extra attribute", cert: x509.Certificate{Subject: pkix.Name{CommonName: "ops", Organization: []string{"Acme"}, Country: []string{"CN"}}}},
+		{name: "email", cert: x509.Certificate{EmailAddresses: []string{"oncall@ops.acme.test"}}, want: "ops"},
+		{name: "ip", cert: x509.Certificate{IPAddresses: []net.IP{net.ParseIP("10.0.0.7")}}, want: "ops"},
+		{name: "empty common name never matches", cert: x509.Certificate{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, ok := matchClientIdentity(&tt.cert)
+			if ok != (tt.want != "") || identity.Name != tt.want {
+				t.Errorf("matchClientIdentity = %q, %v; want %q", identity.Name, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadClientIdentitiesErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "invalid json", data: `{`, want: "unexpected end"},
+		{name: "missing name", data: `[{"match":["CN:x"]}]`, want: "needs a name"},
+		{name: "no rules", data: `[{"name":"x","match":[]}]`, want: "needs a name"},
+		{name: "unknown kind", data: `[{"name":"x","match":["OU:x"]}]`, want: "invalid match rule"},
+		{name: "missing kind", data: `[{"name":"x","match":["billing"]}]`, want: "invalid match rule"},
+		{name: "bad pattern", data: `[{"name":"x","match":["CN:[x"]}]`, want: "invalid pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := clientIdentities.Load()
+			err := useClientIdentities(t, tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadClientIdentities error = %v, want containing %q", err, tt.want)
+			}
+			if clientIdentities.Load() != before {
+				t.Error("a rejected identities file replaced the current mapping")
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	if err := useClientIdentities(t, `[{"name":"billing","match":["CN:billing"],"api_key":"ark-billing"},{"name":"nokey","match":["CN:nokey"]}]`); err != nil {
+		t.Fatal(err)
+	}
+	verified := func(cn string) *tls.ConnectionState {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	}
+	tests := []struct {
+		name     string
+		tls      *tls.ConnectionState
+		auth     string
+		want     Identity
+		wantAuth string
+		wantCode string
+	}{
+		{name: "bearer", auth: "Bearer sk-1", want: Identity{Name: bearerIdentityName("Bearer sk-1"), Method: IdentityMethodBearer}, wantAuth: "Bearer sk-1"},
+		{name: "no credentials", wantCode: "invalid_api_key"},
+		{name: "basic auth", auth: "Basic eDp5", wantCode: "invalid_api_key"},
+		{name: "certificate uses identity key", tls: verified("billing"), want: Identity{Name: "billing", Method: IdentityMethodMTLS, Subject: "CN=billing"}, wantAuth: "Bearer ark-billing"},
+		{name: "request token wins", tls: verified("billing"), auth: "Bearer sk-2", want: Identity{Name: "billing", Method: IdentityMethodMTLS, Subject: "CN=billing"}, wantAuth: "Bearer sk-2"},
+		{name: "certificate without key", tls: verified("nokey"), wantCode: "invalid_api_key"},
+		{name: "unmapped certificate", tls: verified("intruder"), auth: "Bearer sk-1", wantCode: "unknown_client_certificate"},
+		{name: "unverified certificate is ignored", tls: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "billing"}}}}, wantCode: "invalid_api_key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+			r.TLS = tt.tls
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			identity, auth, err := authenticate(r)
+			if tt.wantCode != "" {
+				var apiErr *apiError
+				if !errors.As(err, &apiErr) || apiErr.template().Code != tt.wantCode {
+					t.Errorf("authenticate error = %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil || identity != tt.want || auth != tt.wantAuth {
+				t.Errorf("authenticate = %+v, %q, %v; want %+v, %q", identity, auth, err, tt.want, tt.wantAuth)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package translator
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 进行中的请求：HTTP 与 gRPC 入口在路由确定后登记，处理结束时移除，供 GET /admin/requests 查看。
+// 调用方身份与模型在鉴权、解析请求体之后补充。
+
+type inflightRequest struct {
+	ID            string    `json:"id"`
+	Method        string    `json:"method"`
+	Path          string    `json:"path"`
+	ClientAddress string    `json:"client_address,omitempty"`
+	Identity      string    `json:"identity,omitempty"`
+	Model         string    `json:"model,omitempty"`
+	Stream        bool      `json:"stream"`
+	StartedAt     time.Time `json:"started_at"`
+	DurationMs    int64     `json:"duration_ms"`
+}
+
+type inflightTracker struct {
+	mu       sync.Mutex
+	requests map[string]*inflightRequest
+}
+
+func newInflightTracker() *inflightTracker {
+	return &inflightTracker{requests: map[string]*inflightRequest{}}
+}
+
+type inflightKey struct{}
+
+type inflightEntry struct {
+	tracker *inflightTracker
+	request *inflightRequest
+}
+
+// start 登记一个请求，返回带有登记项的 context 与结束时调用的移除函数。
+func (t *inflightTracker) start(ctx context.Context, r *http.Request, clientAddress string) (context.Context, func()) {
+	request := &inflightRequest{
+		ID:            genID("req"),
+		Method:        r.Method,
+		Path:          r.URL.Path,
+		ClientAddress: clientAddress,
+		StartedAt:     time.Now(),
+	}
+	t.mu.Lock()
+	t.requests[request.ID] = request
+	t.mu.Unlock()
+	done := func() {
+		t.mu.Lock()
+		delete(t.requests, request.ID)
+		t.mu.Unlock()
+	}
+	return context.WithValue(ctx, inflightKey{}, inflightEntry{tracker: t, request: request}), done
+}
+
+// snapshot 返回按开始时间排序的请求副本。
+func (t *inflightTracker) snapshot() []inflightRequest {
+	now := time.Now()
+	t.mu.Lock()
+	requests := make([]inflightRequest, 0, len(t.requests))
+	for _, request := range t.requests {
+		copied := *request
+		copied.DurationMs = now.Sub(request.StartedAt).Milliseconds()
+		requests = append(requests, copied)
+	}
+	t.mu.Unlock()
+	sort.Slice(requests, func(i, j int) bool { return requests[i].StartedAt.Before(requests[j].StartedAt) })
+	return requests
+}
+
+// updateInflight 在当前请求的登记项上补充信息，未登记的请求（例如内嵌的 Client）直接忽略。
+func updateInflight(ctx context.Context, update func(*inflightRequest)) {
+	entry, ok := ctx.Value(inflightKey{}).(inflightEntry)
+	if !ok {
+		return
+	}
+	entry.tracker.mu.Lock()
+	update(entry.request)
+	entry.tracker.mu.Unlock()
+}
@@ -0,0 +1,113 @@
+package translator
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// IP 访问控制：在鉴权之前按客户端 IP 检查拒绝列表与允许列表，拒绝列表优先；允许列表为空时不限制来源。
+// 客户端 IP 只有在对端属于 CONFIG.TrustedProxies 时才从 X-Forwarded-For / X-Real-IP 推导，否则直接使用 TCP 对端地址。
+
+type ipFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// activeIPFilter 为生效的规则，LoadIPFilter 整体替换，未加载时不限制来源。
+var activeIPFilter atomic.Pointer[ipFilter]
+
+// LoadIPFilter 解析逗号分隔的允许/拒绝 CIDR 列表（单个 IP 视为 /32 或 /128），替换当前规则。
+// 与其他环境变量不同，列表无效时返回错误而不是忽略，避免允许列表写错时放开访问。
+func LoadIPFilter(allow, deny string) error {
+	allowNets, err := parseCIDRs(allow)
+	if err != nil {
+		return fmt.Errorf("IP_ALLOWLIST: %w", err)
+	}
+	denyNets, err := parseCIDRs(deny)
+	if err != nil {
+		return fmt.Errorf("IP_DENYLIST: %w", err)
+	}
+	activeIPFilter.Store(&ipFilter{allow: allowNets, deny: denyNets})
+	return nil
+}
+
+// IPFilterEnabled 报告是否配置了任何允许或拒绝规则。
+func IPFilterEnabled() bool {
+	return activeIPFilter.Load().enabled()
+}
+
+func (f *ipFilter) enabled() bool {
+	return f != nil && (len(f.allow) > 0 || len(f.deny) > 0)
+}
+
+// clientIP 推导真实客户端地址。对端是可信代理时，从右向左遍历 X-Forwarded-For 并跳过可信代理，
+// 第一个不可信的地址即客户端；最左侧的值可由客户端随意伪造，不能直接采用。
+// 没有 X-Forwarded-For 时使用 X-Real-IP；全部都是可信代理时取最左侧的地址。
+func clientIP(r *http.Request) net.IP {
+	peer := remoteIP(r)
+	if !ipInNets(peer, CONFIG.TrustedProxies) {
+		return peer
+	}
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	var leftmost net.IP
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			// 无法解析的条目之前的内容都不可信，以已经确认的最后一跳为准。
+			break
+		}
+		if !ipInNets(ip, CONFIG.TrustedProxies) {
+			return ip
+		}
+		leftmost = ip
+	}
+	if leftmost != nil {
+		return leftmost
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip
+	}
+	return peer
+}
+
+// matchingNet 返回第一个包含 ip 的网段。
+func matchingNet(ip net.IP, nets []*net.IPNet) *net.IPNet {
+	if ip == nil {
+		return nil
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return n
+		}
+	}
+	return nil
+}
+
+// checkClientIP 按 IP 规则检查请求，返回推导出的客户端地址；被拒绝时记录日志并返回 ipDenied 错误。
+func checkClientIP(r *http.Request) (string, error) {
+	ip := clientIP(r)
+	address := r.RemoteAddr
+	if ip != nil {
+		address = ip.String()
+	}
+	filter := activeIPFilter.Load()
+	if !filter.enabled() {
+		return address, nil
+	}
+	if n := matchingNet(ip, filter.deny); n != nil {
+		log.Printf("denied %s %s from %s (peer %s): matches deny rule %s", r.Method, r.URL.Path, address, r.RemoteAddr, n)
+		return address, newAPIError("ipDenied", address)
+	}
+	if len(filter.allow) > 0 && matchingNet(ip, filter.allow) == nil {
+		log.Printf("denied %s %s from %s (peer %s): not in allow list", r.Method, r.URL.Path, address, r.RemoteAddr)
+		return address, newAPIError("ipDenied", address)
+	}
+	return address, nil
+}
@@ -0,0 +1,115 @@
+package translator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// useIPFilter 加载规则，测试结束后恢复原规则。
+func useIPFilter(t *testing.T, allow, deny string) {
+	t.Helper()
+	previous := activeIPFilter.Load()
+	t.Cleanup(func() { activeIPFilter.Store(previous) })
+	if err := LoadIPFilter(allow, deny); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	previous := CONFIG.TrustedProxies
+	defer func() { CONFIG.TrustedProxies = previous }()
+	CONFIG.TrustedProxies = mustParseCIDRs("10.0.0.0/8,::1")
+
+	tests := []struct {
+		name      string
+		peer      string
+		forwarded []string
+		realIP    string
+		want      string
+	}{
+		{name: "untrusted peer ignores headers", peer: "203.0.113.9:4000", forwarded: []string{"198.51.100.1"}, realIP: "198.51.100.2", want: "203.0.113.9"},
+		{name: "trusted peer", peer: "10.0.0.5:4000", forwarded: []string{"198.51.100.1"}, want: "198.51.100.1"},
+		{name: "spoofed leftmost entry", peer: "10.0.0.5:4000", forwarded: []string{"1.2.3.4, 198.51.100.1, 10.0.0.6"}, want: "198.51.100.1"},
+		{name: "multiple headers", peer: "10.0.0.5:4000", forwarded: []string{"1.2.3.4", "198.51.100.1"}, want: "198.51.100.1"},
+		{name: "all hops trusted", peer: "10.0.0.5:4000", forwarded: []string{"10.1.1.1, 10.2.2.2"}, want: "10.1.1.1"},
+		{name: "garbage stops the walk", peer: "10.0.0.5:4000", forwarded: []string{"198.51.100.1, unknown, 10.0.0.6"}, want: "10.0.0.6"},
+		{name: "real ip fallback", peer: "10.0.0.5:4000", realIP: " 198.51.100.3 ", want: "198.51.100.3"},
+		{name: "no headers", peer: "10.0.0.5:4000", want: "10.0.0.5"},
+		{name: "ipv6 peer", peer: "[::1]:4000", forwarded: []string{"2001:db8::1"}, want: "2001:db8::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+			r.RemoteAddr = tt.peer
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			if got := clientIP(r); got.String() != tt.want {
+				t.Errorf("clientIP = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckClientIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		allow   string
+		deny    string
+		peer    string
+		allowed bool
+	}{
+		{name: "no rules", peer: "203.0.113.9:1", allowed: true},
+		{name: "in allow list", allow: "203.0.113.0/24, 198.51.100.7", peer: "203.0.113.9:1", allowed: true},
+		{name: "single allowed ip", allow: "203.0.113.0/24, 198.51.100.7", peer: "198.51.100.7:1", allowed: true},
+		{name: "outside allow list", allow: "203.0.113.0/24", peer: "198.51.100.8:1", allowed: false},
+		{name: "deny only", deny: "198.51.100.0/24", peer: "198.51.100.8:1", allowed: false},
+		{name: "deny wins over allow", allow: "198.51.100.0/24", deny: "198.51.100.8", peer: "198.51.100.8:1", allowed: false},
+		{name: "ipv6 allow", allow: "2001:db8::/32", peer: "[2001:db8::5]:1", allowed: true},
+		{name: "unparseable peer with allow list", allow: "203.0.113.0/24", peer: "pipe", allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useIPFilter(t, tt.allow, tt.deny)
+			r := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+			r.RemoteAddr = tt.peer
+			_, err := checkClientIP(r)
+			if (err == nil) != tt.allowed {
+				t.Errorf("checkClientIP error = %v, want allowed %v", err, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestLoadIPFilterErrors(t *testing.T) {
+	useIPFilter(t, "203.0.113.0/24", "")
+
+	for _, tt := range []struct{ allow, deny, want string }{
+		{allow: "203.0.113.0/33", want: "IP_ALLOWLIST"},
+		{allow: "localhost", want: "IP_ALLOWLIST"},
+		{deny: "198.51.100.1, nope", want: "IP_DENYLIST"},
+	} {
+		err := LoadIPFilter(tt.allow, tt.deny)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadIPFilter(%q, %q) error = %v, want %s", tt.allow, tt.deny, err, tt.want)
+		}
+	}
+	if !IPFilterEnabled() || len(activeIPFilter.Load().allow) != 1 {
+		t.Error("an invalid list replaced the current rules")
+	}
+}
+
+func TestIPFilterRejectsBeforeAuth(t *testing.T) {
+	useIPFilter(t, "", "192.0.2.0/24")
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	rec := httptest.NewRecorder()
+	newHandler(nil).ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), `"code":"ip_not_allowed"`) {
+		t.Errorf("status = %d, body %s; want 403 ip_not_allowed", rec.Code, rec.Body)
+	}
+}
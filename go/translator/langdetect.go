@@ -0,0 +1,293 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"unicode"
+)
+
+// 离线语种识别：先按文字系统（汉字/假名/谚文/西里尔/阿拉伯/泰文）判定，
+// 拉丁字母文本再结合高频词与特征字符打分。结果统一为语言注册表中的编码。
+
+type languageDetection struct {
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+	// variantAmbiguous 表示文本是中文但无法区分简体与繁体。
+	variantAmbiguous bool
+}
+
+type latinProfile struct {
+	Code  string
+	Words []string
+	Chars string
+}
+
+var latinProfiles = []latinProfile{
+	{Code: "en", Words: []string{"the", "and", "is", "are", "to", "of", "in", "that", "it", "you", "for", "with", "this", "was", "have", "not", "be", "on", "what", "how", "hello"}},
+	{Code: "de", Words: []string{"der", "die", "das", "und", "ist", "nicht", "ich", "sie", "es", "ein", "eine", "zu", "mit", "den", "von", "auf", "sind", "wie", "hallo"}, Chars: "äöüß"},
+	{Code: "fr", Words: []string{"le", "la", "les", "et", "est", "une", "des", "du", "que", "pas", "pour", "vous", "je", "nous", "dans", "ce", "qui", "sur", "bonjour"}, Chars: "éèêàçœëîû"},
+	{Code: "es", Words: []string{"el", "la", "los", "las", "y", "es", "que", "de", "en", "un", "una", "por", "para", "con", "no", "se", "está", "hola", "cómo"}, Chars: "ñ¿¡áíóú"},
+	{Code: "it", Words: []string{"il", "lo", "gli", "e", "è", "che", "di", "non", "un", "una", "per", "sono", "con", "della", "questo", "ciao", "come", "sei"}, Chars: "àèìòù"},
+	{Code: "pt", Words: []string{"o", "a", "os", "as", "e", "é", "que", "de", "não", "um", "uma", "para", "com", "em", "do", "da", "você", "olá", "está"}, Chars: "ãõçâê"},
+	{Code: "nl", Words: []string{"de", "het", "een", "en", "is", "niet", "van", "ik", "je", "dat", "zijn", "op", "te", "met", "voor", "hoe", "gaat"}},
+	{Code: "sv", Words: []string{"och", "är", "det", "att", "en", "som", "jag", "inte", "på", "med", "för", "har", "hur", "mår", "du"}, Chars: "åäö"},
+	{Code: "da", Words: []string{"og", "er", "det", "at", "en", "jeg", "ikke", "på", "med", "for", "har", "som", "hvad", "blev", "nogle", "meget"}, Chars: "æøå"},
+	{Code: "nb", Words: []string{"og", "er", "det", "at", "en", "jeg", "ikke", "på", "med", "for", "har", "som", "hva", "ble", "noen", "mye"}, Chars: "æøå"},
+	{Code: "fi", Words: []string{"ja", "on", "ei", "se", "että", "oli", "hän", "mutta", "kun", "minä", "sinä", "tämä", "mitä", "kuinka"}, Chars: "äö"},
+	{Code: "pl", Words: []string{"i", "w", "nie", "na", "się", "jest", "to", "że", "z", "do", "jak", "co", "czy"}, Chars: "ąćęłńśźż"},
+	{Code: "cs", Words: []string{"a", "je", "se", "na", "že", "to", "v", "s", "jak", "ale", "není", "jsem", "máš"}, Chars: "ěščřžůý"},
+	{Code: "hr", Words: []string{"i", "je", "u", "se", "na", "da", "su", "ne", "za", "od", "kako", "što", "si"}, Chars: "čćđšž"},
+	{Code: "hu", Words: []string{"a", "az", "és", "hogy", "nem", "egy", "van", "is", "de", "ez", "vagy", "hogyan"}, Chars: "őűáé"},
+	{Code: "ro", Words: []string{"și", "este", "în", "nu", "un", "o", "cu", "la", "pe", "care", "ce", "ești"}, Chars: "ăâîșțşţ"},
+	{Code: "tr", Words: []string{"ve", "bir", "bu", "da", "de", "için", "ne", "ile", "çok", "değil", "nasılsın", "merhaba"}, Chars: "çğışİ"},
+	{Code: "id", Words: []string{"dan", "yang", "di", "ini", "itu", "dengan", "untuk", "tidak", "dari", "ada", "saya", "bisa", "saja", "karena", "apa", "kabar"}},
+	{Code: "ms", Words: []string{"dan", "yang", "di", "ini", "itu", "dengan", "untuk", "tidak", "dari", "ada", "saya", "boleh", "sahaja", "kerana", "apa", "khabar"}},
+}
+
+var latinProfileWords = func() []map[string]struct{} {
+	sets := make([]map[string]struct{}, len(latinProfiles))
+	for i, profile := range latinProfiles {
+		sets[i] = make(map[string]struct{}, len(profile.Words))
+		for _, word := range profile.Words {
+			sets[i][word] = struct{}{}
+		}
+	}
+	return sets
+}()
+
+// zhVariantPairs 为"简体 繁体"字对，只收录两种写法互不通用的常用字。一简对多繁或在另一侧也通用的字，
+// 如 后/後、干/幹、里/裡、台/臺，只把仅见于繁体的写法列入 traditionalOnlyExtra。
+const zhVariantPairs = `
+这這 个個 们們 来來 时時 会會 说說 对對 国國 学學 为為 与與 从從 开開 关關 门門 问問 间間 电電 发發
+经經 过過 还還 实實 点點 车車 书書 长長 见見 东東 现現 话話 让讓 认認 应應 给給 爱愛 变變 吗嗎 么麼
+样樣 机機 体體 头頭 听聽 觉覺 读讀 写寫 气氣 万萬 动動 进進 种種 业業 总總 员員 华華 产產 无無 处處
+场場 边邊 义義 区區 网網 线線 务務 条條 报報 张張 论論 题題 记記 设設 计計 许許 语語 请請 谁誰 谢謝
+识識 该該 试試 调調 课課 讲講 议議 证證 评評 词詞 译譯 诉訴 误誤 诗詩 红紅 级級 约約 纪紀 组組 细細
+终終 结結 统統 绝絕 继繼 续續 练練 维維 编編 钱錢 铁鐵 银銀 错錯 钟鐘 锁鎖 镜鏡 饭飯 馆館 饮飲 饿餓
+马馬 鸟鳥 鱼魚 龙龍 贵貴 买買 卖賣 费費 资資 质質 贸貿 贴貼 货貨 财財 责責 页頁 顺順 须須 预預 领領
+颜顏 额額 顾顧 飞飛 风風 闭閉 闻聞 阅閱 陆陸 阳陽 阴陰 际際 队隊 随隨 险險 单單 师師 归歸 当當 岁歲
+历歷 亲親 视視 观觀 规規 览覽 讨討 优優 价價 传傳 伤傷 众眾 伟偉 备備 复復 复複 汉漢 满滿 济濟 浅淺
+温溫 湾灣 滚滾 灯燈 烧燒 热熱 爷爺 节節 苏蘇 药藥 艺藝 杂雜 难難 鸡雞 轻輕 较較 辆輛 转轉 输輸 医醫
+广廣 庆慶 兴興 举舉 农農 厅廳 厂廠 图圖 园園 围圍 团團 坏壞 块塊 坚堅 声聲 梦夢 妈媽 宝寶 导導 层層
+岛島 带帶 帮幫 库庫 录錄 怀懷 态態 战戰 护護 择擇 拥擁 挂掛 数數 断斷 术術 权權 极極 构構 检檢 欢歡
+汤湯 灭滅 状狀 独獨 献獻 环環 画畫 疗療 尽盡 盘盤 确確 礼禮 离離 积積 称稱 笔筆 简簡 类類 纸紙 罗羅
+联聯 肃肅 胜勝 脑腦 脸臉 舰艦 荣榮 获獲 营營 虽雖 虫蟲 补補 装裝 订訂 诚誠 谈談 贝貝 负負 败敗 账賬
+赵趙 赶趕 跃躍 运運 远遠 连連 选選 递遞 邮郵 邻鄰 钢鋼 闹鬧 阶階 隐隱 项項 饰飾 驾駕 验驗 鲜鮮 齐齊
+齿齒 龟龜 习習 乡鄉 乱亂 争爭 亚亞 亿億 仅僅 仓倉 伞傘 伦倫 侠俠 侦偵 俭儉 债債 倾傾 儿兒 党黨 兰蘭
+养養 兽獸 册冊 军軍 冯馮 决決 况況 冻凍 净淨 凉涼 减減 凤鳳 击擊 刘劉 则則 刚剛 创創 删刪 别別 剑劍
+剧劇 劝勸 办辦 励勵 劳勞 势勢 协協 卫衛 却卻 厉厲 压壓 厌厭 县縣 参參 双雙 叙敘 号號 叹嘆 吓嚇 启啟
+吴吳 呜嗚 响響 圆圓 圣聖 坛壇 执執 扩擴 扫掃 扬揚 扰擾 抢搶 担擔 拦攔 挤擠 挥揮 损損 换換 据據 摄攝
+摆擺 摇搖 敌敵 斩斬 旧舊 显顯 晓曉 暂暫 杀殺 杨楊 枪槍 柜櫃 标標 栏欄 树樹 桥橋 楼樓 欧歐 残殘 毁毀
+汇匯 沟溝 没沒 泪淚 泽澤 洁潔 测測 浓濃 涛濤 润潤 涨漲 渐漸 渔漁 湿濕 灵靈 炉爐 烂爛 烟煙 牵牽 犹猶
+猎獵 畅暢 疯瘋 盐鹽 监監 盖蓋 睁睜 矿礦 码碼 础礎 碍礙 祸禍 稳穩 穷窮 竞競 筑築 签簽 粮糧 紧緊 纯純
+纳納 纷紛 织織 绍紹 绕繞 绘繪 络絡 绩績 绪緒 绿綠 缘緣 缓緩 缩縮 罚罰 职職 聪聰 肤膚 胆膽 脏髒 脚腳
+腾騰 艰艱 苍蒼 荐薦 莲蓮 蓝藍 虑慮 虚虛 虾蝦 袜襪 袭襲 誉譽 训訓 访訪 诊診 诞誕 询詢 详詳 诸諸 谊誼
+谋謀 谓謂 谨謹 贡貢 贤賢 贩販 贪貪 贫貧 购購 贯貫 贷貸 贺賀 赋賦 赏賞 赔賠 赖賴 赚賺 赛賽 赞贊 赠贈
+赢贏 趋趨 践踐 踪蹤 轨軌 轮輪 软軟 轰轟 载載 辅輔 辈輩 辉輝 辑輯 辞辭 达達 迁遷 迈邁 违違 迟遲 逻邏
+遗遺 邓鄧 郑鄭 释釋 鉴鑒 针針 钥鑰 铃鈴 铜銅 铺鋪 链鏈 销銷 锅鍋 键鍵 镇鎮 闪閃 闲閒 阔闊 阵陣 陈陳
+雾霧 韩韓 顶頂 顽頑 顿頓 频頻 飘飄 饱飽 饼餅 驱驅 驶駛 驻駐 骂罵 骑騎 骗騙 鲁魯 鸣鳴 鸭鴨 龄齡
+`
+
+const traditionalOnlyExtra = "後裡裏麵隻臺颱餘係繫雲幹衝鬥範遊劃適幾"
+
+// simplifiedOnly / traditionalOnly 为只出现在简体或繁体文本中的字，用于按出现次数判断中文书写体系。
+var simplifiedOnly, traditionalOnly = func() (map[rune]bool, map[rune]bool) {
+	simplified, traditional := map[rune]bool{}, map[rune]bool{}
+	for _, pair := range strings.Fields(zhVariantPairs) {
+		runes := []rune(pair)
+		simplified[runes[0]] = true
+		traditional[runes[1]] = true
+	}
+	for _, r := range traditionalOnlyExtra {
+		traditional[r] = true
+	}
+	return simplified, traditional
+}()
+
+// detectLanguage 返回识别出的语种编码与置信度；无法判断时 Language 为空。
+func detectLanguage(text string) languageDetection {
+	var han, kana, hangul, cyrillic, arabic, thai, latin, total int
+	var traditional, simplified int
+	var ukrainianMarks, vietnameseMarks int
+
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		total++
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+			if traditionalOnly[r] {
+				traditional++
+			} else if simplifiedOnly[r] {
+				simplified++
+			}
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+			if strings.ContainsRune("іїєґІЇЄҐ", r) {
+				ukrainianMarks++
+			}
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		case unicode.Is(unicode.Thai, r):
+			thai++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+			if (r >= 0x1EA0 && r <= 0x1EF9) || strings.ContainsRune("ơưđăƠƯĐĂ", r) {
+				vietnameseMarks++
+			}
+		}
+	}
+
+	if total == 0 {
+		return languageDetection{}
+	}
+
+	ratio := func(count int) float64 {
+		return math.Round(float64(count)/float64(total)*100) / 100
+	}
+
+	switch {
+	case kana > 0 && kana+han >= latin:
+		return languageDetection{Language: "ja", Confidence: ratio(kana + han)}
+	case hangul > 0 && hangul >= latin && hangul >= han:
+		return languageDetection{Language: "ko", Confidence: ratio(hangul)}
+	case han > 0 && han >= latin:
+		return detectChineseVariant(simplified, traditional, ratio(han))
+	case cyrillic > 0 && cyrillic >= latin:
+		if ukrainianMarks > 0 {
+			return languageDetection{Language: "uk", Confidence: ratio(cyrillic)}
+		}
+		return languageDetection{Language: "ru", Confidence: ratio(cyrillic)}
+	case arabic > 0 && arabic >= latin:
+		return languageDetection{Language: "ar", Confidence: ratio(arabic)}
+	case thai > 0 && thai >= latin:
+		return languageDetection{Language: "th", Confidence: ratio(thai)}
+	case latin == 0:
+		return languageDetection{}
+	}
+
+	if vietnameseMarks > 0 && float64(vietnameseMarks)/float64(latin) > 0.03 {
+		return languageDetection{Language: "vi", Confidence: ratio(latin)}
+	}
+	return detectLatinLanguage(text, ratio(latin))
+}
+
+// detectChineseVariant 按简体、繁体特有字的出现次数区分 zh 与 zh-Hant：一方至少是另一方的三倍才下结论。
+// 没有特有字（如"中文"、"日本"）或两种写法混杂时标记为 variantAmbiguous，按 zh 返回并降低置信度。
+func detectChineseVariant(simplified, traditional int, scriptConfidence float64) languageDetection {
+	switch {
+	case traditional > 0 && traditional >= 3*simplified:
+		return languageDetection{Language: "zh-Hant", Confidence: scriptConfidence}
+	case simplified > 0 && simplified >= 3*traditional:
+		return languageDetection{Language: "zh", Confidence: scriptConfidence}
+	}
+	return languageDetection{Language: "zh", Confidence: math.Round(scriptConfidence*50) / 100, variantAmbiguous: true}
+}
+
+func detectLatinLanguage(text string, scriptConfidence float64) languageDetection {
+	lower := strings.ToLower(text)
+	words := strings.FieldsFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+
+	scores := make([]float64, len(latinProfiles))
+	for i, profile := range latinProfiles {
+		for _, word := range words {
+			if _, ok := latinProfileWords[i][word]; ok {
+				scores[i]++
+			}
+		}
+		for _, r := range profile.Chars {
+			scores[i] += 0.5 * float64(strings.Count(lower, string(r)))
+		}
+	}
+
+	best, bestScore, sum := -1, 0.0, 0.0
+	for i, score := range scores {
+		sum += score
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best == -1 {
+		// 没有任何特征时按最常见的英语处理，并给出较低置信度。
+		return languageDetection{Language: "en", Confidence: math.Round(scriptConfidence*30) / 100}
+	}
+	confidence := scriptConfidence * bestScore / sum
+	if len(words) > 0 {
+		coverage := bestScore / float64(len(words))
+		if coverage > 1 {
+			coverage = 1
+		}
+		confidence = confidence*0.7 + coverage*0.3
+	}
+	return languageDetection{Language: latinProfiles[best].Code, Confidence: math.Round(confidence*100) / 100}
+}
+
+type detectRequest struct {
+	Input interface{} `json:"input"`
+}
+
+type detectResult struct {
+	Index      int     `json:"index"`
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+}
+
+func (s *Handler) handleDetect(ctx context.Context, w http.ResponseWriter, body []byte) {
+	var req detectRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeAPIError(ctx, w, newAPIError("invalidJson"))
+		return
+	}
+
+	var inputs []string
+	switch val := req.Input.(type) {
+	case string:
+		inputs = []string{val}
+	case []interface{}:
+		for _, item := range val {
+			if text, ok := item.(string); ok {
+				inputs = append(inputs, text)
+			} else {
+				inputs = append(inputs, extractTextFromContent(item))
+			}
+		}
+	default:
+		if text := extractTextFromContent(val); text != "" {
+			inputs = []string{text}
+		}
+	}
+	if len(inputs) == 0 {
+		writeAPIError(ctx, w, newAPIError("noMessage").withParam("input"))
+		return
+	}
+
+	_, detectSpan := s.tracer.start(ctx, "language.detect", spanKindInternal)
+	data := make([]detectResult, 0, len(inputs))
+	for i, text := range inputs {
+		detection := detectLanguage(text)
+		data = append(data, detectResult{Index: i, Language: detection.Language, Confidence: detection.Confidence})
+	}
+	detectSpan.setAttr("language.detect.inputs", len(inputs))
+	detectSpan.end()
+
+	writeJSON(w, http.StatusOK, listResponse[detectResult]{Object: "list", Data: data})
+}
+
+// detectSourceLanguage 仅在调用方未指定源语言时执行识别，用于在响应中回填 detected_source_language。
+func detectSourceLanguage(options translationOptions, userContent interface{}) string {
+	if options.SourceLanguage != nil {
+		return ""
+	}
+	return detectLanguage(stringifyUserContent(userContent)).Language
+}
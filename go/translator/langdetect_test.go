@@ -0,0 +1,65 @@
+package translator
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		want      string
+		ambiguous bool
+	}{
+		{name: "empty", text: "", want: ""},
+		{name: "digits and punctuation", text: "12345 !?", want: ""},
+		{name: "english", text: "Hello, how are you today?", want: "en"},
+		{name: "german", text: "Ich weiß nicht, wie es dir geht.", want: "de"},
+		{name: "french", text: "Bonjour, je ne sais pas où est la gare.", want: "fr"},
+		{name: "spanish", text: "Hola, ¿cómo está usted?", want: "es"},
+		{name: "vietnamese", text: "Xin chào, bạn có khỏe không?", want: "vi"},
+		{name: "japanese with kanji", text: "今日は天気がいいですね", want: "ja"},
+		{name: "korean", text: "안녕하세요, 만나서 반갑습니다", want: "ko"},
+		{name: "russian", text: "Привет, как дела?", want: "ru"},
+		{name: "ukrainian", text: "Привіт, як справи? Це їхній дім.", want: "uk"},
+		{name: "arabic", text: "مرحبا، كيف حالك؟", want: "ar"},
+		{name: "thai", text: "สวัสดีครับ คุณสบายดีไหม", want: "th"},
+		{name: "simplified", text: "这个问题我们明天再讨论。", want: "zh"},
+		{name: "traditional", text: "這個問題我們明天再討論。", want: "zh-Hant"},
+		{name: "traditional beyond the common set", text: "請幫我預訂兩張車票，謝謝。", want: "zh-Hant"},
+		{name: "traditional with a stray simplified char", text: "臺灣的颱風季節從六月開始，請記得準備這些東西：电池", want: "zh-Hant"},
+		{name: "no variant-specific chars", text: "中文", want: "zh", ambiguous: true},
+		{name: "evenly mixed", text: "这個", want: "zh", ambiguous: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := detectLanguage(tt.text)
+			if got.Language != tt.want || got.variantAmbiguous != tt.ambiguous {
+				t.Errorf("detectLanguage(%q) = %q (ambiguous %v), want %q (ambiguous %v)",
+					tt.text, got.Language, got.variantAmbiguous, tt.want, tt.ambiguous)
+			}
+			if tt.want != "" && (got.Confidence <= 0 || got.Confidence > 1) {
+				t.Errorf("detectLanguage(%q) confidence = %v, want (0, 1]", tt.text, got.Confidence)
+			}
+		})
+	}
+}
+
+func TestDetectChineseVariantConfidence(t *testing.T) {
+	decided := detectLanguage("这个问题我们明天再讨论")
+	ambiguous := detectLanguage("中文")
+	if ambiguous.Confidence >= decided.Confidence {
+		t.Errorf("ambiguous confidence %v, want below %v", ambiguous.Confidence, decided.Confidence)
+	}
+}
+
+func TestZhVariantTables(t *testing.T) {
+	for r := range simplifiedOnly {
+		if traditionalOnly[r] {
+			t.Errorf("%q is listed as both simplified-only and traditional-only", r)
+		}
+	}
+	for _, r := range "后干里台中文日本" {
+		if simplifiedOnly[r] || traditionalOnly[r] {
+			t.Errorf("%q is shared by both scripts but listed as variant-specific", r)
+		}
+	}
+}
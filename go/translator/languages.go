@@ -0,0 +1,254 @@
+package translator
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// 语言注册表：数据来自 data/languages.json（可通过 LANGUAGE_REGISTRY_FILE 替换），
+// 负责 BCP-47 标签解析、别名匹配，以及区域/文字变体到豆包支持编码的映射。
+
+//go:embed data/languages.json
+var embeddedLanguageRegistry []byte
+
+type languageEntry struct {
+	Code       string            `json:"code"`
+	Name       string            `json:"name"`
+	NativeName string            `json:"native_name"`
+	ZhName     string            `json:"zh_name"`
+	Supported  bool              `json:"supported"`
+	Aliases    []string          `json:"aliases"`
+	Scripts    map[string]string `json:"scripts,omitempty"`
+	Regions    map[string]string `json:"regions,omitempty"`
+}
+
+type languageRegistry struct {
+	entries []languageEntry
+	byCode  map[string]*languageEntry
+	byAlias map[string]*languageEntry
+}
+
+// activeLanguages 为生效的语言注册表。替换时整体换入新的注册表，进行中的请求继续使用已取得的那一份。
+var activeLanguages atomic.Pointer[languageRegistry]
+
+func init() {
+	activeLanguages.Store(mustParseLanguageRegistry(embeddedLanguageRegistry))
+}
+
+func currentLanguages() *languageRegistry {
+	return activeLanguages.Load()
+}
+
+func mustParseLanguageRegistry(data []byte) *languageRegistry {
+	registry, err := parseLanguageRegistry(data)
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded language registry: %v", err))
+	}
+	return registry
+}
+
+// LoadLanguageRegistry 用指定文件替换内置语言注册表；替换是原子的，可在运行期间调用。
+func LoadLanguageRegistry(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	registry, err := parseLanguageRegistry(data)
+	if err != nil {
+		return err
+	}
+	activeLanguages.Store(registry)
+	return nil
+}
+
+func parseLanguageRegistry(data []byte) (*languageRegistry, error) {
+	var file struct {
+		Languages []languageEntry `json:"languages"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Languages) == 0 {
+		return nil, fmt.Errorf("no languages defined")
+	}
+
+	registry := &languageRegistry{
+		entries: file.Languages,
+		byCode:  map[string]*languageEntry{},
+		byAlias: map[string]*languageEntry{},
+	}
+	for i := range registry.entries {
+		entry := &registry.entries[i]
+		if entry.Code == "" {
+			return nil, fmt.Errorf("language entry %d has no code", i)
+		}
+		key := strings.ToLower(entry.Code)
+		if _, exists := registry.byCode[key]; exists {
+			return nil, fmt.Errorf("duplicate language code %q", entry.Code)
+		}
+		registry.byCode[key] = entry
+	}
+	for i := range registry.entries {
+		entry := &registry.entries[i]
+		names := append([]string{entry.Name, entry.NativeName, entry.ZhName}, entry.Aliases...)
+		for _, name := range names {
+			key := strings.ToLower(strings.TrimSpace(name))
+			if key == "" {
+				continue
+			}
+			if _, isCode := registry.byCode[key]; isCode {
+				continue
+			}
+			if _, exists := registry.byAlias[key]; !exists {
+				registry.byAlias[key] = entry
+			}
+		}
+	}
+	for _, entry := range registry.entries {
+		for _, target := range entry.Scripts {
+			if _, ok := registry.byCode[strings.ToLower(target)]; !ok {
+				return nil, fmt.Errorf("language %q maps script to unknown code %q", entry.Code, target)
+			}
+		}
+		for _, target := range entry.Regions {
+			if _, ok := registry.byCode[strings.ToLower(target)]; !ok {
+				return nil, fmt.Errorf("language %q maps region to unknown code %q", entry.Code, target)
+			}
+		}
+	}
+	return registry, nil
+}
+
+type languageTag struct {
+	Language string
+	Script   string
+	Region   string
+}
+
+// parseLanguageTag 按 BCP-47 结构拆分标签（兼容 "_" 分隔），并规范大小写。
+func parseLanguageTag(tag string) (languageTag, bool) {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"), "-")
+	if len(parts) == 0 || !isAlphaSubtag(parts[0], 2, 3) {
+		return languageTag{}, false
+	}
+	result := languageTag{Language: strings.ToLower(parts[0])}
+	for _, part := range parts[1:] {
+		switch {
+		case result.Script == "" && result.Region == "" && isAlphaSubtag(part, 4, 4):
+			result.Script = strings.ToUpper(part[:1]) + strings.ToLower(part[1:])
+		case result.Region == "" && (isAlphaSubtag(part, 2, 2) || isDigitSubtag(part, 3)):
+			result.Region = strings.ToUpper(part)
+		case isAlphaSubtag(part, 3, 3) && result.Script == "" && result.Region == "":
+			// extlang（如 zh-yue）：以扩展语言子标签为准
+			result.Language = strings.ToLower(part)
+		case len(part) == 0 || len(part) > 8:
+			return languageTag{}, false
+		}
+	}
+	return result, true
+}
+
+func isAlphaSubtag(s string, minLen, maxLen int) bool {
+	if len(s) < minLen || len(s) > maxLen {
+		return false
+	}
+	for _, r := range s {
+		if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigitSubtag(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+type languageError struct {
+	Input       string
+	Unsupported *languageEntry
+}
+
+func (e *languageError) Error() string {
+	if e.Unsupported != nil {
+		if strings.EqualFold(e.Input, e.Unsupported.Code) {
+			return fmt.Sprintf("豆包翻译模型暂不支持该语言：%s", e.Input)
+		}
+		return fmt.Sprintf("豆包翻译模型暂不支持该语言：%s（%s）", e.Input, e.Unsupported.Code)
+	}
+	return fmt.Sprintf("无法识别的语言：%s", e.Input)
+}
+
+// resolve 将名称或 BCP-47 标签解析为豆包支持的语言编码。
+func (reg *languageRegistry) resolve(input string) (string, error) {
+	trimmed := strings.TrimSpace(input)
+	if trimmed == "" {
+		return "", &languageError{Input: input}
+	}
+	key := strings.ToLower(trimmed)
+
+	var entry *languageEntry
+	if found, ok := reg.byCode[key]; ok {
+		entry = found
+	} else if found, ok := reg.byAlias[key]; ok {
+		entry = found
+	} else if tag, ok := parseLanguageTag(trimmed); ok {
+		if base := reg.lookupSubtag(tag.Language); base != nil {
+			entry = base
+			if target, ok := base.Scripts[tag.Script]; tag.Script != "" && ok {
+				entry = reg.byCode[strings.ToLower(target)]
+			} else if target, ok := base.Regions[tag.Region]; tag.Script == "" && tag.Region != "" && ok {
+				entry = reg.byCode[strings.ToLower(target)]
+			}
+		}
+	}
+
+	if entry == nil {
+		return "", &languageError{Input: trimmed}
+	}
+	if !entry.Supported {
+		return "", &languageError{Input: trimmed, Unsupported: entry}
+	}
+	return entry.Code, nil
+}
+
+func (reg *languageRegistry) lookupSubtag(subtag string) *languageEntry {
+	if entry, ok := reg.byCode[subtag]; ok {
+		return entry
+	}
+	if entry, ok := reg.byAlias[subtag]; ok {
+		return entry
+	}
+	return nil
+}
+
+func (s *Handler) handleLanguages(w http.ResponseWriter, r *http.Request) {
+	onlySupported := parseStreamFlag(r.URL.Query().Get("supported"))
+	registry := currentLanguages()
+	data := make([]languageEntry, 0, len(registry.entries))
+	for _, entry := range registry.entries {
+		if onlySupported && !entry.Supported {
+			continue
+		}
+		data = append(data, entry)
+	}
+	writeJSON(w, http.StatusOK, listResponse[languageEntry]{Object: "list", Data: data})
+}
+
+// ResolveLanguage 按 HTTP 接口相同的规则将语言名称、编码或 BCP-47 标签解析为豆包编码。
+func ResolveLanguage(value string) (string, error) {
+	return currentLanguages().resolve(value)
+}
@@ -0,0 +1,139 @@
+package translator
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLanguageTag(t *testing.T) {
+	tests := []struct {
+		tag    string
+		want   languageTag
+		wantOK bool
+	}{
+		{tag: "en", want: languageTag{Language: "en"}, wantOK: true},
+		{tag: "zh-hant-tw", want: languageTag{Language: "zh", Script: "Hant", Region: "TW"}, wantOK: true},
+		{tag: "ZH_hans", want: languageTag{Language: "zh", Script: "Hans"}, wantOK: true},
+		{tag: "pt-BR", want: languageTag{Language: "pt", Region: "BR"}, wantOK: true},
+		{tag: "es-419", want: languageTag{Language: "es", Region: "419"}, wantOK: true},
+		{tag: "zh-yue", want: languageTag{Language: "yue"}, wantOK: true},
+		{tag: "en-US-x-private", want: languageTag{Language: "en", Region: "US"}, wantOK: true},
+		{tag: "", wantOK: false},
+		{tag: "e", wantOK: false},
+		{tag: "english", wantOK: false},
+		{tag: "en--US", wantOK: false},
+		{tag: "en-abcdefghi", wantOK: false},
+		{tag: "12", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, ok := parseLanguageTag(tt.tag)
+			if ok != tt.wantOK || (ok && got != tt.want) {
+				t.Errorf("parseLanguageTag(%q) = %+v, %v; want %+v, %v", tt.tag, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestLanguageRegistryResolve(t *testing.T) {
+	tests := []struct {
+		input           string
+		want            string
+		wantUnsupported string
+		wantUnknown     bool
+	}{
+		{input: "zh", want: "zh"},
+		{input: "ZH-HANT", want: "zh-Hant"},
+		{input: "zh-Hant-HK", want: "zh-Hant"},
+		{input: "zh-TW", want: "zh-Hant"},
+		{input: "zh-CN", want: "zh"},
+		{input: "zh-Hans-TW", want: "zh"},
+		{input: "繁體中文", want: "zh-Hant"},
+		{input: " Simplified Chinese ", want: "zh"},
+		{input: "en-GB", want: "en"},
+		{input: "eng", want: "en"},
+		{input: "pt-BR", want: "pt"},
+		{input: "no", want: "nb"},
+		{input: "日本語", want: "ja"},
+		{input: "af", wantUnsupported: "af"},
+		{input: "Afrikaans", wantUnsupported: "af"},
+		{input: "af-ZA", wantUnsupported: "af"},
+		{input: "", wantUnknown: true},
+		{input: "klingon", wantUnknown: true},
+		{input: "xx-YY", wantUnknown: true},
+	}
+	registry := currentLanguages()
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := registry.resolve(tt.input)
+			var langErr *languageError
+			switch {
+			case tt.want != "":
+				if err != nil || got != tt.want {
+					t.Errorf("resolve(%q) = %q, %v; want %q", tt.input, got, err, tt.want)
+				}
+			case !errors.As(err, &langErr):
+				t.Errorf("resolve(%q) = %q, %v; want a languageError", tt.input, got, err)
+			case tt.wantUnknown && langErr.Unsupported != nil:
+				t.Errorf("resolve(%q) reported unsupported %q, want unknown", tt.input, langErr.Unsupported.Code)
+			case tt.wantUnsupported != "" && (langErr.Unsupported == nil || langErr.Unsupported.Code != tt.wantUnsupported):
+				t.Errorf("resolve(%q) error %v, want unsupported %q", tt.input, err, tt.wantUnsupported)
+			}
+		})
+	}
+}
+
+func TestParseLanguageRegistryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "invalid json", data: `{`, want: "unexpected end"},
+		{name: "empty", data: `{"languages":[]}`, want: "no languages"},
+		{name: "missing code", data: `{"languages":[{"name":"X"}]}`, want: "has no code"},
+		{name: "duplicate code", data: `{"languages":[{"code":"en"},{"code":"EN"}]}`, want: "duplicate language code"},
+		{name: "unknown script target", data: `{"languages":[{"code":"zh","scripts":{"Hant":"zh-Hant"}}]}`, want: "unknown code"},
+		{name: "unknown region target", data: `{"languages":[{"code":"pt","regions":{"BR":"pt-BR"}}]}`, want: "unknown code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseLanguageRegistry([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("parseLanguageRegistry error = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLanguageRegistrySwap(t *testing.T) {
+	previous := currentLanguages()
+	t.Cleanup(func() { activeLanguages.Store(previous) })
+
+	path := filepath.Join(t.TempDir(), "languages.json")
+	data := `{"languages":[{"code":"en","name":"English","supported":true},{"code":"tlh","name":"Klingon","supported":true}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadLanguageRegistry(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, err := currentLanguages().resolve("klingon"); err != nil || code != "tlh" {
+		t.Errorf("resolve(klingon) = %q, %v after swap", code, err)
+	}
+	if _, err := previous.resolve("klingon"); err == nil {
+		t.Error("the previous registry was modified by the swap")
+	}
+
+	rec := httptest.NewRecorder()
+	newHandler(nil).handleLanguages(rec, httptest.NewRequest(http.MethodGet, "/v1/languages?supported=true", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `"code":"tlh"`) || strings.Contains(body, `"code":"zh"`) {
+		t.Errorf("/v1/languages does not list the swapped registry: %s", body)
+	}
+}
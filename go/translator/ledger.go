@@ -0,0 +1,383 @@
+package translator
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// 用量台账：每次上游翻译完成后记录一条 UsageRecord（调用方身份、模型、语言对、token 与字符数、按价格表计算的费用），
+// 以 JSONL 追加写入 CONFIG.UsageLedgerFile；启动时读回历史记录，在内存中按 天/身份/模型/语言对 汇总，供 /admin/usage 查询。
+// 费用在写入时计算并随记录保存，之后修改价格表不会改变历史费用。同语种直通不调用上游，不计入台账。
+
+type UsageRecord struct {
+	Time           time.Time `json:"time"`
+	Key            string    `json:"key"`
+	Model          string    `json:"model"`
+	SourceLanguage string    `json:"source_language"`
+	TargetLanguage string    `json:"target_language"`
+	InputTokens    int       `json:"input_tokens"`
+	OutputTokens   int       `json:"output_tokens"`
+	TotalTokens    int       `json:"total_tokens"`
+	InputChars     int       `json:"input_characters"`
+	OutputChars    int       `json:"output_characters"`
+	Cost           float64   `json:"cost"`
+}
+
+// ModelPrice 为单个模型的单价；字符价格按输入（原文）字符数计费。
+type ModelPrice struct {
+	InputPerMillionTokens  float64 `json:"input_per_million_tokens"`
+	OutputPerMillionTokens float64 `json:"output_per_million_tokens"`
+	PerMillionCharacters   float64 `json:"per_million_characters"`
+}
+
+// PriceTable 的 Models 以模型名为键，支持 path.Match 通配（如 "doubao-seed-translation-*"），精确匹配优先。
+type PriceTable struct {
+	Currency string                `json:"currency"`
+	Models   map[string]ModelPrice `json:"models"`
+}
+
+// activePriceTable 为生效的价格表，LoadPriceTable 整体替换。
+var activePriceTable atomic.Pointer[PriceTable]
+
+func init() {
+	activePriceTable.Store(&PriceTable{Currency: "CNY"})
+}
+
+func currentPriceTable() *PriceTable {
+	return activePriceTable.Load()
+}
+
+// LoadPriceTable 从 JSON 文件加载价格表，原子地替换当前价格表。
+func LoadPriceTable(file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	var table PriceTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	for pattern := range table.Models {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%s: invalid model pattern %q: %w", file, pattern, err)
+		}
+	}
+	if table.Currency == "" {
+		table.Currency = "CNY"
+	}
+	activePriceTable.Store(&table)
+	return nil
+}
+
+// price 返回模型的单价：先精确匹配，再按模式长度从长到短尝试通配。
+func (t PriceTable) price(model string) (ModelPrice, bool) {
+	if p, ok := t.Models[model]; ok {
+		return p, true
+	}
+	patterns := make([]string, 0, len(t.Models))
+	for pattern := range t.Models {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, model); ok {
+			return t.Models[pattern], true
+		}
+	}
+	return ModelPrice{}, false
+}
+
+func (p ModelPrice) cost(record UsageRecord) float64 {
+	cost := (float64(record.InputTokens)*p.InputPerMillionTokens +
+		float64(record.OutputTokens)*p.OutputPerMillionTokens +
+		float64(record.InputChars)*p.PerMillionCharacters) / 1e6
+	return roundCost(cost)
+}
+
+func roundCost(cost float64) float64 {
+	return math.Round(cost*1e6) / 1e6
+}
+
+// usageMeta 保存计入台账所需的请求信息，由各入口在解析出翻译选项后放入 context；
+// 同一请求可能从多个分支上报用量（例如流式 completed 事件与补发的结束事件），只记录第一次。
+type usageMeta struct {
+	model          string
+	sourceLanguage string
+	targetLanguage string
+	inputChars     int
+	once           sync.Once
+	// discarded 累计因占位符不一致而丢弃、重新请求的上游响应的用量，计入最终记录。
+	discarded doubaoUsage
+}
+
+// addDiscardedUsage 记下被丢弃的上游响应的用量，这些调用同样计费。
+func addDiscardedUsage(ctx context.Context, usage *doubaoUsage) {
+	meta, _ := ctx.Value(usageMetaKey{}).(*usageMeta)
+	if meta == nil || usage == nil {
+		return
+	}
+	meta.discarded.InputTokens += usageInputTokens(usage)
+	meta.discarded.OutputTokens += usageOutputTokens(usage)
+	meta.discarded.TotalTokens += usageTotalTokens(usage)
+}
+
+type usageMetaKey struct{}
+
+func withUsageMeta(ctx context.Context, model string, options translationOptions, detectedSource, text string) context.Context {
+	source := detectedSource
+	if options.SourceLanguage != nil && *options.SourceLanguage != "" {
+		source = *options.SourceLanguage
+	}
+	if source == "" {
+		source = "auto"
+	}
+	return context.WithValue(ctx, usageMetaKey{}, &usageMeta{
+		model:          model,
+		sourceLanguage: source,
+		targetLanguage: options.TargetLanguage,
+		inputChars:     utf8.RuneCountInString(text),
+	})
+}
+
+// recordUsage 在台账开启时写入当前请求的用量记录。
+func (s *Handler) recordUsage(ctx context.Context, inputTokens, outputTokens, totalTokens int, outputText string) {
+	meta, _ := ctx.Value(usageMetaKey{}).(*usageMeta)
+	if s.ledger == nil || meta == nil {
+		return
+	}
+	meta.once.Do(func() {
+		key := "anonymous"
+		if identity, ok := IdentityFromContext(ctx); ok && identity.Name != "" {
+			key = identity.Name
+		}
+		if totalTokens == 0 {
+			totalTokens = inputTokens + outputTokens
+		}
+		inputTokens += meta.discarded.InputTokens
+		outputTokens += meta.discarded.OutputTokens
+		totalTokens += meta.discarded.TotalTokens
+		record := UsageRecord{
+			Time:           time.Now().UTC(),
+			Key:            key,
+			Model:          meta.model,
+			SourceLanguage: meta.sourceLanguage,
+			TargetLanguage: meta.targetLanguage,
+			InputTokens:    inputTokens,
+			OutputTokens:   outputTokens,
+			TotalTokens:    totalTokens,
+			InputChars:     meta.inputChars,
+			OutputChars:    utf8.RuneCountInString(outputText),
+		}
+		if price, ok := currentPriceTable().price(record.Model); ok {
+			record.Cost = price.cost(record)
+		}
+		s.ledger.append(record)
+	})
+}
+
+// OpenUsageLedger 开启用量台账：读回 path 中已有的记录用于汇总，之后的记录追加写入同一文件。
+func (s *Handler) OpenUsageLedger(path string) error {
+	ledger := &usageLedger{totals: map[usageGroup]*usageTotals{}}
+	if file, err := os.Open(path); err == nil {
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		line := 0
+		for scanner.Scan() {
+			line++
+			if len(strings.TrimSpace(scanner.Text())) == 0 {
+				continue
+			}
+			var record UsageRecord
+			if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+				log.Printf("skipping invalid usage record %s:%d: %v", path, line, err)
+				continue
+			}
+			ledger.add(record)
+		}
+		err := scanner.Err()
+		file.Close()
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	ledger.file = file
+	s.ledger = ledger
+	return nil
+}
+
+type usageGroup struct {
+	Day            string
+	Key            string
+	Model          string
+	SourceLanguage string
+	TargetLanguage string
+}
+
+type usageTotals struct {
+	Requests     int     `json:"requests"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	TotalTokens  int     `json:"total_tokens"`
+	InputChars   int     `json:"input_characters"`
+	OutputChars  int     `json:"output_characters"`
+	Cost         float64 `json:"cost"`
+}
+
+func (t *usageTotals) merge(other usageTotals) {
+	t.Requests += other.Requests
+	t.InputTokens += other.InputTokens
+	t.OutputTokens += other.OutputTokens
+	t.TotalTokens += other.TotalTokens
+	t.InputChars += other.InputChars
+	t.OutputChars += other.OutputChars
+	t.Cost = roundCost(t.Cost + other.Cost)
+}
+
+type usageLedger struct {
+	mu     sync.Mutex
+	file   *os.File
+	totals map[usageGroup]*usageTotals
+}
+
+func (l *usageLedger) add(record UsageRecord) {
+	group := usageGroup{
+		Day:            record.Time.UTC().Format(time.DateOnly),
+		Key:            record.Key,
+		Model:          record.Model,
+		SourceLanguage: record.SourceLanguage,
+		TargetLanguage: record.TargetLanguage,
+	}
+	totals := l.totals[group]
+	if totals == nil {
+		totals = &usageTotals{}
+		l.totals[group] = totals
+	}
+	totals.merge(usageTotals{
+		Requests:     1,
+		InputTokens:  record.InputTokens,
+		OutputTokens: record.OutputTokens,
+		TotalTokens:  record.TotalTokens,
+		InputChars:   record.InputChars,
+		OutputChars:  record.OutputChars,
+		Cost:         record.Cost,
+	})
+}
+
+func (l *usageLedger) append(record UsageRecord) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("failed to encode usage record: %v", err)
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.add(record)
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		log.Printf("failed to write usage record: %v", err)
+	}
+}
+
+func (l *usageLedger) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
+
+// usageDimensions 为 /admin/usage 支持的分组维度；language_pair 同时输出源语言与目标语言两列。
+var usageDimensions = []string{"day", "key", "model", "language_pair"}
+
+type usageQuery struct {
+	GroupBy []string
+	// From / To 为 UTC 日期（YYYY-MM-DD），包含两端，为空表示不限。
+	From  string
+	To    string
+	Key   string
+	Model string
+}
+
+type usageRow struct {
+	Day            string `json:"day,omitempty"`
+	Key            string `json:"key,omitempty"`
+	Model          string `json:"model,omitempty"`
+	SourceLanguage string `json:"source_language,omitempty"`
+	TargetLanguage string `json:"target_language,omitempty"`
+	usageTotals
+}
+
+// query 按分组维度汇总满足过滤条件的用量，返回按分组字段排序的结果与总计。
+func (l *usageLedger) query(q usageQuery) ([]usageRow, usageTotals) {
+	grouped := map[usageGroup]*usageTotals{}
+	var total usageTotals
+	l.mu.Lock()
+	for group, totals := range l.totals {
+		if (q.From != "" && group.Day < q.From) || (q.To != "" && group.Day > q.To) ||
+			(q.Key != "" && group.Key != q.Key) || (q.Model != "" && group.Model != q.Model) {
+			continue
+		}
+		var target usageGroup
+		for _, dimension := range q.GroupBy {
+			switch dimension {
+			case "day":
+				target.Day = group.Day
+			case "key":
+				target.Key = group.Key
+			case "model":
+				target.Model = group.Model
+			case "language_pair":
+				target.SourceLanguage, target.TargetLanguage = group.SourceLanguage, group.TargetLanguage
+			}
+		}
+		if grouped[target] == nil {
+			grouped[target] = &usageTotals{}
+		}
+		grouped[target].merge(*totals)
+		total.merge(*totals)
+	}
+	l.mu.Unlock()
+
+	rows := make([]usageRow, 0, len(grouped))
+	for group, totals := range grouped {
+		rows = append(rows, usageRow{
+			Day:            group.Day,
+			Key:            group.Key,
+			Model:          group.Model,
+			SourceLanguage: group.SourceLanguage,
+			TargetLanguage: group.TargetLanguage,
+			usageTotals:    *totals,
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		for _, pair := range [][2]string{{a.Day, b.Day}, {a.Key, b.Key}, {a.Model, b.Model},
+			{a.SourceLanguage, b.SourceLanguage}, {a.TargetLanguage, b.TargetLanguage}} {
+			if pair[0] != pair[1] {
+				return pair[0] < pair[1]
+			}
+		}
+		return false
+	})
+	return rows, total
+}
@@ -0,0 +1,289 @@
+package translator
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"doubao/mockupstream"
+)
+
+// usePriceTable 加载价格表，测试结束后恢复原价格表。
+func usePriceTable(t *testing.T, data string) error {
+	t.Helper()
+	previous := activePriceTable.Load()
+	t.Cleanup(func() { activePriceTable.Store(previous) })
+	file := filepath.Join(t.TempDir(), "prices.json")
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return LoadPriceTable(file)
+}
+
+func TestPriceTableMatch(t *testing.T) {
+	table := PriceTable{Models: map[string]ModelPrice{
+		"doubao-seed-translation-250915": {InputPerMillionTokens: 1},
+		"doubao-seed-translation-*":      {InputPerMillionTokens: 2},
+		"doubao-*":                       {InputPerMillionTokens: 3},
+	}}
+	tests := []struct {
+		model string
+		want  float64
+		ok    bool
+	}{
+		{model: "doubao-seed-translation-250915", want: 1, ok: true},
+		{model: "doubao-seed-translation-latest", want: 2, ok: true},
+		{model: "doubao-pro", want: 3, ok: true},
+		{model: "gpt-4o", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			price, ok := table.price(tt.model)
+			if ok != tt.ok || price.InputPerMillionTokens != tt.want {
+				t.Errorf("price(%q) = %v, %v; want %v, %v", tt.model, price.InputPerMillionTokens, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestModelPriceCost(t *testing.T) {
+	tests := []struct {
+		name   string
+		price  ModelPrice
+		record UsageRecord
+		want   float64
+	}{
+		{name: "tokens", price: ModelPrice{InputPerMillionTokens: 1.2, OutputPerMillionTokens: 3.6},
+			record: UsageRecord{InputTokens: 1000, OutputTokens: 500}, want: 0.003},
+		{name: "characters", price: ModelPrice{PerMillionCharacters: 20},
+			record: UsageRecord{InputChars: 12, OutputChars: 100}, want: 0.00024},
+		{name: "rounded to micro units", price: ModelPrice{InputPerMillionTokens: 1},
+			record: UsageRecord{InputTokens: 1}, want: 0.000001},
+		{name: "below rounding", price: ModelPrice{InputPerMillionTokens: 0.4},
+			record: UsageRecord{InputTokens: 1}, want: 0},
+		{name: "free", record: UsageRecord{InputTokens: 1000}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.price.cost(tt.record); got != tt.want {
+				t.Errorf("cost = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPriceTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantErr  string
+		currency string
+	}{
+		{name: "default currency", data: `{"models":{"doubao-*":{"input_per_million_tokens":1}}}`, currency: "CNY"},
+		{name: "explicit currency", data: `{"currency":"USD","models":{}}`, currency: "USD"},
+		{name: "invalid json", data: `{"models":`, wantErr: "unexpected end of JSON input"},
+		{name: "invalid pattern", data: `{"models":{"doubao-[":{}}}`, wantErr: "invalid model pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := usePriceTable(t, tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				if currentPriceTable().Currency != "CNY" {
+					t.Errorf("failed load replaced the price table")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := currentPriceTable().Currency; got != tt.currency {
+				t.Errorf("currency = %q, want %q", got, tt.currency)
+			}
+		})
+	}
+}
+
+func TestUsageLedgerQuery(t *testing.T) {
+	day1 := time.Date(2025, 3, 1, 23, 30, 0, 0, time.UTC)
+	day2 := day1.Add(time.Hour)
+	ledger := &usageLedger{totals: map[usageGroup]*usageTotals{}}
+	for _, record := range []UsageRecord{
+		{Time: day1, Key: "alice", Model: "m1", SourceLanguage: "en", TargetLanguage: "zh", TotalTokens: 10, Cost: 0.1},
+		{Time: day1, Key: "alice", Model: "m1", SourceLanguage: "en", TargetLanguage: "zh", TotalTokens: 20, Cost: 0.2},
+		{Time: day1, Key: "bob", Model: "m2", SourceLanguage: "ja", TargetLanguage: "zh", TotalTokens: 5, Cost: 0.05},
+		{Time: day2, Key: "alice", Model: "m2", SourceLanguage: "en", TargetLanguage: "fr", TotalTokens: 7, Cost: 0.07},
+	} {
+		ledger.add(record)
+	}
+
+	tests := []struct {
+		name  string
+		query usageQuery
+		want  []string
+		total int
+	}{
+		{name: "by key", query: usageQuery{GroupBy: []string{"key"}}, want: []string{"|alice|||:37", "|bob|||:5"}, total: 42},
+		{name: "by day and model", query: usageQuery{GroupBy: []string{"day", "model"}},
+			want: []string{"2025-03-01||m1||:30", "2025-03-01||m2||:5", "2025-03-02||m2||:7"}, total: 42},
+		{name: "by language pair", query: usageQuery{GroupBy: []string{"language_pair"}},
+			want: []string{"|||en|fr:7", "|||en|zh:30", "|||ja|zh:5"}, total: 42},
+		{name: "no grouping", query: usageQuery{}, want: []string{"||||:42"}, total: 42},
+		{name: "date range", query: usageQuery{GroupBy: []string{"day"}, From: "2025-03-02", To: "2025-03-02"},
+			want: []string{"2025-03-02||||:7"}, total: 7},
+		{name: "key and model filter", query: usageQuery{GroupBy: []string{"model"}, Key: "alice", Model: "m2"},
+			want: []string{"||m2||:7"}, total: 7},
+		{name: "no match", query: usageQuery{GroupBy: []string{"key"}, Key: "carol"}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, total := ledger.query(tt.query)
+			got := make([]string, 0, len(rows))
+			for _, row := range rows {
+				got = append(got, fmt.Sprintf("%s|%s|%s|%s|%s:%d",
+					row.Day, row.Key, row.Model, row.SourceLanguage, row.TargetLanguage, row.TotalTokens))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("rows = %v, want %v", got, tt.want)
+			}
+			if total.TotalTokens != tt.total {
+				t.Errorf("total tokens = %d, want %d", total.TotalTokens, tt.total)
+			}
+		})
+	}
+
+	_, total := ledger.query(usageQuery{})
+	if total.Requests != 4 || total.Cost != 0.42 {
+		t.Errorf("total = %+v, want 4 requests costing 0.42", total)
+	}
+}
+
+func TestOpenUsageLedger(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "usage.jsonl")
+	existing := `{"time":"2025-03-01T10:00:00Z","key":"alice","model":"m1","total_tokens":10,"cost":0.1}
+
+not json
+{"time":"2025-03-01T11:00:00Z","key":"alice","model":"m1","total_tokens":5,"cost":0.05}
+`
+	if err := os.WriteFile(file, []byte(existing), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := newHandler(nil)
+	if err := handler.OpenUsageLedger(file); err != nil {
+		t.Fatal(err)
+	}
+	_, total := handler.ledger.query(usageQuery{})
+	if total.Requests != 2 || total.TotalTokens != 15 {
+		t.Fatalf("reloaded total = %+v, want 2 requests and 15 tokens", total)
+	}
+
+	handler.ledger.append(UsageRecord{Time: time.Now(), Key: "bob", Model: "m2", TotalTokens: 3})
+	handler.Close()
+
+	reopened := newHandler(nil)
+	if err := reopened.OpenUsageLedger(file); err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	if _, total := reopened.ledger.query(usageQuery{}); total.Requests != 3 || total.TotalTokens != 18 {
+		t.Errorf("total after reopen = %+v, want 3 requests and 18 tokens", total)
+	}
+}
+
+func TestRecordUsageOnce(t *testing.T) {
+	if err := usePriceTable(t, `{"models":{"m1":{"input_per_million_tokens":1000,"output_per_million_tokens":2000}}}`); err != nil {
+		t.Fatal(err)
+	}
+	handler := newHandler(nil)
+	if err := handler.OpenUsageLedger(filepath.Join(t.TempDir(), "usage.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+
+	source := "en"
+	ctx := withIdentity(context.Background(), Identity{Name: "alice"})
+	ctx = withUsageMeta(ctx, "m1", translationOptions{SourceLanguage: &source, TargetLanguage: "zh"}, "", "Hello")
+	handler.recordUsage(ctx, 10, 20, 0, "你好")
+	handler.recordUsage(ctx, 10, 20, 0, "你好")
+	// 没有 usageMeta 的请求不计入台账。
+	handler.recordUsage(context.Background(), 10, 20, 0, "")
+
+	rows, total := handler.ledger.query(usageQuery{GroupBy: []string{"key", "model", "language_pair"}})
+	if len(rows) != 1 || total.Requests != 1 {
+		t.Fatalf("rows = %+v, want a single request", rows)
+	}
+	row := rows[0]
+	if row.Key != "alice" || row.Model != "m1" || row.SourceLanguage != "en" || row.TargetLanguage != "zh" {
+		t.Errorf("row = %+v", row)
+	}
+	if row.TotalTokens != 30 || row.InputChars != 5 || row.OutputChars != 2 || row.Cost != 0.05 {
+		t.Errorf("row totals = %+v, want 30 tokens, 5/2 characters, cost 0.05", row.usageTotals)
+	}
+}
+
+func TestAdminUsage(t *testing.T) {
+	previous := CONFIG.AdminAPIKey
+	defer func() { CONFIG.AdminAPIKey = previous }()
+	CONFIG.AdminAPIKey = "admin-secret"
+
+	handler, _ := newMockHandler(t, mockupstream.Options{})
+	if err := handler.OpenUsageLedger(filepath.Join(t.TempDir(), "usage.jsonl")); err != nil {
+		t.Fatal(err)
+	}
+	defer handler.Close()
+	if rec := serveJSON(handler, "/v1/chat/completions", chatBody("doubao-seed-translation", "Hello", false)); rec.Code != http.StatusOK {
+		t.Fatalf("translate status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		token  string
+		status int
+	}{
+		{name: "json", query: "?group_by=model", token: "admin-secret", status: http.StatusOK},
+		{name: "csv", query: "?group_by=model,language_pair&format=csv", token: "admin-secret", status: http.StatusOK},
+		{name: "wrong key", token: "test-key", status: http.StatusUnauthorized},
+		{name: "unknown dimension", query: "?group_by=region", token: "admin-secret", status: http.StatusBadRequest},
+		{name: "invalid date", query: "?from=yesterday", token: "admin-secret", status: http.StatusBadRequest},
+		{name: "unknown format", query: "?format=xml", token: "admin-secret", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/usage"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			switch tt.name {
+			case "json":
+				var resp usageResponse
+				decodeJSON(t, rec.Body.String(), &resp)
+				if len(resp.Data) != 1 || resp.Data[0].Model != "doubao-seed-translation" || resp.Total.Requests != 1 || resp.Currency != "CNY" {
+					t.Errorf("response = %+v", resp)
+				}
+			case "csv":
+				records, err := csv.NewReader(rec.Body).ReadAll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(records) != 2 || strings.Join(records[0][:3], ",") != "model,source_language,target_language" ||
+					records[1][0] != "doubao-seed-translation" || records[1][len(records[1])-1] != "CNY" {
+					t.Errorf("csv = %v", records)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package translator
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 请求指标与最近错误：ServeHTTP 结束时按路由累计请求数、状态码与耗时；writeAPIError 与流式中断时记录最近的错误。
+// 两者只保存在内存中，重启后清零，供 /admin/metrics、/admin/errors 与内置控制台查看。
+
+type routeMetrics struct {
+	Route        string           `json:"route"`
+	Requests     int64            `json:"requests"`
+	Errors       int64            `json:"errors"`
+	Status       map[string]int64 `json:"status"`
+	AvgLatencyMs float64          `json:"avg_latency_ms"`
+	totalLatency time.Duration
+}
+
+type requestMetrics struct {
+	mu      sync.Mutex
+	started time.Time
+	routes  map[string]*routeMetrics
+}
+
+func newRequestMetrics() *requestMetrics {
+	return &requestMetrics{started: time.Now(), routes: map[string]*routeMetrics{}}
+}
+
+// metricsRoute 将请求路径归并为有限的路由名，避免任意路径撑大指标表。
+func metricsRoute(path string) string {
+	switch {
+	case routeMethods[path] != "":
+		return path
+	case strings.HasPrefix(path, "/admin/"):
+		return "/admin/*"
+	case path == "/ui" || strings.HasPrefix(path, "/ui/"):
+		return "/ui/*"
+	default:
+		return "other"
+	}
+}
+
+func (m *requestMetrics) observe(path string, status int, elapsed time.Duration) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	route := metricsRoute(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	metrics := m.routes[route]
+	if metrics == nil {
+		metrics = &routeMetrics{Route: route, Status: map[string]int64{}}
+		m.routes[route] = metrics
+	}
+	metrics.Requests++
+	if status >= http.StatusBadRequest {
+		metrics.Errors++
+	}
+	metrics.Status[strconv.Itoa(status)]++
+	metrics.totalLatency += elapsed
+}
+
+type metricsResponse struct {
+	StartedAt     time.Time        `json:"started_at"`
+	UptimeSeconds int64            `json:"uptime_seconds"`
+	InFlight      int              `json:"in_flight"`
+	Upstream      upstreamSettings `json:"upstream"`
+	Routes        []routeMetrics   `json:"routes"`
+}
+
+func (m *requestMetrics) snapshot() []routeMetrics {
+	m.mu.Lock()
+	routes := make([]routeMetrics, 0, len(m.routes))
+	for _, metrics := range m.routes {
+		copied := *metrics
+		copied.Status = make(map[string]int64, len(metrics.Status))
+		for status, count := range metrics.Status {
+			copied.Status[status] = count
+		}
+		copied.AvgLatencyMs = float64(metrics.totalLatency.Microseconds()) / 1000 / float64(metrics.Requests)
+		routes = append(routes, copied)
+	}
+	m.mu.Unlock()
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Route < routes[j].Route })
+	return routes
+}
+
+// recentErrorLimit 为保留的最近错误条数；recentErrorArgChars 为错误信息中每段动态文本保留的字符数。
+const (
+	recentErrorLimit    = 100
+	recentErrorArgChars = 80
+)
+
+type recentError struct {
+	Time     time.Time `json:"time"`
+	Method   string    `json:"method,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Identity string    `json:"identity,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Status   int       `json:"status"`
+	Code     string    `json:"code"`
+	Message  string    `json:"message"`
+}
+
+// recentErrorLog 为每个 Handler 独立的最近错误环形缓冲，由 ServeHTTP 放入 context。
+type recentErrorLog struct {
+	mu      sync.Mutex
+	entries []recentError
+}
+
+func newRecentErrorLog() *recentErrorLog {
+	return &recentErrorLog{}
+}
+
+type recentErrorsKey struct{}
+
+func withRecentErrors(ctx context.Context, log *recentErrorLog) context.Context {
+	return context.WithValue(ctx, recentErrorsKey{}, log)
+}
+
+// recordRecentError 记录一条错误；请求信息取自进行中请求的登记项，路由之前的错误（如 404、IP 拒绝）不记录。
+// 错误信息中的动态文本（上游返回的错误等）可能包含用户内容，按审计日志的 mask 与 truncate 方式脱敏后保存。
+func recordRecentError(ctx context.Context, apiErr *apiError) {
+	errorLog, _ := ctx.Value(recentErrorsKey{}).(*recentErrorLog)
+	entry, ok := ctx.Value(inflightKey{}).(inflightEntry)
+	if errorLog == nil || !ok {
+		return
+	}
+	entry.tracker.mu.Lock()
+	record := recentError{
+		Time:     time.Now().UTC(),
+		Method:   entry.request.Method,
+		Path:     entry.request.Path,
+		Identity: entry.request.Identity,
+		Model:    entry.request.Model,
+	}
+	entry.tracker.mu.Unlock()
+	record.Status = apiErr.status()
+	record.Code = apiErr.template().Code
+	record.Message = redactedErrorMessage(apiErr, localeFromContext(ctx))
+
+	errorLog.mu.Lock()
+	defer errorLog.mu.Unlock()
+	errorLog.entries = append(errorLog.entries, record)
+	if len(errorLog.entries) > recentErrorLimit {
+		errorLog.entries = errorLog.entries[len(errorLog.entries)-recentErrorLimit:]
+	}
+}
+
+// redactedErrorMessage 渲染错误信息，其中的字符串参数先遮盖 PII，再截断到 recentErrorArgChars 个字符。
+func redactedErrorMessage(apiErr *apiError, locale string) string {
+	redacted := *apiErr
+	redacted.Args = make([]interface{}, len(apiErr.Args))
+	for i, arg := range apiErr.Args {
+		if text, ok := arg.(string); ok {
+			text = maskPII(text)
+			if utf8.RuneCountInString(text) > recentErrorArgChars {
+				text = string([]rune(text)[:recentErrorArgChars]) + "…"
+			}
+			arg = text
+		}
+		redacted.Args[i] = arg
+	}
+	return redacted.message(locale)
+}
+
+// snapshot 返回最近的错误，最新的在前；identity 非空时只返回该身份的错误。
+func (l *recentErrorLog) snapshot(identity string) []recentError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entries := make([]recentError, 0, len(l.entries))
+	for i := len(l.entries) - 1; i >= 0; i-- {
+		if identity == "" || l.entries[i].Identity == identity {
+			entries = append(entries, l.entries[i])
+		}
+	}
+	return entries
+}
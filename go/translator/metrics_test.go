@@ -0,0 +1,88 @@
+package translator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"doubao/mockupstream"
+)
+
+func TestDashboard(t *testing.T) {
+	previous := CONFIG.Dashboard
+	defer func() { CONFIG.Dashboard = previous }()
+	if previous {
+		t.Fatal("Dashboard should default to false")
+	}
+	handler := newHandler(nil)
+
+	tests := []struct {
+		name    string
+		enabled bool
+		method  string
+		path    string
+		status  int
+	}{
+		{name: "disabled", enabled: false, method: http.MethodGet, path: "/ui/", status: http.StatusNotFound},
+		{name: "index", enabled: true, method: http.MethodGet, path: "/ui/", status: http.StatusOK},
+		{name: "asset", enabled: true, method: http.MethodGet, path: "/ui/app.js", status: http.StatusOK},
+		{name: "redirect", enabled: true, method: http.MethodGet, path: "/ui", status: http.StatusMovedPermanently},
+		{name: "post", enabled: true, method: http.MethodPost, path: "/ui/", status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			CONFIG.Dashboard = tt.enabled
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && !strings.Contains(rec.Header().Get("Content-Security-Policy"), "default-src 'self'") {
+				t.Errorf("missing Content-Security-Policy: %v", rec.Header())
+			}
+		})
+	}
+}
+
+func TestRecentErrorsAndMetrics(t *testing.T) {
+	useAdminKey(t)
+	handler, _ := newMockHandler(t, mockupstream.Options{})
+	serveJSON(handler, "/v1/chat/completions", chatBody("m", "Hello", false))
+	serveJSON(handler, "/v1/chat/completions", chatBody("mock-500", "Hello", false))
+	serveJSON(handler, "/v1/chat/completions", chatBody("mock-429", "Hello", true))
+
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{name: "errors", path: "/admin/errors", want: []string{`"model":"mock-429"`, `"model":"mock-500"`, `"identity":"key:`}},
+		{name: "errors by identity", path: "/admin/errors?identity=vk:other", want: []string{`"data":[]`}},
+		{name: "metrics", path: "/admin/metrics", want: []string{`"route":"/v1/chat/completions","requests":3,"errors":2`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveAdmin(handler, http.MethodGet, tt.path, "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body %s does not contain %s", rec.Body.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactedErrorMessage(t *testing.T) {
+	long := strings.Repeat("x", recentErrorArgChars+20)
+	got := redactedErrorMessage(newAPIError("upstream", long), "en")
+	if !strings.Contains(got, strings.Repeat("x", recentErrorArgChars)+"…") || strings.Contains(got, strings.Repeat("x", recentErrorArgChars+1)) {
+		t.Errorf("message = %q, want the argument truncated to %d characters", got, recentErrorArgChars)
+	}
+	if got := redactedErrorMessage(newAPIError("upstream", "bad input from a@b.cn"), "en"); got != "Upstream API error: bad input from [EMAIL]" {
+		t.Errorf("message = %q, want the email masked", got)
+	}
+}
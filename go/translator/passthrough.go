@@ -0,0 +1,143 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 同语种直通：源语言（显式指定或离线识别）与目标语言一致时不调用上游，
+// 原样返回输入文本，usage 记为 0，并以 passthrough=true 标记。
+
+const passthroughMinConfidence = 0.6
+
+// passthroughSource 判断是否应跳过上游调用，命中时返回判定出的源语言。
+func passthroughSource(options translationOptions, userContent interface{}, overrides ...interface{}) (string, bool) {
+	enabled := CONFIG.SkipSameLanguage
+	for _, src := range overrides {
+		if candidate := extractCandidate(src); candidate != nil {
+			if raw, ok := candidate["skip_same_language"]; ok {
+				enabled = parseStreamFlag(raw)
+			}
+		}
+	}
+	if !enabled {
+		return "", false
+	}
+
+	source := ""
+	if options.SourceLanguage != nil {
+		source = *options.SourceLanguage
+	} else {
+		detection := detectLanguage(stringifyUserContent(userContent))
+		// 分不清简繁的中文可能与目标写法不同，照常翻译。
+		if detection.Confidence < passthroughMinConfidence || detection.variantAmbiguous {
+			return "", false
+		}
+		source = detection.Language
+	}
+	if source == "" || !strings.EqualFold(source, options.TargetLanguage) {
+		return "", false
+	}
+	return source, true
+}
+
+func (s *Handler) writeChatPassthrough(ctx context.Context, w http.ResponseWriter, model, text, source string, isStream, includeUsage bool) {
+	spanFromContext(ctx).setAttr("translation.passthrough", true)
+	id := genID("chatcmpl")
+	created := time.Now().Unix()
+	usage := &chatUsage{}
+
+	if !isStream {
+		writeJSON(w, http.StatusOK, chatCompletion{
+			ID:      id,
+			Object:  "chat.completion",
+			Created: created,
+			Model:   model,
+			Choices: []chatChoice{
+				{Message: chatMessage{Role: "assistant", Content: text}, FinishReason: "stop"},
+			},
+			Usage:                  *usage,
+			DetectedSourceLanguage: source,
+			Passthrough:            true,
+		})
+		return
+	}
+
+	newChunk := func(choices ...chatChunkChoice) chatCompletionChunk {
+		if choices == nil {
+			choices = []chatChunkChoice{}
+		}
+		return chatCompletionChunk{
+			ID:          id,
+			Object:      "chat.completion.chunk",
+			Created:     created,
+			Model:       model,
+			Choices:     choices,
+			Usage:       chunkUsage{include: includeUsage},
+			Passthrough: true,
+		}
+	}
+
+	roleChunk := newChunk(chatChunkChoice{Delta: chatDelta{Role: "assistant"}})
+	roleChunk.DetectedSourceLanguage = source
+
+	events := []chatCompletionChunk{roleChunk}
+	if text != "" {
+		events = append(events, newChunk(chatChunkChoice{Delta: chatDelta{Content: text}}))
+	}
+	stop := "stop"
+	events = append(events, newChunk(chatChunkChoice{FinishReason: &stop}))
+	if includeUsage {
+		usageChunk := newChunk()
+		usageChunk.Usage.usage = usage
+		events = append(events, usageChunk)
+	}
+
+	writePassthroughStream(w, func(out io.Writer) error {
+		for _, event := range events {
+			data, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintf(out, "data: %s\n\n", data); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(out, "data: [DONE]\n\n")
+		return err
+	})
+}
+
+func (s *Handler) writeResponsesPassthrough(ctx context.Context, w http.ResponseWriter, model, text, source string, isStream bool) {
+	spanFromContext(ctx).setAttr("translation.passthrough", true)
+	state := newResponsesStreamState(model, source)
+	state.passthrough = true
+	state.text.WriteString(text)
+	state.usage = map[string]interface{}{"input_tokens": 0, "output_tokens": 0, "total_tokens": 0}
+
+	if !isStream {
+		writeJSON(w, http.StatusOK, state.completedResponse())
+		return
+	}
+	emitSyntheticResponseEvents(newResponsesEventWriter(w), state)
+}
+
+func writePassthroughStream(w http.ResponseWriter, write func(io.Writer) error) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	if err := write(w); err != nil {
+		log.Printf("failed to write passthrough stream: %v", err)
+		return
+	}
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,121 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPassthroughSource(t *testing.T) {
+	english, chinese := "en", "zh"
+	tests := []struct {
+		name       string
+		enabled    bool
+		source     *string
+		target     string
+		text       string
+		overrides  []interface{}
+		wantSource string
+		wantOK     bool
+	}{
+		{name: "disabled", target: "en", text: "Hello, how are you today?"},
+		{name: "detected match", enabled: true, target: "en", text: "Hello, how are you today?", wantSource: "en", wantOK: true},
+		{name: "detected mismatch", enabled: true, target: "fr", text: "Hello, how are you today?"},
+		{name: "target case-insensitive", enabled: true, target: "ZH-hant", text: "這個問題我們明天再討論", wantSource: "zh-Hant", wantOK: true},
+		{name: "explicit source", enabled: true, source: &english, target: "en", text: "bonjour", wantSource: "en", wantOK: true},
+		{name: "explicit source mismatch", enabled: true, source: &chinese, target: "en", text: "Hello"},
+		{name: "low confidence", enabled: true, target: "en", text: "ok"},
+		{name: "ambiguous chinese variant", enabled: true, target: "zh", text: "中文"},
+		{name: "simplified into traditional", enabled: true, target: "zh-Hant", text: "这个问题我们明天再讨论"},
+		{
+			name: "enabled per request", target: "en", text: "Hello, how are you today?",
+			overrides:  []interface{}{map[string]interface{}{"skip_same_language": true}},
+			wantSource: "en", wantOK: true,
+		},
+		{
+			name: "disabled per request", enabled: true, target: "en", text: "Hello, how are you today?",
+			overrides: []interface{}{map[string]interface{}{"translation_options": map[string]interface{}{"skip_same_language": "false"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := CONFIG.SkipSameLanguage
+			CONFIG.SkipSameLanguage = tt.enabled
+			defer func() { CONFIG.SkipSameLanguage = previous }()
+
+			options := translationOptions{SourceLanguage: tt.source, TargetLanguage: tt.target}
+			source, ok := passthroughSource(options, tt.text, tt.overrides...)
+			if source != tt.wantSource || ok != tt.wantOK {
+				t.Errorf("passthroughSource = %q, %v; want %q, %v", source, ok, tt.wantSource, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestPassthroughResponses(t *testing.T) {
+	previous := CONFIG.SkipSameLanguage
+	CONFIG.SkipSameLanguage = true
+	defer func() { CONFIG.SkipSameLanguage = previous }()
+	// 直通不应访问上游，上游桩被调用即判定失败。
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("upstream called for a passthrough request: %s", r.URL)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer upstream.Close()
+
+	text := "Hello, how are you today?"
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		wantBody []string
+	}{
+		{
+			name:     "chat",
+			path:     "/v1/chat/completions",
+			body:     `{"model":"m","messages":[{"role":"system","content":"target_language: en"},{"role":"user","content":"` + text + `"}]}`,
+			wantBody: []string{`"content":"` + text + `"`, `"passthrough":true`, `"total_tokens":0`},
+		},
+		{
+			name:     "chat stream",
+			path:     "/v1/chat/completions",
+			body:     `{"model":"m","stream":true,"messages":[{"role":"system","content":"target_language: en"},{"role":"user","content":"` + text + `"}]}`,
+			wantBody: []string{`"detected_source_language":"en"`, `"content":"` + text + `"`, `"finish_reason":"stop"`, "data: [DONE]\n\n"},
+		},
+		{
+			name:     "responses",
+			path:     "/v1/responses",
+			body:     `{"model":"m","input":[{"role":"system","content":"target_language: en"},{"role":"user","content":"` + text + `"}]}`,
+			wantBody: []string{`"text":"` + text + `"`, `"passthrough":true`, `"status":"completed"`},
+		},
+		{
+			name:     "responses stream",
+			path:     "/v1/responses",
+			body:     `{"model":"m","stream":true,"input":[{"role":"system","content":"target_language: en"},{"role":"user","content":"` + text + `"}]}`,
+			wantBody: []string{"event: response.created\n", "event: response.output_text.delta\n", "event: response.completed\n"},
+		},
+	}
+	srv := newHandler(nil)
+	srv.baseURL = upstream.URL
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer test-key")
+			rec := httptest.NewRecorder()
+			srv.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+			}
+			for _, want := range tt.wantBody {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body does not contain %q:\n%s", want, rec.Body)
+				}
+			}
+			if !strings.Contains(tt.body, `"stream":true`) && !json.Valid(rec.Body.Bytes()) {
+				t.Errorf("non-stream body is not JSON: %s", rec.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,242 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// 个人信息（PII）识别：邮箱、电话、居民身份证号与银行卡号，以及 PII_PATTERNS_FILE 中的自定义检测器。
+// 基于正则并辅以校验位检查，识别结果按出现位置排序且互不重叠。发往上游前按 PII_MASKING 替换为占位符
+//（见 placeholder.go）、按 PII_REJECT 拒绝请求；审计日志的 mask 脱敏也使用同一组检测器。
+
+const (
+	PIIEmail      = "email"
+	PIIPhone      = "phone"
+	PIIIDNumber   = "id_number"
+	PIICreditCard = "credit_card"
+)
+
+// spanDetector 用正则识别一类文本片段，PII 与不翻译片段（protect.go）共用。
+type spanDetector struct {
+	class   string
+	pattern *regexp.Regexp
+	// valid 对正则命中的文本做进一步校验（如 Luhn、身份证校验位），为空表示不校验。
+	valid func(string) bool
+}
+
+// builtinPIIDetectors 按优先级排列：同一位置被多个检测器命中时取前者，较长的数字串（身份证、银行卡）优先于电话。
+var builtinPIIDetectors = []spanDetector{
+	{class: PIIEmail, pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)},
+	{class: PIIIDNumber, pattern: regexp.MustCompile(`\b\d{17}[\dXx]\b`), valid: validIDNumber},
+	{class: PIICreditCard, pattern: regexp.MustCompile(`\b\d(?:[ \-]?\d){12,18}\b`), valid: validCardNumber},
+	{class: PIIPhone, pattern: regexp.MustCompile(`(?:\+86[ \-]?)?\b1[3-9]\d{9}\b|\+\d{1,3}[ \-]?\(?\d{1,4}\)?(?:[ \-]?\d{2,4}){2,3}\b`)},
+}
+
+// piiPolicy 为一次 LoadPIIPolicy 的结果：内置与自定义检测器，以及遮盖、拒绝的类别（为空表示不遮盖 / 不拒绝）。
+// 每次加载都构造新的 piiPolicy 后整体替换，重复加载不会累积检测器，进行中的请求继续使用旧策略。
+type piiPolicy struct {
+	detectors     []spanDetector
+	maskClasses   []string
+	rejectClasses []string
+}
+
+var activePIIPolicy atomic.Pointer[piiPolicy]
+
+func init() {
+	activePIIPolicy.Store(&piiPolicy{detectors: builtinPIIDetectors})
+}
+
+func currentPIIPolicy() *piiPolicy {
+	return activePIIPolicy.Load()
+}
+
+func (p *piiPolicy) hasClass(class string) bool {
+	return slices.ContainsFunc(p.detectors, func(d spanDetector) bool { return d.class == class })
+}
+
+// piiPattern 为 PII_PATTERNS_FILE 中的一条自定义检测器。
+type piiPattern struct {
+	Class   string `json:"class"`
+	Pattern string `json:"pattern"`
+}
+
+var piiClassName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// LoadPIIPolicy 加载自定义检测器（按文件中的顺序排在内置检测器之后），并解析逗号分隔的遮盖与拒绝类别；
+// "all" 表示全部类别。类别名无效时返回错误并保留原有策略，避免因拼写错误而把 PII 发给上游；可重复调用以重新加载。
+func LoadPIIPolicy(mask, reject, patternsFile string) error {
+	policy := &piiPolicy{detectors: slices.Clone(builtinPIIDetectors)}
+	if patternsFile != "" {
+		data, err := os.ReadFile(patternsFile)
+		if err != nil {
+			return err
+		}
+		var patterns []piiPattern
+		if err := json.Unmarshal(data, &patterns); err != nil {
+			return fmt.Errorf("%s: %w", patternsFile, err)
+		}
+		for i, p := range patterns {
+			if !piiClassName.MatchString(p.Class) {
+				return fmt.Errorf("%s: entry %d: invalid class %q", patternsFile, i, p.Class)
+			}
+			if isProtectedSpanClass(p.Class) || policy.hasClass(p.Class) {
+				return fmt.Errorf("%s: entry %d: duplicate class %q", patternsFile, i, p.Class)
+			}
+			pattern, err := regexp.Compile(p.Pattern)
+			if err != nil {
+				return fmt.Errorf("%s: entry %d: %w", patternsFile, i, err)
+			}
+			policy.detectors = append(policy.detectors, spanDetector{class: p.Class, pattern: pattern})
+		}
+	}
+	var err error
+	if policy.maskClasses, err = policy.parseClasses(mask); err != nil {
+		return fmt.Errorf("PII_MASKING: %w", err)
+	}
+	if policy.rejectClasses, err = policy.parseClasses(reject); err != nil {
+		return fmt.Errorf("PII_REJECT: %w", err)
+	}
+	activePIIPolicy.Store(policy)
+	return nil
+}
+
+// PIIClasses 返回生效的遮盖与拒绝类别，供启动日志使用。
+func PIIClasses() (mask, reject []string) {
+	policy := currentPIIPolicy()
+	return policy.maskClasses, policy.rejectClasses
+}
+
+func (p *piiPolicy) parseClasses(value string) ([]string, error) {
+	var classes []string
+	for _, class := range splitList(strings.ToLower(value)) {
+		if class == "all" {
+			classes = classes[:0]
+			for _, detector := range p.detectors {
+				classes = append(classes, detector.class)
+			}
+			return classes, nil
+		}
+		if !p.hasClass(class) {
+			return nil, fmt.Errorf("unknown PII class %q", class)
+		}
+		if !slices.Contains(classes, class) {
+			classes = append(classes, class)
+		}
+	}
+	return classes, nil
+}
+
+// rejectPII 在文本包含 PII_REJECT 中的类别时返回 piiRejected 错误，错误与日志中只列出类别。
+func rejectPII(text string) error {
+	policy := currentPIIPolicy()
+	if len(policy.rejectClasses) == 0 {
+		return nil
+	}
+	var found []string
+	for _, span := range findSpans(text, policy.detectors, policy.rejectClasses) {
+		if !slices.Contains(found, span.Class) {
+			found = append(found, span.Class)
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	log.Printf("rejected request containing PII: %s", strings.Join(found, ", "))
+	return newAPIError("piiRejected", strings.Join(found, ", "))
+}
+
+// findPII 返回 text 中 classes 指定类别（为空表示全部）的 PII，按起始位置排序且互不重叠。
+func findPII(text string, classes []string) []textSpan {
+	return findSpans(text, currentPIIPolicy().detectors, classes)
+}
+
+// findSpans 依次运行 detectors 中 classes 指定类别（为空表示全部）的检测器。
+func findSpans(text string, detectors []spanDetector, classes []string) []textSpan {
+	var matches []textSpan
+	for _, detector := range detectors {
+		if len(classes) > 0 && !slices.Contains(classes, detector.class) {
+			continue
+		}
+		for _, loc := range detector.pattern.FindAllStringIndex(text, -1) {
+			if detector.valid != nil && !detector.valid(text[loc[0]:loc[1]]) {
+				continue
+			}
+			matches = append(matches, textSpan{Start: loc[0], End: loc[1], Class: detector.class})
+		}
+	}
+	// 先按起始位置，再按检测器优先级（detectors 顺序）排序，之后丢弃与已选区间重叠的命中。
+	order := map[string]int{}
+	for i, detector := range detectors {
+		order[detector.class] = i
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return order[matches[i].Class] < order[matches[j].Class]
+	})
+	selected := matches[:0]
+	end := -1
+	for _, m := range matches {
+		if m.Start < end {
+			continue
+		}
+		selected = append(selected, m)
+		end = m.End
+	}
+	return selected
+}
+
+// maskPII 将 PII 替换为 [EMAIL]、[PHONE] 等类别标记。
+func maskPII(text string) string {
+	matches := findPII(text, nil)
+	if len(matches) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		b.WriteString(text[last:m.Start])
+		b.WriteString("[" + strings.ToUpper(m.Class) + "]")
+		last = m.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// validCardNumber 去掉空格与连字符后检查长度（13–19 位）与 Luhn 校验。
+func validCardNumber(value string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(value)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+// validIDNumber 按 GB 11643 校验 18 位居民身份证号的最后一位。
+func validIDNumber(value string) bool {
+	weights := [17]int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(value[i]-'0') * w
+	}
+	return "10X98765432"[sum%11] == strings.ToUpper(value)[17]
+}
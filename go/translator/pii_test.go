@@ -0,0 +1,168 @@
+package translator
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"doubao/mockupstream"
+)
+
+func TestMaskPII(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "email", text: "mail alice.w@example.com now", want: "mail [EMAIL] now"},
+		{name: "mobile", text: "call 13812345678", want: "call [PHONE]"},
+		{name: "mobile with country code", text: "call +86 13812345678", want: "call [PHONE]"},
+		{name: "international phone", text: "call +1 (415) 555 0100", want: "call [PHONE]"},
+		{name: "id number", text: "id 11010519491231002X", want: "id [ID_NUMBER]"},
+		{name: "id number with bad check digit", text: "id 110105194912310021", want: "id 110105194912310021"},
+		{name: "card", text: "card 4111 1111 1111 1111", want: "card [CREDIT_CARD]"},
+		{name: "card failing luhn", text: "card 4111 1111 1111 1112", want: "card 4111 1111 1111 1112"},
+		{name: "short number", text: "order 12345", want: "order 12345"},
+		{name: "several", text: "a@b.cn, 13912345678", want: "[EMAIL], [PHONE]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := maskPII(tt.text); got != tt.want {
+				t.Errorf("maskPII(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindPIIClasses(t *testing.T) {
+	text := "a@b.cn 13912345678"
+	tests := []struct {
+		classes []string
+		want    []string
+	}{
+		{classes: nil, want: []string{PIIEmail, PIIPhone}},
+		{classes: []string{PIIPhone}, want: []string{PIIPhone}},
+		{classes: []string{PIICreditCard}, want: nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, m := range findPII(text, tt.classes) {
+			got = append(got, m.Class)
+		}
+		if len(got) != len(tt.want) || (len(got) > 0 && (got[0] != tt.want[0] || got[len(got)-1] != tt.want[len(tt.want)-1])) {
+			t.Errorf("findPII(%v) = %v, want %v", tt.classes, got, tt.want)
+		}
+	}
+}
+
+func TestLoadPIIPolicyReload(t *testing.T) {
+	t.Cleanup(func() { activePIIPolicy.Store(&piiPolicy{detectors: builtinPIIDetectors}) })
+	patterns := filepath.Join(t.TempDir(), "patterns.json")
+	if err := os.WriteFile(patterns, []byte(`[{"class":"employee_id","pattern":"EMP-\\d{6}"}]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := LoadPIIPolicy("all", "", patterns); err != nil {
+			t.Fatalf("load %d: %v", i+1, err)
+		}
+	}
+	mask, reject := PIIClasses()
+	if want := []string{PIIEmail, PIIIDNumber, PIICreditCard, PIIPhone, "employee_id"}; !slices.Equal(mask, want) {
+		t.Errorf("mask classes = %v, want %v", mask, want)
+	}
+	if len(reject) != 0 {
+		t.Errorf("reject classes = %v, want none", reject)
+	}
+	if spans := findPII("id EMP-123456", nil); len(spans) != 1 || spans[0].Class != "employee_id" {
+		t.Errorf("findPII = %v, want one employee_id span", spans)
+	}
+
+	if err := LoadPIIPolicy("email", "phone", ""); err != nil {
+		t.Fatal(err)
+	}
+	if spans := findPII("id EMP-123456", nil); len(spans) != 0 {
+		t.Errorf("custom detector still active after reload without patterns: %v", spans)
+	}
+	if err := rejectPII("call 13812345678"); err == nil {
+		t.Error("rejectPII accepted a phone number with PII_REJECT=phone")
+	}
+}
+
+func TestLoadPIIPolicyKeepsPolicyOnError(t *testing.T) {
+	t.Cleanup(func() { activePIIPolicy.Store(&piiPolicy{detectors: builtinPIIDetectors}) })
+	if err := LoadPIIPolicy("email", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadPIIPolicy("emial", "", ""); err == nil {
+		t.Fatal("LoadPIIPolicy accepted an unknown class")
+	}
+	if mask, _ := PIIClasses(); !slices.Equal(mask, []string{PIIEmail}) {
+		t.Errorf("mask classes = %v after failed reload, want [email]", mask)
+	}
+}
+
+func TestPIIPlaceholdersMockUpstream(t *testing.T) {
+	t.Cleanup(func() { activePIIPolicy.Store(&piiPolicy{detectors: builtinPIIDetectors}) })
+	if err := LoadPIIPolicy("email", "id_number", ""); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		upstream string
+		want     string
+	}{
+		{name: "masked", body: chatBody("m", "Mail a@b.cn or a@b.cn", false), status: http.StatusOK,
+			upstream: "Mail [[EMAIL_1]] or [[EMAIL_1]]", want: mockupstream.Translation("Mail a@b.cn or a@b.cn", "ja")},
+		{name: "masked stream", body: chatBody("m", "Mail a@b.cn now", true), status: http.StatusOK,
+			upstream: "Mail [[EMAIL_1]] now", want: "a@b.cn"},
+		{name: "with glossary", body: chatBody("m", "Acme: a@b.cn", false), status: http.StatusOK,
+			upstream: "[[GLOSSARY_1]]: [[EMAIL_1]]", want: mockupstream.Translation("アクメ: a@b.cn", "ja")},
+		{name: "placeholder already in text", body: chatBody("m", "[[EMAIL_1]] a@b.cn", false), status: http.StatusOK,
+			upstream: "[[EMAIL_1]] [[EMAIL_2]]", want: mockupstream.Translation("[[EMAIL_1]] a@b.cn", "ja")},
+		{name: "rejected", body: chatBody("m", "id 11010519491231002X", false), status: http.StatusBadRequest, want: "pii_rejected"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mock := newMockHandler(t, mockupstream.Options{ChunkRunes: 2})
+			handler.glossary.replace([]glossaryEntry{{ID: "1", Source: "Acme", Target: "アクメ", TargetLanguage: "ja"}})
+			rec := serveJSON(handler, "/v1/chat/completions", tt.body)
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			requests := mock.Requests()
+			if tt.upstream == "" {
+				if len(requests) != 0 {
+					t.Errorf("rejected request reached the upstream: %+v", requests)
+				}
+			} else if len(requests) != 1 || requests[0].Text != tt.upstream {
+				t.Errorf("upstream requests = %+v, want text %q", requests, tt.upstream)
+			}
+			body := rec.Body.String()
+			if tt.name == "masked stream" {
+				var text strings.Builder
+				for _, event := range parseSSE(t, body) {
+					if event.Data == "[DONE]" {
+						continue
+					}
+					var chunk struct {
+						Choices []struct{ Delta struct{ Content string } }
+					}
+					decodeJSON(t, event.Data, &chunk)
+					for _, choice := range chunk.Choices {
+						text.WriteString(choice.Delta.Content)
+					}
+				}
+				body = text.String()
+			}
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("response %s does not contain %q", body, tt.want)
+			}
+		})
+	}
+}
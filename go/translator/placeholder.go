@@ -0,0 +1,344 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 占位符保护：发往上游前把不应离开本服务的片段（PII）和不应被翻译的片段（模板变量、标签、URL 等，见 protect.go）
+// 替换为 [[EMAIL_1]]、[[VARIABLE_1]] 形式的占位符，译文返回后校验每个占位符出现的次数与发送时一致，再替换回原文。
+// 流式响应由 placeholderRestorer 逐段还原，被拆到两个增量里的占位符会先缓存，等到完整后再输出；
+// 非流式请求校验失败时按 CONFIG.PlaceholderRetries 重新请求上游。
+
+// textSpan 是文本中 [Start, End) 的一段字节区间及其类别。
+type textSpan struct {
+	Start int
+	End   int
+	Class string
+}
+
+type placeholder struct {
+	token string
+	// value 为还原到译文中的内容；label 用于校验失败时的说明，PII 只显示占位符本身。
+	value string
+	label string
+	// count 为占位符在发往上游的文本中出现的次数，译文中应出现同样多次。
+	count int
+}
+
+type placeholderSet struct {
+	source string
+	// glossary 为术语原文到固定译法的映射，术语占位符还原为固定译法。
+	glossary   map[string]string
+	items      []*placeholder
+	byOriginal map[string]*placeholder
+	next       map[string]int
+	maxLen     int
+	replacer   *strings.Replacer
+}
+
+func newPlaceholderSet(source string) *placeholderSet {
+	return &placeholderSet{source: source, byOriginal: map[string]*placeholder{}, next: map[string]int{}}
+}
+
+// add 返回 original 对应的占位符；同一请求中相同类别、相同原文的片段使用同一个占位符。
+// 占位符按类别编号，并跳过原文中本来就出现过的写法。
+func (ps *placeholderSet) add(class, original string) *placeholder {
+	key := class + "\x00" + original
+	if item, ok := ps.byOriginal[key]; ok {
+		return item
+	}
+	name := strings.ToUpper(class)
+	var token string
+	for {
+		ps.next[name]++
+		token = fmt.Sprintf("[[%s_%d]]", name, ps.next[name])
+		if !strings.Contains(ps.source, token) {
+			break
+		}
+	}
+	item := &placeholder{token: token, value: restoredValue(class, original), label: token}
+	if class == SpanGlossary {
+		item.value = ps.glossary[original]
+	}
+	if class == SpanGlossary || class != SpanNoTranslate && isProtectedSpanClass(class) {
+		item.label = original + " " + token
+	}
+	ps.items = append(ps.items, item)
+	ps.byOriginal[key] = item
+	ps.maxLen = max(ps.maxLen, len(token))
+	ps.replacer = nil
+	return item
+}
+
+// replaceSpans 把按位置排序且互不重叠的 spans 替换为占位符。
+func (ps *placeholderSet) replaceSpans(text string, spans []textSpan) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range spans {
+		item := ps.add(span.Class, text[span.Start:span.End])
+		item.count++
+		b.WriteString(text[last:span.Start])
+		b.WriteString(item.token)
+		last = span.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func (ps *placeholderSet) restore(text string) string {
+	if ps.replacer == nil {
+		pairs := make([]string, 0, len(ps.items)*2)
+		for _, item := range ps.items {
+			pairs = append(pairs, item.token, item.value)
+		}
+		ps.replacer = strings.NewReplacer(pairs...)
+	}
+	return ps.replacer.Replace(text)
+}
+
+// verify 检查译文（还原前）中每个占位符的出现次数与发送时一致。
+func (ps *placeholderSet) verify(output string) *apiError {
+	var mismatched []string
+	for _, item := range ps.items {
+		if n := strings.Count(output, item.token); n != item.count {
+			mismatched = append(mismatched, fmt.Sprintf("%s (%d/%d)", item.label, n, item.count))
+		}
+	}
+	if len(mismatched) == 0 {
+		return nil
+	}
+	return newAPIError("placeholderMismatch", strings.Join(mismatched, ", "))
+}
+
+// partialSuffix 返回 text 末尾可能是某个占位符前半部分的字节数，这部分需要等后续增量到达后再还原。
+func (ps *placeholderSet) partialSuffix(text string) int {
+	for i := max(0, len(text)-ps.maxLen+1); i < len(text); i++ {
+		if text[i] != '[' {
+			continue
+		}
+		suffix := text[i:]
+		for _, item := range ps.items {
+			if len(suffix) < len(item.token) && strings.HasPrefix(item.token, suffix) {
+				return len(text) - i
+			}
+		}
+	}
+	return 0
+}
+
+type placeholderKey struct{}
+
+func withPlaceholders(ctx context.Context, ps *placeholderSet) context.Context {
+	return context.WithValue(ctx, placeholderKey{}, ps)
+}
+
+func placeholdersFromContext(ctx context.Context) *placeholderSet {
+	ps, _ := ctx.Value(placeholderKey{}).(*placeholderSet)
+	return ps
+}
+
+// protectPayload 在请求发往上游前处理 payload 中的文本：命中 PII_REJECT 的请求直接拒绝，
+// PII_MASKING 与 PROTECTED_SPANS 中的片段以及目标语言的术语（见 glossary.go）替换为占位符，
+// 重叠时依次优先不翻译片段、URL、术语；对应关系保存在返回的 context 中，供 restoreText 与 placeholderRestorer 还原。
+func (s *Handler) protectPayload(ctx context.Context, payload *doubaoRequest) (context.Context, error) {
+	if len(payload.Input) == 0 || len(payload.Input[0].Content) == 0 {
+		return ctx, nil
+	}
+	content := &payload.Input[0].Content[0]
+	if err := rejectPII(content.Text); err != nil {
+		return ctx, err
+	}
+	policy := currentPIIPolicy()
+	classes := slices.Concat(CONFIG.ProtectedSpans, policy.maskClasses)
+	detectors := protectedSpanDetectors
+	glossary := s.glossary.detectorFor(content.TranslationOptions.TargetLanguage)
+	if glossary != nil {
+		// 术语排在 <notranslate> 与 URL 之后、标签与模板变量之前。
+		classes = append(classes, SpanGlossary)
+		detectors = slices.Concat(protectedSpanDetectors[:2], []spanDetector{glossary.detector}, protectedSpanDetectors[2:])
+	}
+	if len(classes) == 0 {
+		return ctx, nil
+	}
+	spans := findSpans(content.Text, slices.Concat(detectors, policy.detectors), classes)
+	if len(spans) == 0 {
+		return ctx, nil
+	}
+	set := newPlaceholderSet(content.Text)
+	if glossary != nil {
+		set.glossary = glossary.targets
+	}
+	content.Text = set.replaceSpans(content.Text, spans)
+	masked, terms := 0, 0
+	for _, span := range spans {
+		switch {
+		case span.Class == SpanGlossary:
+			terms++
+		case !isProtectedSpanClass(span.Class):
+			masked++
+		}
+	}
+	spanFromContext(ctx).setAttr("translation.pii_masked", masked)
+	spanFromContext(ctx).setAttr("translation.protected_spans", len(spans)-masked-terms)
+	spanFromContext(ctx).setAttr("translation.glossary_terms", terms)
+	return withPlaceholders(ctx, set), nil
+}
+
+// sendProtectedRequest 解析模型别名后请求上游。请求带占位符或开启了译文缓存且为非流式时，先读取译文校验占位符：
+// 不一致时记录日志并重新请求，最多 CONFIG.PlaceholderRetries 次；校验通过的响应写入缓存，相同请求直接从缓存返回。
+// 返回的 Response 正文已缓存，调用方照常读取；重试用尽仍不一致时先把各次尝试的用量记入台账，
+// 再由调用方的 restoreText 返回 placeholder_mismatch。
+func (s *Handler) sendProtectedRequest(ctx context.Context, payload doubaoRequest, auth string) (*http.Response, error) {
+	if model := s.aliases.resolve(payload.Model); model != payload.Model {
+		spanFromContext(ctx).setAttr("translation.model_alias", payload.Model)
+		payload.Model = model
+	}
+	set := placeholdersFromContext(ctx)
+	cacheKey := s.cache.key(ctx, payload)
+	if (set == nil && cacheKey == "") || payload.Stream {
+		return s.sendDoubaoRequest(ctx, payload, auth)
+	}
+	if body, ok := s.cache.get(cacheKey, time.Now()); ok {
+		spanFromContext(ctx).setAttr("translation.cache_hit", true)
+		return cachedResponse(body), nil
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := s.sendDoubaoRequest(ctx, payload, auth)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, newTransportError(err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+
+		var parsed doubaoResponse
+		if json.Unmarshal(body, &parsed) != nil || parsed.Error != nil {
+			return resp, nil
+		}
+		text := findAssistantMessage(parsed)
+		if text == "" {
+			return resp, nil
+		}
+		var mismatch *apiError
+		if set != nil {
+			mismatch = set.verify(text)
+		}
+		if mismatch == nil {
+			s.cache.put(ctx, cacheKey, payload, body, time.Now())
+			return resp, nil
+		}
+		log.Printf("placeholder mismatch in translation (attempt %d of %d): %v", attempt, CONFIG.PlaceholderRetries+1, mismatch.Args[0])
+		if attempt > CONFIG.PlaceholderRetries {
+			s.recordMismatchUsage(ctx, usageInputTokens(parsed.Usage), usageOutputTokens(parsed.Usage), usageTotalTokens(parsed.Usage))
+			return resp, nil
+		}
+		spanFromContext(ctx).setAttr("translation.placeholder_retries", attempt)
+		addDiscardedUsage(ctx, parsed.Usage)
+	}
+}
+
+// recordMismatchUsage 在请求因占位符不一致而失败时记账：上游已按返回的译文计费，
+// 本次用量与此前重试丢弃的用量一并计入台账（recordUsage 对同一请求只记一次）。
+func (s *Handler) recordMismatchUsage(ctx context.Context, inputTokens, outputTokens, totalTokens int) {
+	recordUsageAttributes(spanFromContext(ctx), inputTokens, outputTokens)
+	s.recordUsage(ctx, inputTokens, outputTokens, totalTokens, "")
+}
+
+// restoreText 校验并还原一段完整的译文；请求没有占位符时原样返回。
+func restoreText(ctx context.Context, text string) (string, error) {
+	set := placeholdersFromContext(ctx)
+	if set == nil {
+		return text, nil
+	}
+	if err := set.verify(text); err != nil {
+		spanFromContext(ctx).setAttr("translation.placeholder_mismatch", fmt.Sprint(err.Args...))
+		return text, err
+	}
+	return set.restore(text), nil
+}
+
+// restoreOutputText 还原 Responses 结构（response 对象或流式事件）中所有 "text" 字段里的占位符，
+// 调用前应已用 restoreText 或 placeholderRestorer.verify 校验过译文。
+func restoreOutputText(ctx context.Context, value interface{}) {
+	if set := placeholdersFromContext(ctx); set != nil {
+		set.restoreFields(value)
+	}
+}
+
+func (ps *placeholderSet) restoreFields(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if text, ok := item.(string); ok && key == "text" {
+				v[key] = ps.restore(text)
+				continue
+			}
+			ps.restoreFields(item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			ps.restoreFields(item)
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			ps.restoreFields(item)
+		}
+	}
+}
+
+// placeholderRestorer 逐段还原流式译文；为 nil 时各方法原样透传。
+type placeholderRestorer struct {
+	set     *placeholderSet
+	raw     strings.Builder
+	pending string
+}
+
+func newPlaceholderRestorer(ctx context.Context) *placeholderRestorer {
+	set := placeholdersFromContext(ctx)
+	if set == nil {
+		return nil
+	}
+	return &placeholderRestorer{set: set}
+}
+
+// push 接收一段上游增量，返回可以输出的已还原文本；末尾未完整的占位符留到下一次。
+func (r *placeholderRestorer) push(delta string) string {
+	if r == nil {
+		return delta
+	}
+	r.raw.WriteString(delta)
+	text := r.pending + delta
+	keep := r.set.partialSuffix(text)
+	r.pending = text[len(text)-keep:]
+	return r.set.restore(text[:len(text)-keep])
+}
+
+// flush 在流结束时输出缓存的剩余文本。
+func (r *placeholderRestorer) flush() string {
+	if r == nil {
+		return ""
+	}
+	text := r.pending
+	r.pending = ""
+	return r.set.restore(text)
+}
+
+// verify 校验整段上游译文中的占位符。
+func (r *placeholderRestorer) verify() *apiError {
+	if r == nil {
+		return nil
+	}
+	return r.set.verify(r.raw.String())
+}
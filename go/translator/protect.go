@@ -0,0 +1,66 @@
+package translator
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 不翻译片段：模板变量（{user_name}、{{count}}、%1$s）、编号标签（<0>…</0> 中的 <0> 与 </0>，标签内的文字照常翻译）、
+// URL，以及用 <notranslate>…</notranslate> 显式标出的内容。按 PROTECTED_SPANS 在发往上游前替换为占位符，
+// 译文中校验并还原（见 placeholder.go）；<notranslate> 标记本身不会出现在译文中。
+
+const (
+	SpanNoTranslate = "notranslate"
+	SpanURL         = "url"
+	SpanTag         = "tag"
+	SpanVariable    = "variable"
+)
+
+// cjkPunctuation 为 URL 不会包含的全角标点，避免把紧跟在 URL 后面的中文标点算进去。
+const cjkPunctuation = `\x{3000}-\x{303F}\x{FF00}-\x{FFEF}`
+
+// protectedSpanDetectors 按优先级排列：<notranslate> 整段优先，其次 URL（其中的 {id}、%20 不再单独处理）。
+var protectedSpanDetectors = []spanDetector{
+	{class: SpanNoTranslate, pattern: regexp.MustCompile(`(?is)<notranslate>.*?</notranslate>`)},
+	{class: SpanURL, pattern: regexp.MustCompile(`\bhttps?://[^\s<>"'` + cjkPunctuation + `]*[^\s<>"'.,;:!?)\]` + cjkPunctuation + `]`)},
+	{class: SpanTag, pattern: regexp.MustCompile(`</?\d+\s*/?>`)},
+	{class: SpanVariable, pattern: regexp.MustCompile(`\{\{\s*[\w.\-]+\s*\}\}|\{[\w.\-]+\}|%(?:\d+\$)?[-+0#]*\d*(?:\.\d+)?[sdfiuxXoeEgGc@]`)},
+}
+
+var noTranslateMarker = regexp.MustCompile(`(?i)</?notranslate>`)
+
+// restoredValue 返回占位符在译文中还原成的内容：<notranslate> 片段去掉标记，其余类别原样还原。
+func restoredValue(class, original string) string {
+	if class == SpanNoTranslate {
+		return noTranslateMarker.ReplaceAllString(original, "")
+	}
+	return original
+}
+
+func isProtectedSpanClass(class string) bool {
+	for _, detector := range protectedSpanDetectors {
+		if detector.class == class {
+			return true
+		}
+	}
+	return false
+}
+
+// parseProtectedSpans 解析逗号分隔的 PROTECTED_SPANS，"all" 表示全部类别；返回无法识别的条目供调用方记录。
+func parseProtectedSpans(value string) (classes, invalid []string) {
+	for _, class := range splitList(strings.ToLower(value)) {
+		switch {
+		case class == "all":
+			classes = classes[:0]
+			for _, detector := range protectedSpanDetectors {
+				classes = append(classes, detector.class)
+			}
+			return classes, invalid
+		case isProtectedSpanClass(class):
+			classes = append(classes, class)
+		default:
+			invalid = append(invalid, class)
+		}
+	}
+	return classes, invalid
+}
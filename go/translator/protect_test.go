@@ -0,0 +1,181 @@
+package translator
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"doubao/mockupstream"
+)
+
+// useProtectedSpans 设置 CONFIG.ProtectedSpans 与 CONFIG.PlaceholderRetries，测试结束后恢复。
+func useProtectedSpans(t *testing.T, classes []string, retries int) {
+	t.Helper()
+	spans, previousRetries := CONFIG.ProtectedSpans, CONFIG.PlaceholderRetries
+	t.Cleanup(func() { CONFIG.ProtectedSpans, CONFIG.PlaceholderRetries = spans, previousRetries })
+	CONFIG.ProtectedSpans, CONFIG.PlaceholderRetries = classes, retries
+}
+
+func TestParseProtectedSpans(t *testing.T) {
+	tests := []struct {
+		value   string
+		classes []string
+		invalid []string
+	}{
+		{value: "", classes: nil},
+		{value: "Variable, url", classes: []string{SpanVariable, SpanURL}},
+		{value: "all", classes: []string{SpanNoTranslate, SpanURL, SpanTag, SpanVariable}},
+		{value: "tag,markdown", classes: []string{SpanTag}, invalid: []string{"markdown"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			classes, invalid := parseProtectedSpans(tt.value)
+			if !slices.Equal(classes, tt.classes) || !slices.Equal(invalid, tt.invalid) {
+				t.Errorf("parseProtectedSpans(%q) = %v, %v; want %v, %v", tt.value, classes, invalid, tt.classes, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestFindProtectedSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{name: "variables", text: "Hi {user_name}, {{ count }} items, %1$s and %d",
+			want: []string{"variable:{user_name}", "variable:{{ count }}", "variable:%1$s", "variable:%d"}},
+		{name: "tags", text: "Click <0>here</0> or <1/>", want: []string{"tag:<0>", "tag:</0>", "tag:<1/>"}},
+		{name: "url before variable", text: "See https://example.com/items/{id}?q=%20.",
+			want: []string{"url:https://example.com/items/{id}?q=%20"}},
+		{name: "url before cjk punctuation", text: "见https://example.com/a。", want: []string{"url:https://example.com/a"}},
+		{name: "notranslate wins", text: "<notranslate>Acme {id}</notranslate> {id}",
+			want: []string{"notranslate:<notranslate>Acme {id}</notranslate>", "variable:{id}"}},
+		{name: "plain text", text: "100% sure {not closed", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, span := range findSpans(tt.text, protectedSpanDetectors, nil) {
+				got = append(got, span.Class+":"+tt.text[span.Start:span.End])
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("spans = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtectedSpansMockUpstream(t *testing.T) {
+	useProtectedSpans(t, []string{SpanNoTranslate, SpanURL, SpanTag, SpanVariable}, 0)
+	tests := []struct {
+		name     string
+		text     string
+		upstream string
+		want     string
+	}{
+		{name: "variables and tags", text: "Hi {name}, click <0>here</0>",
+			upstream: "Hi [[VARIABLE_1]], click [[TAG_1]]here[[TAG_2]]", want: "Hi {name}, click <0>here</0>"},
+		{name: "notranslate markers removed", text: "Use <notranslate>Acme Cloud</notranslate> now",
+			upstream: "Use [[NOTRANSLATE_1]] now", want: "Use Acme Cloud now"},
+		{name: "url", text: "Open https://example.com/{id}", upstream: "Open [[URL_1]]", want: "Open https://example.com/{id}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, mock := newMockHandler(t, mockupstream.Options{})
+			rec := serveJSON(handler, "/v1/chat/completions", chatBody("m", tt.text, false))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+			}
+			if got := mock.Requests()[0].Text; got != tt.upstream {
+				t.Errorf("upstream text = %q, want %q", got, tt.upstream)
+			}
+			var resp struct {
+				Choices []struct{ Message struct{ Content string } }
+			}
+			decodeJSON(t, rec.Body.String(), &resp)
+			if want := mockupstream.Translation(tt.want, "ja"); len(resp.Choices) != 1 || resp.Choices[0].Message.Content != want {
+				t.Errorf("response = %s, want content %q", rec.Body.String(), want)
+			}
+		})
+	}
+}
+
+var testPlaceholder = regexp.MustCompile(`\[\[[A-Z_]+_\d+\]\]`)
+
+// newDroppingUpstream 返回一个前 drop 次响应丢掉占位符的上游，每次响应用量为 10 + 5 个 token。
+func newDroppingUpstream(t *testing.T, drop int32) (string, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Input []struct{ Content []struct{ Text string } }
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &payload)
+		text := "[ja] " + payload.Input[0].Content[0].Text
+		if calls.Add(1) <= drop {
+			text = testPlaceholder.ReplaceAllString(text, "")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id": "resp-1", "object": "response",
+			"output": []map[string]interface{}{{
+				"type": "message", "role": "assistant",
+				"content": []map[string]interface{}{{"type": "output_text", "text": text}},
+			}},
+			"usage": map[string]int{"input_tokens": 10, "output_tokens": 5, "total_tokens": 15},
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL, &calls
+}
+
+func TestPlaceholderRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		retries int
+		drop    int32
+		status  int
+		calls   int32
+		tokens  int
+	}{
+		{name: "no retries", retries: 0, drop: 1, status: http.StatusBadGateway, calls: 1, tokens: 15},
+		{name: "retry succeeds", retries: 2, drop: 1, status: http.StatusOK, calls: 2, tokens: 30},
+		{name: "retries exhausted", retries: 2, drop: 5, status: http.StatusBadGateway, calls: 3, tokens: 45},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useProtectedSpans(t, []string{SpanVariable}, tt.retries)
+			url, calls := newDroppingUpstream(t, tt.drop)
+			handler := newHandler(nil)
+			handler.baseURL = url
+			if err := handler.OpenUsageLedger(filepath.Join(t.TempDir(), "usage.jsonl")); err != nil {
+				t.Fatal(err)
+			}
+			defer handler.Close()
+
+			rec := serveJSON(handler, "/v1/chat/completions", chatBody("m", "Hi {name}", false))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.status != http.StatusOK && !strings.Contains(rec.Body.String(), "placeholder_mismatch") {
+				t.Errorf("body = %s, want placeholder_mismatch", rec.Body.String())
+			}
+			if got := calls.Load(); got != tt.calls {
+				t.Errorf("upstream calls = %d, want %d", got, tt.calls)
+			}
+			// 被丢弃的响应同样计费，并与最终结果合并为一条记录。
+			if _, total := handler.ledger.query(usageQuery{}); total.Requests != 1 || total.TotalTokens != tt.tokens {
+				t.Errorf("ledger total = %+v, want 1 request with %d tokens", total, tt.tokens)
+			}
+		})
+	}
+}
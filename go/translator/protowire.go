@@ -0,0 +1,262 @@
+package translator
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// proto/translation.proto 中消息的手写 protobuf 编解码，仅覆盖 gRPC 服务用到的
+// varint 与 length-delimited 两种线格式，未知字段按线格式跳过以保持前向兼容。
+
+const (
+	pbWireVarint  = 0
+	pbWireFixed64 = 1
+	pbWireBytes   = 2
+	pbWireFixed32 = 5
+)
+
+var errProtoTruncated = errors.New("protobuf: truncated message")
+
+type pbEncoder struct {
+	buf []byte
+}
+
+func (e *pbEncoder) tag(field, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+func (e *pbEncoder) bytesField(field int, value []byte) {
+	e.tag(field, pbWireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(value)))
+	e.buf = append(e.buf, value...)
+}
+
+// stringField 与 proto3 语义一致：空字符串不写出。
+func (e *pbEncoder) stringField(field int, value string) {
+	if value == "" {
+		return
+	}
+	e.bytesField(field, []byte(value))
+}
+
+func (e *pbEncoder) int32Field(field int, value int32) {
+	if value == 0 {
+		return
+	}
+	e.tag(field, pbWireVarint)
+	// 负数按 proto 规范以 64 位补码编码。
+	e.buf = binary.AppendUvarint(e.buf, uint64(int64(value)))
+}
+
+func (e *pbEncoder) boolField(field int, value bool) {
+	if !value {
+		return
+	}
+	e.tag(field, pbWireVarint)
+	e.buf = append(e.buf, 1)
+}
+
+func (e *pbEncoder) messageField(field int, value []byte) {
+	e.bytesField(field, value)
+}
+
+// pbField 是解码出的单个字段；Bytes 仅对 length-delimited 字段有效。
+type pbField struct {
+	Number   int
+	WireType int
+	Varint   uint64
+	Bytes    []byte
+}
+
+// decodeProtoFields 依次回调每个字段，遇到格式错误时返回错误。
+func decodeProtoFields(data []byte, fn func(pbField) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		data = data[n:]
+		field := pbField{Number: int(key >> 3), WireType: int(key & 7)}
+		if field.Number <= 0 {
+			return fmt.Errorf("protobuf: invalid field number %d", field.Number)
+		}
+		switch field.WireType {
+		case pbWireVarint:
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return errProtoTruncated
+			}
+			field.Varint = v
+			data = data[n:]
+		case pbWireFixed64:
+			if len(data) < 8 {
+				return errProtoTruncated
+			}
+			data = data[8:]
+		case pbWireFixed32:
+			if len(data) < 4 {
+				return errProtoTruncated
+			}
+			data = data[4:]
+		case pbWireBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return errProtoTruncated
+			}
+			field.Bytes = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return fmt.Errorf("protobuf: unsupported wire type %d", field.WireType)
+		}
+		if err := fn(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type pbTranslateRequest struct {
+	Model            string
+	Text             string
+	SourceLanguage   string
+	TargetLanguage   string
+	SkipSameLanguage bool
+}
+
+func (m *pbTranslateRequest) unmarshal(data []byte) error {
+	return decodeProtoFields(data, func(f pbField) error {
+		switch {
+		case f.Number == 1 && f.WireType == pbWireBytes:
+			m.Model = string(f.Bytes)
+		case f.Number == 2 && f.WireType == pbWireBytes:
+			m.Text = string(f.Bytes)
+		case f.Number == 3 && f.WireType == pbWireBytes:
+			m.SourceLanguage = string(f.Bytes)
+		case f.Number == 4 && f.WireType == pbWireBytes:
+			m.TargetLanguage = string(f.Bytes)
+		case f.Number == 5 && f.WireType == pbWireVarint:
+			m.SkipSameLanguage = f.Varint != 0
+		}
+		return nil
+	})
+}
+
+type pbUsage struct {
+	InputTokens  int32
+	OutputTokens int32
+	TotalTokens  int32
+}
+
+func (m *pbUsage) marshal() []byte {
+	var e pbEncoder
+	e.int32Field(1, m.InputTokens)
+	e.int32Field(2, m.OutputTokens)
+	e.int32Field(3, m.TotalTokens)
+	return e.buf
+}
+
+type pbTranslateResponse struct {
+	Text                   string
+	Model                  string
+	SourceLanguage         string
+	TargetLanguage         string
+	DetectedSourceLanguage string
+	Passthrough            bool
+	Usage                  pbUsage
+}
+
+func (m *pbTranslateResponse) marshal() []byte {
+	var e pbEncoder
+	e.stringField(1, m.Text)
+	e.stringField(2, m.Model)
+	e.stringField(3, m.SourceLanguage)
+	e.stringField(4, m.TargetLanguage)
+	e.stringField(5, m.DetectedSourceLanguage)
+	e.boolField(6, m.Passthrough)
+	e.messageField(7, m.Usage.marshal())
+	return e.buf
+}
+
+// pbTranslateStreamResponse 对应 oneof event：Result 非空时写出 result，否则写出 delta。
+type pbTranslateStreamResponse struct {
+	Delta  string
+	Result *pbTranslateResponse
+}
+
+func (m *pbTranslateStreamResponse) marshal() []byte {
+	var e pbEncoder
+	if m.Result != nil {
+		e.messageField(2, m.Result.marshal())
+	} else {
+		// oneof 成员即使为空值也需要写出，以便客户端区分事件类型。
+		e.bytesField(1, []byte(m.Delta))
+	}
+	return e.buf
+}
+
+type pbBatchTranslateRequest struct {
+	Model            string
+	Texts            []string
+	SourceLanguage   string
+	TargetLanguage   string
+	SkipSameLanguage bool
+}
+
+func (m *pbBatchTranslateRequest) unmarshal(data []byte) error {
+	return decodeProtoFields(data, func(f pbField) error {
+		switch {
+		case f.Number == 1 && f.WireType == pbWireBytes:
+			m.Model = string(f.Bytes)
+		case f.Number == 2 && f.WireType == pbWireBytes:
+			m.Texts = append(m.Texts, string(f.Bytes))
+		case f.Number == 3 && f.WireType == pbWireBytes:
+			m.SourceLanguage = string(f.Bytes)
+		case f.Number == 4 && f.WireType == pbWireBytes:
+			m.TargetLanguage = string(f.Bytes)
+		case f.Number == 5 && f.WireType == pbWireVarint:
+			m.SkipSameLanguage = f.Varint != 0
+		}
+		return nil
+	})
+}
+
+type pbBatchError struct {
+	Code    string
+	Message string
+}
+
+type pbBatchTranslateResult struct {
+	Index    int32
+	Response *pbTranslateResponse
+	Error    *pbBatchError
+}
+
+func (m *pbBatchTranslateResult) marshal() []byte {
+	var e pbEncoder
+	e.int32Field(1, m.Index)
+	switch {
+	case m.Response != nil:
+		e.messageField(2, m.Response.marshal())
+	case m.Error != nil:
+		var errEnc pbEncoder
+		errEnc.stringField(1, m.Error.Code)
+		errEnc.stringField(2, m.Error.Message)
+		e.messageField(3, errEnc.buf)
+	}
+	return e.buf
+}
+
+type pbBatchTranslateResponse struct {
+	Results []pbBatchTranslateResult
+	Usage   pbUsage
+}
+
+func (m *pbBatchTranslateResponse) marshal() []byte {
+	var e pbEncoder
+	for i := range m.Results {
+		e.messageField(1, m.Results[i].marshal())
+	}
+	e.messageField(2, m.Usage.marshal())
+	return e.buf
+}
@@ -0,0 +1,228 @@
+package translator
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// 测试侧的编解码与服务端方向相反：编码请求、解码响应，用于验证线格式往返一致。
+
+func (m *pbTranslateRequest) marshal() []byte {
+	var e pbEncoder
+	e.stringField(1, m.Model)
+	e.stringField(2, m.Text)
+	e.stringField(3, m.SourceLanguage)
+	e.stringField(4, m.TargetLanguage)
+	e.boolField(5, m.SkipSameLanguage)
+	return e.buf
+}
+
+func (m *pbBatchTranslateRequest) marshal() []byte {
+	var e pbEncoder
+	e.stringField(1, m.Model)
+	for _, text := range m.Texts {
+		e.bytesField(2, []byte(text))
+	}
+	e.stringField(3, m.SourceLanguage)
+	e.stringField(4, m.TargetLanguage)
+	e.boolField(5, m.SkipSameLanguage)
+	return e.buf
+}
+
+func (m *pbUsage) unmarshal(data []byte) error {
+	return decodeProtoFields(data, func(f pbField) error {
+		switch f.Number {
+		case 1:
+			m.InputTokens = int32(f.Varint)
+		case 2:
+			m.OutputTokens = int32(f.Varint)
+		case 3:
+			m.TotalTokens = int32(f.Varint)
+		}
+		return nil
+	})
+}
+
+func (m *pbTranslateResponse) unmarshal(data []byte) error {
+	return decodeProtoFields(data, func(f pbField) error {
+		switch f.Number {
+		case 1:
+			m.Text = string(f.Bytes)
+		case 2:
+			m.Model = string(f.Bytes)
+		case 3:
+			m.SourceLanguage = string(f.Bytes)
+		case 4:
+			m.TargetLanguage = string(f.Bytes)
+		case 5:
+			m.DetectedSourceLanguage = string(f.Bytes)
+		case 6:
+			m.Passthrough = f.Varint != 0
+		case 7:
+			return m.Usage.unmarshal(f.Bytes)
+		}
+		return nil
+	})
+}
+
+func (m *pbTranslateStreamResponse) unmarshal(data []byte) error {
+	return decodeProtoFields(data, func(f pbField) error {
+		switch f.Number {
+		case 1:
+			m.Delta = string(f.Bytes)
+		case 2:
+			m.Result = &pbTranslateResponse{}
+			return m.Result.unmarshal(f.Bytes)
+		}
+		return nil
+	})
+}
+
+func (m *pbBatchTranslateResponse) unmarshal(data []byte) error {
+	return decodeProtoFields(data, func(f pbField) error {
+		switch f.Number {
+		case 1:
+			var result pbBatchTranslateResult
+			err := decodeProtoFields(f.Bytes, func(f pbField) error {
+				switch f.Number {
+				case 1:
+					result.Index = int32(f.Varint)
+				case 2:
+					result.Response = &pbTranslateResponse{}
+					return result.Response.unmarshal(f.Bytes)
+				case 3:
+					result.Error = &pbBatchError{}
+					return decodeProtoFields(f.Bytes, func(f pbField) error {
+						if f.Number == 1 {
+							result.Error.Code = string(f.Bytes)
+						} else if f.Number == 2 {
+							result.Error.Message = string(f.Bytes)
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+			m.Results = append(m.Results, result)
+			return err
+		case 2:
+			return m.Usage.unmarshal(f.Bytes)
+		}
+		return nil
+	})
+}
+
+func TestProtoRequestRoundTrip(t *testing.T) {
+	requests := []pbTranslateRequest{
+		{},
+		{Model: "doubao-seed-translation", Text: "你好，世界", SourceLanguage: "zh", TargetLanguage: "en", SkipSameLanguage: true},
+		{Text: string(make([]byte, 300))},
+	}
+	for _, want := range requests {
+		var got pbTranslateRequest
+		if err := got.unmarshal(want.marshal()); err != nil || got != want {
+			t.Errorf("round trip = %+v, %v; want %+v", got, err, want)
+		}
+	}
+
+	batch := pbBatchTranslateRequest{Model: "m", Texts: []string{"a", "", "c"}, TargetLanguage: "ja", SkipSameLanguage: true}
+	var got pbBatchTranslateRequest
+	if err := got.unmarshal(batch.marshal()); err != nil || !reflect.DeepEqual(got, batch) {
+		t.Errorf("batch round trip = %+v, %v; want %+v", got, err, batch)
+	}
+}
+
+func TestProtoResponseRoundTrip(t *testing.T) {
+	response := pbTranslateResponse{
+		Text: "Hello", Model: "m", SourceLanguage: "zh", TargetLanguage: "en", DetectedSourceLanguage: "zh",
+		Passthrough: true, Usage: pbUsage{InputTokens: 3, OutputTokens: 2, TotalTokens: 5},
+	}
+	var got pbTranslateResponse
+	if err := got.unmarshal(response.marshal()); err != nil || got != response {
+		t.Errorf("response round trip = %+v, %v; want %+v", got, err, response)
+	}
+
+	for _, want := range []pbTranslateStreamResponse{{Delta: "Hel"}, {Delta: ""}, {Result: &response}} {
+		var got pbTranslateStreamResponse
+		data := want.marshal()
+		if len(data) == 0 {
+			t.Errorf("stream event %+v marshals to nothing; oneof members must always be written", want)
+		}
+		if err := got.unmarshal(data); err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("stream round trip = %+v, %v; want %+v", got, err, want)
+		}
+	}
+
+	batch := pbBatchTranslateResponse{
+		Results: []pbBatchTranslateResult{
+			{Index: 0, Response: &response},
+			{Index: 1, Error: &pbBatchError{Code: "rate_limit_exceeded", Message: "quota"}},
+		},
+		Usage: pbUsage{InputTokens: 3, OutputTokens: 2, TotalTokens: 5},
+	}
+	var gotBatch pbBatchTranslateResponse
+	if err := gotBatch.unmarshal(batch.marshal()); err != nil || !reflect.DeepEqual(gotBatch, batch) {
+		t.Errorf("batch response round trip = %+v, %v; want %+v", gotBatch, err, batch)
+	}
+}
+
+func TestProtoNegativeInt32(t *testing.T) {
+	usage := pbUsage{InputTokens: -1}
+	data := usage.marshal()
+	// 负数按 64 位补码编码为 10 字节 varint。
+	if len(data) != 11 {
+		t.Errorf("encoded length = %d, want 11", len(data))
+	}
+	var got pbUsage
+	if err := got.unmarshal(data); err != nil || got != usage {
+		t.Errorf("round trip = %+v, %v; want %+v", got, err, usage)
+	}
+}
+
+func TestDecodeProtoFields(t *testing.T) {
+	var unknown pbEncoder
+	unknown.stringField(2, "text")
+	unknown.tag(9, pbWireFixed64)
+	unknown.buf = append(unknown.buf, 1, 2, 3, 4, 5, 6, 7, 8)
+	unknown.tag(10, pbWireFixed32)
+	unknown.buf = append(unknown.buf, 1, 2, 3, 4)
+	unknown.tag(11, pbWireVarint)
+	unknown.buf = append(unknown.buf, 0x96, 0x01)
+	unknown.stringField(12, "future")
+	// 字段 5 以错误的线格式出现时忽略，而不是误读。
+	unknown.stringField(5, "true")
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    pbTranslateRequest
+		wantErr bool
+	}{
+		{name: "unknown fields skipped", data: unknown.buf, want: pbTranslateRequest{Text: "text"}},
+		{name: "truncated key", data: []byte{0x80}, wantErr: true},
+		{name: "truncated varint", data: []byte{0x28, 0x80}, wantErr: true},
+		{name: "truncated bytes", data: []byte{0x12, 0x05, 'a', 'b'}, wantErr: true},
+		{name: "truncated fixed64", data: []byte{0x49, 1, 2, 3}, wantErr: true},
+		{name: "truncated fixed32", data: []byte{0x55, 1}, wantErr: true},
+		{name: "field number zero", data: []byte{0x02, 0x00}, wantErr: true},
+		{name: "group wire type", data: []byte{0x0B}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got pbTranslateRequest
+			err := got.unmarshal(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unmarshal error = %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("unmarshal = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	stop := errors.New("stop")
+	if err := decodeProtoFields(unknown.buf, func(pbField) error { return stop }); err != stop {
+		t.Errorf("callback error = %v, want it returned unchanged", err)
+	}
+}
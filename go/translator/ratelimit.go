@@ -0,0 +1,163 @@
+package translator
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 限流：按调用方身份（Identity.Name，虚拟密钥为 "vk:<name>"）的令牌桶，每分钟补充 RequestsPerMinute 个令牌，
+// 桶容量为 Burst（缺省等于 RequestsPerMinute）。每个请求消耗一个令牌，gRPC 批量请求按其中的文本条数消耗。
+// Identities 中单独配置的身份优先，其余身份使用 Default；两者都没有时不限流。配置通过管理接口修改，桶状态只保存在内存中。
+
+type rateLimit struct {
+	RequestsPerMinute int `json:"requests_per_minute"`
+	Burst             int `json:"burst,omitempty"`
+}
+
+type rateLimitSettings struct {
+	Default    *rateLimit           `json:"default,omitempty"`
+	Identities map[string]rateLimit `json:"identities,omitempty"`
+}
+
+type tokenBucket struct {
+	limit   rateLimit
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimitBucketLimit 为内存中保留的令牌桶上限，超过时清理已经补满的桶。
+const rateLimitBucketLimit = 4096
+
+type rateLimiter struct {
+	mu       sync.Mutex
+	settings rateLimitSettings
+	buckets  map[string]*tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: map[string]*tokenBucket{}}
+}
+
+func (l *rateLimiter) current() rateLimitSettings {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.settings
+}
+
+// replace 替换限流配置；已有的桶按新配置重新开始计数。
+func (l *rateLimiter) replace(settings rateLimitSettings) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.settings = settings
+	l.buckets = map[string]*tokenBucket{}
+}
+
+func (l *rateLimiter) limitFor(identity string) (rateLimit, bool) {
+	if limit, ok := l.settings.Identities[identity]; ok {
+		return limit, limit.RequestsPerMinute > 0
+	}
+	if l.settings.Default != nil {
+		return *l.settings.Default, l.settings.Default.RequestsPerMinute > 0
+	}
+	return rateLimit{}, false
+}
+
+func (limit rateLimit) capacity() float64 {
+	if limit.Burst > 0 {
+		return float64(limit.Burst)
+	}
+	return float64(limit.RequestsPerMinute)
+}
+
+// allow 为 identity 一次性消耗 cost 个令牌，不足时一个也不扣，返回带 Retry-After 的 rateLimited 错误；
+// cost 超过桶容量、等待多久都无法满足时返回 rateLimitBatch。
+func (l *rateLimiter) allow(identity string, cost int, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limit, ok := l.limitFor(identity)
+	if !ok {
+		return nil
+	}
+	if float64(cost) > limit.capacity() {
+		return newAPIError("rateLimitBatch", cost, int(limit.capacity()))
+	}
+	rate := float64(limit.RequestsPerMinute) / 60
+	bucket := l.buckets[identity]
+	if bucket == nil || bucket.limit != limit {
+		if len(l.buckets) >= rateLimitBucketLimit {
+			l.prune(now)
+		}
+		bucket = &tokenBucket{limit: limit, tokens: limit.capacity(), updated: now}
+		l.buckets[identity] = bucket
+	}
+	bucket.tokens = math.Min(limit.capacity(), bucket.tokens+now.Sub(bucket.updated).Seconds()*rate)
+	bucket.updated = now
+	if bucket.tokens >= float64(cost) {
+		bucket.tokens -= float64(cost)
+		return nil
+	}
+	retryAfter := int(math.Ceil((float64(cost) - bucket.tokens) / rate))
+	err := newAPIError("rateLimited", limit.RequestsPerMinute)
+	err.Header = http.Header{"Retry-After": []string{strconv.Itoa(retryAfter)}}
+	return err
+}
+
+// prune 删除到 now 时已经补满的桶，这些桶与新建的桶没有区别。
+func (l *rateLimiter) prune(now time.Time) {
+	for identity, bucket := range l.buckets {
+		rate := float64(bucket.limit.RequestsPerMinute) / 60
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*rate >= bucket.limit.capacity() {
+			delete(l.buckets, identity)
+		}
+	}
+}
+
+// checkRateLimit 为当前请求的调用方身份扣除 texts 个令牌。
+func (s *Handler) checkRateLimit(ctx context.Context, texts int) error {
+	identity, _ := IdentityFromContext(ctx)
+	return s.rateLimits.allow(identity.Name, texts, time.Now())
+}
+
+func validRateLimit(limit rateLimit) bool {
+	return limit.RequestsPerMinute >= 0 && limit.Burst >= 0
+}
+
+// handleAdminRateLimits 查看（GET）或整体替换（PUT）限流配置，
+// 如 {"default":{"requests_per_minute":60},"identities":{"vk:billing":{"requests_per_minute":600,"burst":100}}}。
+func (s *Handler) handleAdminRateLimits(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.rateLimits.current())
+	case http.MethodPut:
+		var settings rateLimitSettings
+		if err := readAdminJSON(r, &settings); err != nil {
+			writeAPIError(ctx, w, err)
+			return
+		}
+		if settings.Default != nil && !validRateLimit(*settings.Default) {
+			writeAPIError(ctx, w, newAPIError("invalidValue", "default", "requests_per_minute / burst").withParam("default"))
+			return
+		}
+		for identity, limit := range settings.Identities {
+			if identity == "" || !validRateLimit(limit) {
+				writeAPIError(ctx, w, newAPIError("invalidValue", "identities", identity).withParam("identities"))
+				return
+			}
+		}
+		state := s.adminSnapshot()
+		before := state.RateLimits
+		state.RateLimits = settings
+		if !s.commitAdminState(ctx, w, state) {
+			return
+		}
+		s.rateLimits.replace(settings)
+		s.auditAdmin(r, "rate_limits.update", before, settings)
+		writeJSON(w, http.StatusOK, settings)
+	default:
+		writeAPIError(ctx, w, newAPIError("notFound"))
+	}
+}
@@ -0,0 +1,97 @@
+package translator
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	settings := rateLimitSettings{
+		Default:    &rateLimit{RequestsPerMinute: 60},
+		Identities: map[string]rateLimit{"vk:billing": {RequestsPerMinute: 6, Burst: 2}, "vk:free": {}},
+	}
+	type call struct {
+		identity   string
+		cost       int
+		after      time.Duration
+		template   string
+		retryAfter string
+	}
+	tests := []struct {
+		name  string
+		calls []call
+	}{
+		{name: "burst then refill", calls: []call{
+			{identity: "vk:billing", cost: 1},
+			{identity: "vk:billing", cost: 1},
+			{identity: "vk:billing", cost: 1, template: "rateLimited", retryAfter: "10"},
+			{identity: "vk:billing", cost: 1, after: 10 * time.Second},
+		}},
+		{name: "default limit", calls: []call{
+			{identity: "anonymous", cost: 60},
+			{identity: "anonymous", cost: 1, template: "rateLimited", retryAfter: "1"},
+		}},
+		{name: "zero rate disables limit", calls: []call{
+			{identity: "vk:free", cost: 1000},
+			{identity: "vk:free", cost: 1000},
+		}},
+		{name: "batch charges per text", calls: []call{
+			{identity: "vk:billing", cost: 2},
+			{identity: "vk:billing", cost: 1, template: "rateLimited", retryAfter: "10"},
+		}},
+		{name: "batch larger than remaining tokens", calls: []call{
+			{identity: "vk:billing", cost: 1},
+			{identity: "vk:billing", cost: 2, template: "rateLimited", retryAfter: "10"},
+			// 被拒绝的批量请求不扣令牌。
+			{identity: "vk:billing", cost: 1},
+		}},
+		{name: "batch larger than burst", calls: []call{
+			{identity: "vk:billing", cost: 3, template: "rateLimitBatch"},
+			{identity: "vk:billing", cost: 2},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newRateLimiter()
+			limiter.replace(settings)
+			at := now
+			for i, c := range tt.calls {
+				at = at.Add(c.after)
+				err := limiter.allow(c.identity, c.cost, at)
+				if c.template == "" {
+					if err != nil {
+						t.Fatalf("call %d rejected: %v", i+1, err)
+					}
+					continue
+				}
+				var apiErr *apiError
+				if !errors.As(err, &apiErr) || apiErr.Template != c.template {
+					t.Fatalf("call %d error = %v, want %s", i+1, err, c.template)
+				}
+				if got := apiErr.Header.Get("Retry-After"); got != c.retryAfter {
+					t.Errorf("call %d Retry-After = %q, want %q", i+1, got, c.retryAfter)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterReplace(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := newRateLimiter()
+	limiter.replace(rateLimitSettings{Default: &rateLimit{RequestsPerMinute: 1}})
+	if err := limiter.allow("a", 1, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.allow("a", 1, now); err == nil {
+		t.Fatal("second request allowed")
+	}
+	limiter.replace(rateLimitSettings{})
+	for i := 0; i < 10; i++ {
+		if err := limiter.allow("a", 1, now); err != nil {
+			t.Fatalf("request %d rejected without limits: %v", i+1, err)
+		}
+	}
+}
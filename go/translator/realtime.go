@@ -0,0 +1,296 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// /v1/realtime/translate：基于 WebSocket 的实时翻译会话。客户端逐段发送文本，服务端按到达顺序排队，
+// 以流式请求上游并推送增量；每段结束时附带本段与整个会话的累计用量。
+//
+// 客户端事件：
+//
+//	{"type":"session.update","session":{"model":"...","translation_options":{...},"metadata":{...}}}
+//	{"type":"segment.append","segment_id":"可选","text":"..."}
+//
+// 服务端事件：session.created、session.updated、segment.delta、segment.done、error。
+
+const realtimeQueueSize = 32
+
+type realtimeClientEvent struct {
+	Type      string                 `json:"type"`
+	Session   *realtimeSessionConfig `json:"session"`
+	SegmentID string                 `json:"segment_id"`
+	Text      interface{}            `json:"text"`
+}
+
+type realtimeSessionConfig struct {
+	Model              string      `json:"model"`
+	TranslationOptions interface{} `json:"translation_options"`
+	Metadata           interface{} `json:"metadata"`
+}
+
+type realtimeUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type realtimeSessionInfo struct {
+	ID                 string             `json:"id"`
+	Model              string             `json:"model"`
+	TranslationOptions translationOptions `json:"translation_options"`
+}
+
+// realtimeServerEvent 覆盖全部服务端事件，各事件只填写自己用到的字段。
+type realtimeServerEvent struct {
+	Type                   string               `json:"type"`
+	Session                *realtimeSessionInfo `json:"session,omitempty"`
+	SegmentID              string               `json:"segment_id,omitempty"`
+	Delta                  string               `json:"delta,omitempty"`
+	Text                   string               `json:"text,omitempty"`
+	DetectedSourceLanguage string               `json:"detected_source_language,omitempty"`
+	Passthrough            bool                 `json:"passthrough,omitempty"`
+	Usage                  *realtimeUsage       `json:"usage,omitempty"`
+	SessionUsage           *realtimeUsage       `json:"session_usage,omitempty"`
+	Error                  *openAIErrorObject   `json:"error,omitempty"`
+}
+
+type realtimeSegment struct {
+	id      string
+	request translationRequest
+}
+
+// realtimeSession 的配置字段只由读取循环修改，usage 只由翻译 worker 修改；
+// 入队时对配置做快照，因此两者之间无需加锁。
+type realtimeSession struct {
+	id        string
+	conn      *wsConn
+	auth      string
+	locale    string
+	model     string
+	options   translationOptions
+	overrides []interface{}
+	queue     chan realtimeSegment
+	received  int
+	usage     realtimeUsage
+}
+
+func (s *Handler) handleRealtime(ctx context.Context, w http.ResponseWriter, r *http.Request, auth string) {
+	if !isWebSocketUpgrade(r) || r.Header.Get("Sec-WebSocket-Version") != "13" || r.Header.Get("Sec-WebSocket-Key") == "" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeAPIError(ctx, w, newAPIError("websocketRequired"))
+		return
+	}
+	if !websocketOriginAllowed(r) {
+		log.Printf("rejected websocket handshake from origin %q", r.Header.Get("Origin"))
+		writeAPIError(ctx, w, newAPIError("websocketOrigin", r.Header.Get("Origin")))
+		return
+	}
+
+	options, err := s.resolveTranslationOptions(ctx, "")
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	conn, err := upgradeWebSocket(w, r, CONFIG.MaxRequestSize)
+	if err != nil {
+		log.Printf("websocket upgrade failed: %v", err)
+		return
+	}
+
+	session := &realtimeSession{
+		id:      genID("sess"),
+		conn:    conn,
+		auth:    auth,
+		locale:  localeFromContext(ctx),
+		model:   r.URL.Query().Get("model"),
+		options: options,
+		queue:   make(chan realtimeSegment, realtimeQueueSize),
+	}
+	spanFromContext(ctx).setAttr("realtime.session_id", session.id)
+
+	sessionCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := conn.writeJSON(session.configEvent("session.created")); err != nil {
+		conn.close(wsCloseInternalError, "")
+		return
+	}
+
+	workerDone := make(chan struct{})
+	go func() {
+		defer close(workerDone)
+		for segment := range session.queue {
+			if sessionCtx.Err() != nil {
+				continue
+			}
+			s.translateRealtimeSegment(sessionCtx, session, segment)
+		}
+	}()
+	if CONFIG.StreamHeartbeatInterval > 0 {
+		go session.keepAlive(sessionCtx, CONFIG.StreamHeartbeatInterval)
+	}
+
+	for {
+		opcode, message, err := conn.readMessage()
+		if err != nil {
+			break
+		}
+		if opcode != wsOpText {
+			session.sendError("", newAPIError("invalidJson"))
+			continue
+		}
+		var event realtimeClientEvent
+		if err := json.Unmarshal(message, &event); err != nil {
+			session.sendError("", newAPIError("invalidJson"))
+			continue
+		}
+		switch event.Type {
+		case "session.update":
+			s.updateRealtimeSession(ctx, session, event.Session)
+		case "segment.append":
+			session.enqueue(event)
+		default:
+			session.sendError(event.SegmentID, newAPIError("invalidValue", "type", event.Type).withParam("type"))
+		}
+	}
+
+	// 客户端断开后取消进行中的上游请求，丢弃尚未处理的片段。
+	cancel()
+	close(session.queue)
+	<-workerDone
+	conn.close(wsCloseNormal, "")
+}
+
+func (s *Handler) updateRealtimeSession(ctx context.Context, session *realtimeSession, cfg *realtimeSessionConfig) {
+	if cfg == nil {
+		session.sendError("", newAPIError("invalidType", "session", "object").withParam("session"))
+		return
+	}
+	if CONFIG.StrictValidation {
+		if err := validateCommonFields(nil, cfg.TranslationOptions, cfg.Metadata); err != nil {
+			session.sendError("", err)
+			return
+		}
+	}
+	options, err := s.resolveTranslationOptions(ctx, "", cfg.TranslationOptions, cfg.Metadata)
+	if err != nil {
+		session.sendError("", err)
+		return
+	}
+	if cfg.Model != "" {
+		session.model = cfg.Model
+	}
+	session.options = options
+	session.overrides = []interface{}{cfg.TranslationOptions, cfg.Metadata}
+	_ = session.conn.writeJSON(session.configEvent("session.updated"))
+}
+
+func (session *realtimeSession) configEvent(eventType string) realtimeServerEvent {
+	return realtimeServerEvent{
+		Type:    eventType,
+		Session: &realtimeSessionInfo{ID: session.id, Model: session.model, TranslationOptions: session.options},
+	}
+}
+
+func (session *realtimeSession) enqueue(event realtimeClientEvent) {
+	session.received++
+	segmentID := event.SegmentID
+	if segmentID == "" {
+		segmentID = fmt.Sprintf("seg_%d", session.received)
+	}
+
+	text, ok := event.Text.(string)
+	if !ok || strings.TrimSpace(text) == "" {
+		session.sendError(segmentID, newAPIError("noMessage").withParam("text"))
+		return
+	}
+	if session.model == "" {
+		session.sendError(segmentID, newAPIError("noModel"))
+		return
+	}
+	if CONFIG.StrictValidation {
+		if err := validateInputLength(text, "text"); err != nil {
+			session.sendError(segmentID, err)
+			return
+		}
+	}
+
+	segment := realtimeSegment{
+		id: segmentID,
+		request: translationRequest{
+			Auth:      session.auth,
+			Model:     session.model,
+			Text:      text,
+			Options:   session.options,
+			Overrides: session.overrides,
+		},
+	}
+	select {
+	case session.queue <- segment:
+	default:
+		session.sendError(segmentID, newAPIError("segmentQueueFull", realtimeQueueSize))
+	}
+}
+
+func (s *Handler) translateRealtimeSegment(ctx context.Context, session *realtimeSession, segment realtimeSegment) {
+	ctx, segmentSpan := s.tracer.start(ctx, "realtime.segment", spanKindInternal)
+	defer segmentSpan.end()
+	segmentSpan.setAttr("realtime.segment_id", segment.id)
+	segmentSpan.setAttr("translation.target_language", segment.request.Options.TargetLanguage)
+
+	result, err := s.translateText(ctx, segment.request, func(delta string) error {
+		return session.conn.writeJSON(realtimeServerEvent{Type: "segment.delta", SegmentID: segment.id, Delta: delta})
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			segmentSpan.setError(err.Error())
+			session.sendError(segment.id, err)
+		}
+		return
+	}
+
+	usage := realtimeUsage{InputTokens: result.InputTokens, OutputTokens: result.OutputTokens, TotalTokens: result.TotalTokens}
+	session.usage.InputTokens += usage.InputTokens
+	session.usage.OutputTokens += usage.OutputTokens
+	session.usage.TotalTokens += usage.TotalTokens
+
+	sessionUsage := session.usage
+	_ = session.conn.writeJSON(realtimeServerEvent{
+		Type:                   "segment.done",
+		SegmentID:              segment.id,
+		Text:                   result.Text,
+		DetectedSourceLanguage: result.DetectedSourceLanguage,
+		Passthrough:            result.Passthrough,
+		Usage:                  &usage,
+		SessionUsage:           &sessionUsage,
+	})
+}
+
+func (session *realtimeSession) sendError(segmentID string, err error) {
+	errObject := toAPIError(err).object(session.locale)
+	_ = session.conn.writeJSON(realtimeServerEvent{Type: "error", SegmentID: segmentID, Error: &errObject})
+}
+
+// keepAlive 定时发送 ping，避免负载均衡器在两段语音之间因空闲断开连接。
+func (session *realtimeSession) keepAlive(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := session.conn.writeFrame(wsOpPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
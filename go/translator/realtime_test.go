@@ -0,0 +1,150 @@
+package translator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialRealtime 对测试服务器完成 WebSocket 握手，返回连接与读取器。
+func dialRealtime(t *testing.T, ts *httptest.Server, query string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(conn, "GET /v1/realtime/translate%s HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\nAuthorization: Bearer test-key\r\n\r\n", query)
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake: status %d, accept %q", resp.StatusCode, resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+	return conn, reader
+}
+
+func sendEvent(t *testing.T, conn net.Conn, event string) {
+	t.Helper()
+	if _, err := conn.Write(clientFrame(true, wsOpText, []byte(event))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readEvent 读取下一个 JSON 事件，跳过 ping 等控制帧。
+func readEvent(t *testing.T, reader *bufio.Reader) map[string]interface{} {
+	t.Helper()
+	for {
+		frame, err := readServerFrame(reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.opcode != wsOpText {
+			continue
+		}
+		var event map[string]interface{}
+		if err := json.Unmarshal(frame.payload, &event); err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+}
+
+func TestRealtimeTranslate(t *testing.T) {
+	srv, _, _ := newTracedHandler(t)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	conn, reader := dialRealtime(t, ts, "?model=m")
+
+	if event := readEvent(t, reader); event["type"] != "session.created" {
+		t.Fatalf("first event = %v, want session.created", event)
+	}
+	sendEvent(t, conn, `{"type":"session.update","session":{"translation_options":{"target_language":"fr"}}}`)
+	if event := readEvent(t, reader); event["type"] != "session.updated" {
+		t.Fatalf("event = %v, want session.updated", event)
+	}
+
+	for i, text := range []string{"Good morning", "Good night"} {
+		sendEvent(t, conn, `{"type":"segment.append","segment_id":"s`+fmt.Sprint(i)+`","text":"`+text+`"}`)
+		var deltas strings.Builder
+		for {
+			event := readEvent(t, reader)
+			if event["segment_id"] != "s"+fmt.Sprint(i) {
+				t.Fatalf("event for another segment: %v", event)
+			}
+			if event["type"] == "segment.delta" {
+				deltas.WriteString(event["delta"].(string))
+				continue
+			}
+			if event["type"] != "segment.done" {
+				t.Fatalf("event = %v, want segment.done", event)
+			}
+			usage := event["usage"].(map[string]interface{})
+			if deltas.String() != stubTranslation || event["text"] != stubTranslation || usage["input_tokens"] != float64(len(text)) {
+				t.Errorf("segment %d: deltas %q, done %v", i, deltas.String(), event)
+			}
+			if i == 1 {
+				sessionUsage := event["session_usage"].(map[string]interface{})
+				if want := float64(len("Good morning") + len("Good night")); sessionUsage["input_tokens"] != want {
+					t.Errorf("session_usage = %v, want input_tokens %v", sessionUsage, want)
+				}
+			}
+			break
+		}
+	}
+
+	tests := []struct {
+		event    string
+		wantCode string
+	}{
+		{event: `not json`, wantCode: "invalid_json"},
+		{event: `{"type":"response.create"}`, wantCode: "invalid_value"},
+		{event: `{"type":"segment.append","text":"  "}`, wantCode: "missing_user_message"},
+		{event: `{"type":"session.update"}`, wantCode: "invalid_type"},
+	}
+	for _, tt := range tests {
+		sendEvent(t, conn, tt.event)
+		event := readEvent(t, reader)
+		errObj, _ := event["error"].(map[string]interface{})
+		if event["type"] != "error" || errObj["code"] != tt.wantCode {
+			t.Errorf("%s: got %v, want error %s", tt.event, event, tt.wantCode)
+		}
+	}
+
+	if _, err := conn.Write(clientFrame(true, wsOpClose, []byte{0x03, 0xE8})); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		frame, err := readServerFrame(reader)
+		if err != nil {
+			t.Fatalf("connection closed without a close frame: %v", err)
+		}
+		if frame.opcode == wsOpClose {
+			if code := closeCode(frame); code != wsCloseNormal {
+				t.Errorf("close code = %d, want %d", code, wsCloseNormal)
+			}
+			break
+		}
+	}
+}
+
+func TestRealtimeRequiresUpgrade(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/realtime/translate", nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	newHandler(nil).ServeHTTP(rec, req)
+	if rec.Code != http.StatusUpgradeRequired || rec.Header().Get("Sec-WebSocket-Version") != "13" {
+		t.Errorf("status = %d, Sec-WebSocket-Version %q; want 426 and 13", rec.Code, rec.Header().Get("Sec-WebSocket-Version"))
+	}
+}
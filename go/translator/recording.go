@@ -0,0 +1,251 @@
+package translator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 上游流量的录制与回放：录制模式把每次上游往返（转换后的请求、状态码、响应体或 SSE 事件序列）
+// 追加写入 JSONL；回放模式下 sendDoubaoRequest 不再访问网络，而是按请求内容从录制文件中取响应，
+// 用于在离线环境下对 /v1/chat/completions、/v1/responses 与流式整形做黄金测试。
+// 录制内容只包含 doubaoRequest 与白名单内的响应头，不会写入 Authorization、traceparent 等请求头。
+
+// upstreamRecord 为录制文件中的一行。
+type upstreamRecord struct {
+	Request doubaoRequest     `json:"request"`
+	Status  int               `json:"status"`
+	Header  map[string]string `json:"header,omitempty"`
+	// Body 为非 SSE 响应的原始内容；SSE 响应改为按事件保存在 Events 中。
+	Body   string        `json:"body,omitempty"`
+	Events []recordEvent `json:"events,omitempty"`
+	// Error 为读取响应体时遇到的错误（如连接中断），回放时在内容读完后返回同样的错误。
+	Error string `json:"error,omitempty"`
+}
+
+type recordEvent struct {
+	Event string `json:"event,omitempty"`
+	Data  string `json:"data"`
+}
+
+// recordedHeaders 为录制时保留的响应头。
+var recordedHeaders = []string{"Content-Type", "Retry-After"}
+
+func recordKey(payload doubaoRequest) string {
+	key, _ := json.Marshal(payload)
+	return string(key)
+}
+
+// RecordUpstream 开启录制模式：之后每次上游往返在响应体关闭时追加一行到 path。
+// 需在处理请求前调用；文件在 Close 时关闭。
+func (s *Handler) RecordUpstream(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	s.recorder = &upstreamRecorder{file: file}
+	return nil
+}
+
+// ReplayUpstream 开启回放模式：上游请求改由 path 中的录制内容应答，不再访问网络。
+// 同一请求录制了多次时按顺序依次返回，用完后重复最后一条；没有匹配记录时返回上游错误。
+func (s *Handler) ReplayUpstream(path string) error {
+	replay, err := loadUpstreamReplay(path)
+	if err != nil {
+		return err
+	}
+	s.replay = replay
+	return nil
+}
+
+type upstreamRecorder struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func (r *upstreamRecorder) close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+func (r *upstreamRecorder) write(record upstreamRecord) error {
+	var line bytes.Buffer
+	encoder := json.NewEncoder(&line)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(record); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err := r.file.Write(line.Bytes())
+	return err
+}
+
+// capture 用 recordingBody 包装响应体，调用方照常读取与关闭即可。
+func (r *upstreamRecorder) capture(payload doubaoRequest, resp *http.Response) {
+	resp.Body = &recordingBody{
+		body:     resp.Body,
+		recorder: r,
+		record:   upstreamRecord{Request: payload, Status: resp.StatusCode, Header: pickHeaders(resp.Header)},
+		stream:   isEventStream(resp.Header),
+	}
+}
+
+func pickHeaders(header http.Header) map[string]string {
+	picked := map[string]string{}
+	for _, name := range recordedHeaders {
+		if v := header.Get(name); v != "" {
+			picked[name] = v
+		}
+	}
+	return picked
+}
+
+// recordingBody 在读取的同时缓存响应内容，关闭时写出一条记录。
+// 流式转发会在后台 goroutine 中读取、在处理函数中关闭，因此需要加锁。
+type recordingBody struct {
+	body     io.ReadCloser
+	recorder *upstreamRecorder
+	record   upstreamRecord
+	stream   bool
+
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	readErr error
+	once    sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.mu.Lock()
+	b.buf.Write(p[:n])
+	if err != nil && err != io.EOF && b.readErr == nil {
+		b.readErr = err
+	}
+	b.mu.Unlock()
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.body.Close()
+	b.once.Do(func() {
+		b.mu.Lock()
+		record := b.record
+		content := b.buf.String()
+		if b.readErr != nil {
+			record.Error = b.readErr.Error()
+		}
+		b.mu.Unlock()
+
+		if b.stream {
+			record.Events = []recordEvent{}
+			scanner := newSSEScanner(strings.NewReader(content))
+			for {
+				event, scanErr := scanner.Next()
+				if scanErr != nil {
+					break
+				}
+				record.Events = append(record.Events, recordEvent{Event: event.Name, Data: event.Data})
+			}
+		} else {
+			record.Body = content
+		}
+		if writeErr := b.recorder.write(record); writeErr != nil {
+			log.Printf("failed to record upstream response: %v", writeErr)
+		}
+	})
+	return err
+}
+
+type upstreamReplay struct {
+	mu      sync.Mutex
+	records map[string][]upstreamRecord
+	served  map[string]int
+}
+
+func loadUpstreamReplay(path string) (*upstreamReplay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	replay := &upstreamReplay{records: map[string][]upstreamRecord{}, served: map[string]int{}}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var record upstreamRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		key := recordKey(record.Request)
+		replay.records[key] = append(replay.records[key], record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return replay, nil
+}
+
+// response 构造与录制时等价的 *http.Response；SSE 事件按 "event:/data:" 格式重新序列化。
+func (r *upstreamReplay) response(payload doubaoRequest) (*http.Response, error) {
+	key := recordKey(payload)
+	r.mu.Lock()
+	records := r.records[key]
+	index := r.served[key]
+	if index < len(records)-1 {
+		r.served[key] = index + 1
+	}
+	r.mu.Unlock()
+	if len(records) == 0 {
+		return nil, fmt.Errorf("replay: no recorded upstream response for model %q, stream=%t", payload.Model, payload.Stream)
+	}
+	record := records[index]
+
+	header := http.Header{}
+	for name, value := range record.Header {
+		header.Set(name, value)
+	}
+	var body strings.Builder
+	if record.Events != nil {
+		for _, event := range record.Events {
+			if event.Event != "" {
+				body.WriteString("event: " + event.Event + "\n")
+			}
+			for _, line := range strings.Split(event.Data, "\n") {
+				body.WriteString("data: " + line + "\n")
+			}
+			body.WriteString("\n")
+		}
+	} else {
+		body.WriteString(record.Body)
+	}
+	var reader io.Reader = strings.NewReader(body.String())
+	if record.Error != "" {
+		reader = io.MultiReader(reader, errorReader{errors.New(record.Error)})
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", record.Status, http.StatusText(record.Status)),
+		StatusCode: record.Status,
+		Header:     header,
+		Body:       io.NopCloser(reader),
+	}, nil
+}
+
+type errorReader struct{ err error }
+
+func (r errorReader) Read([]byte) (int, error) { return 0, r.err }
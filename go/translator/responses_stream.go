@@ -0,0 +1,416 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// /v1/responses 的流式转发：逐事件解析上游 SSE，统一 model/id/usage 字段，
+// 并保证以 response.completed 或 response.failed 结束。
+
+type responsesEventWriter struct {
+	w        io.Writer
+	flusher  http.Flusher
+	sequence int
+	closed   bool
+}
+
+func newResponsesEventWriter(w http.ResponseWriter) *responsesEventWriter {
+	w = newDeadlineWriter(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	return &responsesEventWriter{w: w, flusher: flusher}
+}
+
+// emit 写出一个具名事件，并重写 type 与单调递增的 sequence_number。
+func (e *responsesEventWriter) emit(name string, data map[string]interface{}) bool {
+	if e.closed {
+		return false
+	}
+	data["type"] = name
+	data["sequence_number"] = e.sequence
+	e.sequence++
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("failed to marshal responses event: %v", err)
+		return true
+	}
+	if _, err := fmt.Fprintf(e.w, "event: %s\ndata: %s\n\n", name, payload); err != nil {
+		e.closed = true
+		return false
+	}
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+	return true
+}
+
+// heartbeat 写出 SSE 注释心跳，不占用 sequence_number。
+func (e *responsesEventWriter) heartbeat() error {
+	if e.closed {
+		return io.ErrClosedPipe
+	}
+	if err := writeSSEHeartbeat(e.w, e.flusher); err != nil {
+		e.closed = true
+		return err
+	}
+	return nil
+}
+
+type responsesStreamState struct {
+	model          string
+	detectedSource string
+	responseID     string
+	createdAt      int64
+	messageID      string
+	text           strings.Builder
+	snapshot       map[string]interface{}
+	usage          map[string]interface{}
+	terminal       bool
+	failed         bool
+	passthrough    bool
+	// restorer 还原译文增量中的占位符，请求没有占位符时为 nil。
+	restorer *placeholderRestorer
+}
+
+func newResponsesStreamState(model, detectedSource string) *responsesStreamState {
+	return &responsesStreamState{
+		model:          model,
+		detectedSource: detectedSource,
+		createdAt:      time.Now().Unix(),
+	}
+}
+
+// normalizeResponse 统一 response 对象：请求方的 model、稳定的 id、input/output_tokens 形式的 usage。
+func (st *responsesStreamState) normalizeResponse(obj map[string]interface{}) {
+	if st.responseID == "" {
+		if id, ok := obj["id"].(string); ok && id != "" {
+			st.responseID = id
+		} else {
+			st.responseID = genID("resp")
+		}
+	}
+	obj["id"] = st.responseID
+	obj["object"] = "response"
+	obj["model"] = st.model
+	if created, ok := obj["created_at"].(float64); ok {
+		st.createdAt = int64(created)
+	} else {
+		obj["created_at"] = st.createdAt
+	}
+	if usage, ok := obj["usage"].(map[string]interface{}); ok {
+		obj["usage"] = normalizeResponsesUsage(usage)
+		st.usage = obj["usage"].(map[string]interface{})
+	}
+	if st.detectedSource != "" {
+		obj["detected_source_language"] = st.detectedSource
+	}
+	if st.passthrough {
+		obj["passthrough"] = true
+	}
+}
+
+func normalizeResponsesUsage(usage map[string]interface{}) map[string]interface{} {
+	input := intFromInterface(usage["input_tokens"])
+	if input == 0 {
+		input = intFromInterface(usage["prompt_tokens"])
+	}
+	output := intFromInterface(usage["output_tokens"])
+	if output == 0 {
+		output = intFromInterface(usage["completion_tokens"])
+	}
+	total := intFromInterface(usage["total_tokens"])
+	if total == 0 {
+		total = input + output
+	}
+	normalized := map[string]interface{}{
+		"input_tokens":  input,
+		"output_tokens": output,
+		"total_tokens":  total,
+	}
+	for _, key := range []string{"input_tokens_details", "output_tokens_details"} {
+		if details, ok := usage[key]; ok {
+			normalized[key] = details
+		}
+	}
+	return normalized
+}
+
+// completedResponse 在上游未发送终止事件时，用累计的文本与快照补出 response.completed。
+func (st *responsesStreamState) completedResponse() map[string]interface{} {
+	response := map[string]interface{}{}
+	for k, v := range st.snapshot {
+		response[k] = v
+	}
+	if st.messageID == "" {
+		st.messageID = genID("msg")
+	}
+	response["status"] = "completed"
+	response["output"] = []map[string]interface{}{
+		{
+			"id":     st.messageID,
+			"type":   "message",
+			"role":   "assistant",
+			"status": "completed",
+			"content": []map[string]interface{}{
+				{"type": "output_text", "text": st.text.String(), "annotations": []interface{}{}},
+			},
+		},
+	}
+	if st.usage != nil {
+		response["usage"] = st.usage
+	} else {
+		response["usage"] = map[string]interface{}{"input_tokens": 0, "output_tokens": 0, "total_tokens": 0}
+	}
+	st.normalizeResponse(response)
+	return response
+}
+
+// flushText 把 restorer 中缓存的剩余译文作为一个补充的 output_text.delta 事件写出。
+func (st *responsesStreamState) flushText(writer *responsesEventWriter) {
+	rest := st.restorer.flush()
+	if rest == "" {
+		return
+	}
+	st.text.WriteString(rest)
+	writer.emit("response.output_text.delta", map[string]interface{}{
+		"item_id": st.messageID, "output_index": 0, "content_index": 0, "delta": rest,
+	})
+}
+
+func (st *responsesStreamState) failedResponse(code, message string) map[string]interface{} {
+	response := map[string]interface{}{}
+	for k, v := range st.snapshot {
+		response[k] = v
+	}
+	response["status"] = "failed"
+	response["error"] = map[string]interface{}{"code": code, "message": message}
+	if _, ok := response["output"]; !ok {
+		response["output"] = []interface{}{}
+	}
+	st.normalizeResponse(response)
+	return response
+}
+
+func (s *Handler) streamResponses(ctx context.Context, w http.ResponseWriter, upstream *http.Response, modelID, detectedSource string) {
+	defer upstream.Body.Close()
+	_, relaySpan := s.tracer.start(ctx, "stream.relay", spanKindInternal)
+	defer relaySpan.end()
+
+	writer := newResponsesEventWriter(w)
+	state := newResponsesStreamState(modelID, detectedSource)
+	state.restorer = newPlaceholderRestorer(ctx)
+	defer func() { relaySpan.setAttr("stream.events", writer.sequence) }()
+
+	relay := newSSERelay(upstream.Body, streamTimeoutsFromConfig(), writer.heartbeat)
+	defer relay.Close()
+	for !writer.closed {
+		event, err := relay.Next()
+		if err != nil {
+			var apiErr *apiError
+			if errors.As(err, &apiErr) {
+				log.Printf("streamResponses aborted: %v", apiErr)
+				relaySpan.setError(apiErr.Error())
+				s.finishResponsesStream(ctx, writer, state, apiErr.template().Code, apiErr.message(localeFromContext(ctx)))
+				return
+			}
+			if err == io.EOF && state.failed {
+				s.finishResponsesStream(ctx, writer, state, "", "")
+				return
+			}
+			// 没有收到终止事件就结束的流（读取出错或在帧边界处正常关闭）都按截断处理。
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			} else {
+				log.Printf("streamResponses read error: %v", err)
+			}
+			relaySpan.setError(err.Error())
+			apiErr = newAPIError("streamInterrupted", err.Error())
+			s.finishResponsesStream(ctx, writer, state, apiErr.template().Code, apiErr.message(localeFromContext(ctx)))
+			return
+		}
+		if event.Data == "" || event.Data == "[DONE]" {
+			continue
+		}
+
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+			log.Printf("failed to parse SSE chunk: %v", err)
+			continue
+		}
+		name := event.Name
+		if name == "" {
+			name, _ = data["type"].(string)
+		}
+		if name == "" {
+			continue
+		}
+
+		if response, ok := data["response"].(map[string]interface{}); ok {
+			state.normalizeResponse(response)
+			state.snapshot = response
+		}
+
+		switch name {
+		case "response.output_item.added":
+			if item, ok := data["item"].(map[string]interface{}); ok {
+				if id, ok := item["id"].(string); ok && state.messageID == "" {
+					state.messageID = id
+				}
+			}
+		case "response.output_text.delta":
+			relay.markToken()
+			if id, ok := data["item_id"].(string); ok && state.messageID == "" {
+				state.messageID = id
+			}
+			if delta, ok := toString(data["delta"]); ok {
+				delta = state.restorer.push(delta)
+				if delta == "" {
+					continue
+				}
+				state.text.WriteString(delta)
+				data["delta"] = delta
+			}
+		case "response.output_text.done":
+			state.flushText(writer)
+		case "response.completed", "response.failed", "response.incomplete":
+			var mismatch *apiError
+			if name == "response.completed" {
+				state.flushText(writer)
+				mismatch = state.restorer.verify()
+			}
+			// 失败、未完成或占位符校验失败的响应同样计费，用量一并记录。
+			if state.usage != nil {
+				inputTokens := intFromInterface(state.usage["input_tokens"])
+				outputTokens := intFromInterface(state.usage["output_tokens"])
+				recordUsageAttributes(relaySpan, inputTokens, outputTokens)
+				recordUsageAttributes(spanFromContext(ctx), inputTokens, outputTokens)
+				s.recordUsage(ctx, inputTokens, outputTokens, intFromInterface(state.usage["total_tokens"]), state.text.String())
+			}
+			if mismatch != nil {
+				log.Printf("streamResponses aborted: %v", mismatch)
+				relaySpan.setError(mismatch.Error())
+				s.finishResponsesStream(ctx, writer, state, mismatch.template().Code, mismatch.message(localeFromContext(ctx)))
+				return
+			}
+			state.terminal = true
+			state.failed = name == "response.failed"
+		case "error":
+			state.failed = true
+			data = normalizeResponsesErrorEvent(data)
+		}
+
+		if name != "response.output_text.delta" {
+			restoreOutputText(ctx, data)
+		}
+		writer.emit(name, data)
+		if state.terminal {
+			return
+		}
+	}
+}
+
+// finishResponsesStream 在上游未给出终止事件时补发 error 与 response.failed；code 为空表示上游已发送过 error 事件。
+func (s *Handler) finishResponsesStream(ctx context.Context, writer *responsesEventWriter, state *responsesStreamState, code, message string) {
+	if state.terminal {
+		return
+	}
+	if code == "" {
+		code, message = "upstream_error", "上游在返回错误后结束了流"
+	}
+	if !state.failed {
+		writer.emit("error", map[string]interface{}{"code": code, "message": message, "param": nil})
+	}
+	failed := state.failedResponse(code, message)
+	restoreOutputText(ctx, failed)
+	writer.emit("response.failed", map[string]interface{}{"response": failed})
+	spanFromContext(ctx).setError(message)
+}
+
+func normalizeResponsesErrorEvent(data map[string]interface{}) map[string]interface{} {
+	normalized := map[string]interface{}{"code": data["code"], "message": data["message"], "param": data["param"]}
+	if errObj, ok := data["error"].(map[string]interface{}); ok {
+		normalized["code"] = errObj["code"]
+		normalized["message"] = errObj["message"]
+		if param, ok := errObj["param"]; ok {
+			normalized["param"] = param
+		}
+	}
+	if _, ok := normalized["message"].(string); !ok {
+		normalized["message"] = "上游接口错误"
+	}
+	return normalized
+}
+
+// streamResponsesFromJSON 处理请求了流式但上游返回普通 JSON 的情况，转换为完整的事件序列。
+func (s *Handler) streamResponsesFromJSON(ctx context.Context, w http.ResponseWriter, upstream *http.Response, modelID, detectedSource string) {
+	defer upstream.Body.Close()
+	writer := newResponsesEventWriter(w)
+	state := newResponsesStreamState(modelID, detectedSource)
+
+	responseBytes, err := io.ReadAll(upstream.Body)
+	if err != nil {
+		s.finishResponsesStream(ctx, writer, state, "stream_error", err.Error())
+		return
+	}
+	var parsed doubaoResponse
+	if err := json.Unmarshal(responseBytes, &parsed); err != nil {
+		s.finishResponsesStream(ctx, writer, state, "upstream_error", extractUpstreamError(responseBytes))
+		return
+	}
+	if parsed.Error != nil {
+		s.finishResponsesStream(ctx, writer, state, "upstream_error", parsed.Error.Message)
+		return
+	}
+
+	text, err := restoreText(ctx, findAssistantMessage(parsed))
+	if err != nil {
+		apiErr := err.(*apiError)
+		s.finishResponsesStream(ctx, writer, state, apiErr.template().Code, apiErr.message(localeFromContext(ctx)))
+		return
+	}
+	state.text.WriteString(text)
+	if parsed.ID != "" {
+		state.responseID = parsed.ID
+	}
+	if parsed.Usage != nil {
+		state.usage = map[string]interface{}{
+			"input_tokens":  usageInputTokens(parsed.Usage),
+			"output_tokens": usageOutputTokens(parsed.Usage),
+			"total_tokens":  usageTotalTokens(parsed.Usage),
+		}
+	}
+	emitSyntheticResponseEvents(writer, state)
+	s.recordUsage(ctx, usageInputTokens(parsed.Usage), usageOutputTokens(parsed.Usage), usageTotalTokens(parsed.Usage), state.text.String())
+}
+
+// emitSyntheticResponseEvents 基于已知的完整结果补出 created → delta → done → completed 事件序列。
+func emitSyntheticResponseEvents(writer *responsesEventWriter, state *responsesStreamState) {
+	created := map[string]interface{}{"status": "in_progress", "output": []interface{}{}}
+	state.normalizeResponse(created)
+	state.snapshot = created
+	completed := state.completedResponse()
+
+	writer.emit("response.created", map[string]interface{}{"response": created})
+	text := state.text.String()
+	if text != "" {
+		writer.emit("response.output_text.delta", map[string]interface{}{
+			"item_id": state.messageID, "output_index": 0, "content_index": 0, "delta": text,
+		})
+	}
+	writer.emit("response.output_text.done", map[string]interface{}{
+		"item_id": state.messageID, "output_index": 0, "content_index": 0, "text": text,
+	})
+	writer.emit("response.completed", map[string]interface{}{"response": completed})
+	state.terminal = true
+}
@@ -0,0 +1,47 @@
+package translator
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStreamResponsesTerminalUsage(t *testing.T) {
+	usage := `"usage":{"prompt_tokens":11,"completion_tokens":4,"total_tokens":15}`
+	tests := []struct {
+		name     string
+		terminal string
+		want     string
+	}{
+		{name: "completed", terminal: "response.completed", want: `"status":"completed"`},
+		{name: "failed", terminal: "response.failed", want: `"status":"failed"`},
+		{name: "incomplete", terminal: "response.incomplete", want: `"status":"incomplete"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := &memoryExporter{}
+			srv := newHandler(newTracer("doubao-test", exporter))
+			ctx, root := srv.tracer.start(context.Background(), "root", spanKindServer)
+
+			status := strings.TrimPrefix(tt.terminal, "response.")
+			body := "event: response.output_text.delta\ndata: {\"delta\":\"Bonjour\"}\n\n" +
+				"event: " + tt.terminal + "\ndata: {\"response\":{\"status\":\"" + status + "\"," + usage + "}}\n\n"
+			upstream := &http.Response{Body: io.NopCloser(strings.NewReader(body))}
+			rec := httptest.NewRecorder()
+			srv.streamResponses(ctx, rec, upstream, "m", "")
+			root.end()
+
+			if !strings.Contains(rec.Body.String(), "event: "+tt.terminal+"\n") || !strings.Contains(rec.Body.String(), tt.want) {
+				t.Fatalf("stream does not end with %s:\n%s", tt.terminal, rec.Body)
+			}
+			spans := exporter.Spans()
+			for _, sd := range []*spanData{findSpan(t, spans, "stream.relay"), findSpan(t, spans, "root")} {
+				assertAttr(t, sd, "gen_ai.usage.input_tokens", 11)
+				assertAttr(t, sd, "gen_ai.usage.output_tokens", 4)
+			}
+		})
+	}
+}
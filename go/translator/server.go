@@ -0,0 +1,1415 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var idSource = rand.New(rand.NewSource(time.Now().UnixNano()))
+var idMutex sync.Mutex
+
+func genID(prefix string) string {
+	if prefix == "" {
+		prefix = "chatcmpl"
+	}
+	idMutex.Lock()
+	defer idMutex.Unlock()
+	return fmt.Sprintf("%s-%s-%s", prefix, strconv.FormatInt(time.Now().UnixNano(), 36), randomString(10))
+}
+
+const idAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+func randomString(length int) string {
+	b := make([]byte, length)
+	for i := 0; i < length; i++ {
+		b[i] = idAlphabet[idSource.Intn(len(idAlphabet))]
+	}
+	return string(b)
+}
+
+// Handler 实现全部 HTTP 接口，与独立部署的 doubao 服务行为一致，可直接挂到自己的 http.ServeMux 上。
+// 运行参数读取自 CONFIG，需在 NewHandler 之前设置好。
+type Handler struct {
+	baseURL      string
+	client       *http.Client
+	streamClient *http.Client
+	tracer       *tracer
+	recorder     *upstreamRecorder
+	replay       *upstreamReplay
+	ledger       *usageLedger
+
+	// upstream、inflight、metrics、recentErrors 与 adminAudit 由管理接口读取或修改，见 admin.go、inflight.go、metrics.go。
+	upstream       *upstreamControl
+	inflight       *inflightTracker
+	metrics        *requestMetrics
+	recentErrors   *recentErrorLog
+	adminStateFile string
+	adminStateMu   *sync.Mutex
+	adminAudit     *adminAuditLog
+	// virtualKeys、glossary、aliases、rateLimits 与 cache 为管理接口维护的运行时配置。
+	virtualKeys *virtualKeyStore
+	glossary    *glossaryStore
+	aliases     *modelAliases
+	rateLimits  *rateLimiter
+	cache       *responseCache
+	// audit 为翻译审计日志，见 audit.go。
+	audit *auditLogger
+}
+
+func NewHandler() *Handler {
+	return newHandler(newTracerFromEnv())
+}
+
+func newHandler(t *tracer) *Handler {
+	// 流式请求不设整体超时，只限制等待响应头的时间；读取阶段由首 token 与空闲超时控制。
+	streamTransport := http.DefaultTransport.(*http.Transport).Clone()
+	streamTransport.ResponseHeaderTimeout = 60 * time.Second
+	return &Handler{
+		baseURL: CONFIG.DoubaoBaseURL,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		streamClient: &http.Client{
+			Transport: streamTransport,
+		},
+		tracer:       t,
+		upstream:     newUpstreamControl(CONFIG.DoubaoBaseURL),
+		inflight:     newInflightTracker(),
+		metrics:      newRequestMetrics(),
+		recentErrors: newRecentErrorLog(),
+		adminStateMu: &sync.Mutex{},
+		virtualKeys:  &virtualKeyStore{},
+		glossary:     newGlossaryStore(),
+		aliases:      newModelAliases(),
+		rateLimits:   newRateLimiter(),
+		cache:        newResponseCache(cacheSettingsFromConfig()),
+	}
+}
+
+// Close 导出尚未发送的追踪数据，写完审计队列并关闭录制、用量台账与审计文件，在进程退出前调用。
+func (s *Handler) Close() {
+	s.tracer.shutdown()
+	if s.recorder != nil {
+		if err := s.recorder.close(); err != nil {
+			log.Printf("failed to close upstream recording: %v", err)
+		}
+	}
+	if s.ledger != nil {
+		if err := s.ledger.close(); err != nil {
+			log.Printf("failed to close usage ledger: %v", err)
+		}
+	}
+	if s.audit != nil {
+		s.audit.close()
+	}
+	if s.adminAudit != nil {
+		if err := s.adminAudit.file.Close(); err != nil {
+			log.Printf("failed to close admin audit log: %v", err)
+		}
+	}
+}
+
+var routeMethods = map[string]string{
+	"/v1/chat/completions":   http.MethodPost,
+	"/v1/responses":          http.MethodPost,
+	"/v1/detect":             http.MethodPost,
+	"/v1/languages":          http.MethodGet,
+	"/v1/realtime/translate": http.MethodGet,
+}
+
+func (s *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := contextWithRemoteParent(r.Context(), r.Header.Get("traceparent"))
+	ctx = withLocale(ctx, negotiateLocale(r.Header.Get("Accept-Language")))
+	ctx, rootSpan := s.tracer.start(ctx, r.Method+" "+r.URL.Path, spanKindServer)
+	recorder := &statusRecorder{ResponseWriter: w}
+	w = recorder
+	started := time.Now()
+	defer func() {
+		s.metrics.observe(r.URL.Path, recorder.status, time.Since(started))
+		if rootSpan != nil {
+			rootSpan.setAttr("http.response.status_code", recorder.status)
+			if recorder.status >= http.StatusInternalServerError {
+				rootSpan.setError(http.StatusText(recorder.status))
+			}
+			rootSpan.end()
+		}
+	}()
+	rootSpan.setAttr("http.request.method", r.Method)
+	rootSpan.setAttr("url.path", r.URL.Path)
+
+	address, err := checkClientIP(r)
+	rootSpan.setAttr("client.address", address)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+	if handleCORS(w, r) {
+		return
+	}
+	if !enforceHTTPS(ctx, w, r) {
+		return
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/admin/") {
+		s.serveAdmin(ctx, w, r)
+		return
+	}
+	if s.serveDashboard(w, r) {
+		return
+	}
+
+	if method, ok := routeMethods[r.URL.Path]; !ok || r.Method != method {
+		writeAPIError(ctx, w, newAPIError("notFound"))
+		return
+	}
+	ctx, done := s.inflight.start(ctx, r, address)
+	defer done()
+	ctx = withRecentErrors(ctx, s.recentErrors)
+
+	identity, auth, err := s.authorize(ctx, r)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+	ctx = withIdentity(ctx, identity)
+	updateInflight(ctx, func(req *inflightRequest) { req.Identity = identity.Name })
+	rootSpan.setAttr("enduser.id", identity.Name)
+	if err := s.checkRateLimit(ctx, 1); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		switch r.URL.Path {
+		case "/v1/languages":
+			s.handleLanguages(w, r)
+		case "/v1/realtime/translate":
+			s.handleRealtime(ctx, w, r, auth)
+		}
+		return
+	}
+
+	if cl := r.Header.Get("Content-Length"); cl != "" {
+		if parsed, err := strconv.ParseInt(cl, 10, 64); err == nil && parsed > CONFIG.MaxRequestSize {
+			writeAPIError(ctx, w, newAPIError("tooLarge"))
+			return
+		}
+	}
+
+	limited := http.MaxBytesReader(w, r.Body, CONFIG.MaxRequestSize)
+	defer limited.Close()
+
+	body, err := io.ReadAll(limited)
+	if err != nil {
+		if errors.Is(err, http.ErrBodyReadAfterClose) || errors.Is(err, io.EOF) {
+			writeAPIError(ctx, w, newAPIError("invalidJson"))
+			return
+		}
+		if strings.Contains(err.Error(), "http: request body too large") {
+			writeAPIError(ctx, w, newAPIError("tooLarge"))
+			return
+		}
+		writeAPIError(ctx, w, newAPIError("serverError"))
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/chat/completions":
+		s.handleChatCompletions(ctx, w, body, auth)
+	case "/v1/responses":
+		s.handleResponses(ctx, w, body, auth)
+	case "/v1/detect":
+		s.handleDetect(ctx, w, body)
+	}
+}
+
+type messageInput struct {
+	Role    string      `json:"role"`
+	Content interface{} `json:"content"`
+}
+
+type chatCompletionsRequest struct {
+	Model              string         `json:"model"`
+	Messages           []messageInput `json:"messages"`
+	TranslationOptions interface{}    `json:"translation_options"`
+	Metadata           interface{}    `json:"metadata"`
+	Stream             interface{}    `json:"stream"`
+	StreamOptions      interface{}    `json:"stream_options"`
+}
+
+type responsesRequest struct {
+	Model              string      `json:"model"`
+	Input              interface{} `json:"input"`
+	TranslationOptions interface{} `json:"translation_options"`
+	Metadata           interface{} `json:"metadata"`
+	Stream             interface{} `json:"stream"`
+}
+
+type translationOptions struct {
+	SourceLanguage *string `json:"source_language,omitempty"`
+	TargetLanguage string  `json:"target_language"`
+}
+
+type doubaoUsage struct {
+	InputTokens      int `json:"input_tokens"`
+	OutputTokens     int `json:"output_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type doubaoError struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+}
+
+type doubaoContent struct {
+	Type    string      `json:"type"`
+	Text    string      `json:"text"`
+	Content interface{} `json:"content"`
+}
+
+type doubaoOutput struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Role    string          `json:"role"`
+	Content []doubaoContent `json:"content"`
+}
+
+type doubaoResponse struct {
+	ID      string         `json:"id"`
+	Object  string         `json:"object"`
+	Created int64          `json:"created"`
+	Model   string         `json:"model"`
+	Output  []doubaoOutput `json:"output"`
+	Usage   *doubaoUsage   `json:"usage"`
+	Error   *doubaoError   `json:"error"`
+}
+
+// doubaoRequest 是发往方舟 Responses 接口的请求体。
+type doubaoRequest struct {
+	Model  string               `json:"model"`
+	Input  []doubaoInputMessage `json:"input"`
+	Stream bool                 `json:"stream,omitempty"`
+}
+
+type doubaoInputMessage struct {
+	Role    string            `json:"role"`
+	Content []doubaoInputText `json:"content"`
+}
+
+type doubaoInputText struct {
+	Type               string             `json:"type"`
+	Text               string             `json:"text"`
+	TranslationOptions translationOptions `json:"translation_options"`
+}
+
+type chatUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatChoice struct {
+	Index        int         `json:"index"`
+	Message      chatMessage `json:"message"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+type chatCompletion struct {
+	ID                     string       `json:"id"`
+	Object                 string       `json:"object"`
+	Created                int64        `json:"created"`
+	Model                  string       `json:"model"`
+	Choices                []chatChoice `json:"choices"`
+	Usage                  chatUsage    `json:"usage"`
+	DetectedSourceLanguage string       `json:"detected_source_language,omitempty"`
+	Passthrough            bool         `json:"passthrough,omitempty"`
+}
+
+type chatDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type chatChunkChoice struct {
+	Index        int       `json:"index"`
+	Delta        chatDelta `json:"delta"`
+	FinishReason *string   `json:"finish_reason"`
+}
+
+// chunkUsage 对应 chunk 上的 usage 字段：未开启 include_usage 时省略，开启后除最后一个 chunk 外均为 null。
+type chunkUsage struct {
+	include bool
+	usage   *chatUsage
+}
+
+func (u chunkUsage) IsZero() bool {
+	return !u.include
+}
+
+func (u chunkUsage) MarshalJSON() ([]byte, error) {
+	return json.Marshal(u.usage)
+}
+
+type chatCompletionChunk struct {
+	ID                     string            `json:"id"`
+	Object                 string            `json:"object"`
+	Created                int64             `json:"created"`
+	Model                  string            `json:"model"`
+	Choices                []chatChunkChoice `json:"choices"`
+	Usage                  chunkUsage        `json:"usage,omitzero"`
+	DetectedSourceLanguage string            `json:"detected_source_language,omitempty"`
+	Passthrough            bool              `json:"passthrough,omitempty"`
+}
+
+// listResponse 是 /v1/languages、/v1/detect 等列表接口的外层结构。
+type listResponse[T any] struct {
+	Object string `json:"object"`
+	Data   []T    `json:"data"`
+}
+
+func (s *Handler) handleChatCompletions(ctx context.Context, w http.ResponseWriter, body []byte, auth string) {
+	_, parseSpan := s.tracer.start(ctx, "request.parse", spanKindInternal)
+	var req chatCompletionsRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		parseSpan.setError(err.Error())
+		parseSpan.end()
+		writeAPIError(ctx, w, newAPIError("invalidJson"))
+		return
+	}
+	parseSpan.setAttr("http.request.body.size", len(body))
+	parseSpan.end()
+	spanFromContext(ctx).setAttr("gen_ai.request.model", req.Model)
+
+	if req.Model == "" {
+		writeAPIError(ctx, w, newAPIError("noModel"))
+		return
+	}
+	if CONFIG.StrictValidation {
+		if err := validateChatCompletionsRequest(req); err != nil {
+			writeAPIError(ctx, w, err)
+			return
+		}
+	}
+
+	var userContent interface{}
+	userIndex := -1
+	for i := len(req.Messages) - 1; i >= 0; i-- {
+		if strings.EqualFold(req.Messages[i].Role, "user") {
+			userContent = req.Messages[i].Content
+			userIndex = i
+			break
+		}
+	}
+	if userContent == nil {
+		writeAPIError(ctx, w, newAPIError("noMessage"))
+		return
+	}
+	if CONFIG.StrictValidation {
+		if err := validateInputLength(stringifyUserContent(userContent), fmt.Sprintf("messages[%d].content", userIndex)); err != nil {
+			writeAPIError(ctx, w, err)
+			return
+		}
+	}
+
+	var systemPrompt string
+	for _, msg := range req.Messages {
+		if strings.EqualFold(msg.Role, "system") {
+			systemPrompt = extractTextFromContent(msg.Content)
+			if systemPrompt != "" {
+				break
+			}
+		}
+	}
+
+	translationOptions, err := s.resolveTranslationOptions(ctx, systemPrompt, req.TranslationOptions, req.Metadata)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+	detectedSource := detectSourceLanguage(translationOptions, userContent)
+	isStream := parseStreamFlag(req.Stream)
+	includeUsage := parseIncludeUsage(req.StreamOptions)
+	updateInflight(ctx, func(inflight *inflightRequest) { inflight.Model, inflight.Stream = req.Model, isStream })
+	ctx = withUsageMeta(ctx, req.Model, translationOptions, detectedSource, stringifyUserContent(userContent))
+
+	if source, ok := passthroughSource(translationOptions, userContent, req.TranslationOptions, req.Metadata); ok {
+		s.writeChatPassthrough(ctx, w, req.Model, stringifyUserContent(userContent), source, isStream, includeUsage)
+		return
+	}
+
+	payload := buildDoubaoPayload(req.Model, translationOptions, userContent, isStream)
+	ctx, err = s.protectPayload(ctx, &payload)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+	upstream, err := s.sendProtectedRequest(ctx, payload, auth)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	if isStream && isEventStream(upstream.Header) {
+		s.streamDoubaoResponse(ctx, w, upstream, req.Model, detectedSource, includeUsage)
+		return
+	}
+
+	defer upstream.Body.Close()
+	responseBytes, err := io.ReadAll(upstream.Body)
+	if err != nil {
+		writeAPIError(ctx, w, newAPIError("serverError"))
+		return
+	}
+
+	if upstream.StatusCode < 200 || upstream.StatusCode >= 300 {
+		writeAPIError(ctx, w, &upstreamError{Status: upstream.StatusCode, Message: extractUpstreamError(responseBytes)})
+		return
+	}
+
+	var parsed doubaoResponse
+	if err := json.Unmarshal(responseBytes, &parsed); err != nil {
+		writeAPIError(ctx, w, newAPIError("serverError"))
+		return
+	}
+
+	if parsed.Error != nil {
+		writeAPIError(ctx, w, &upstreamError{Status: http.StatusBadGateway, Message: parsed.Error.Message})
+		return
+	}
+
+	messageContent := findAssistantMessage(parsed)
+	if messageContent == "" {
+		writeAPIError(ctx, w, newAPIError("upstreamNoResult"))
+		return
+	}
+	if messageContent, err = restoreText(ctx, messageContent); err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	recordUsageAttributes(spanFromContext(ctx), usageInputTokens(parsed.Usage), usageOutputTokens(parsed.Usage))
+	s.recordUsage(ctx, usageInputTokens(parsed.Usage), usageOutputTokens(parsed.Usage), usageTotalTokens(parsed.Usage), messageContent)
+
+	writeJSON(w, http.StatusOK, chatCompletion{
+		ID:      genID("chatcmpl"),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: []chatChoice{
+			{Message: chatMessage{Role: "assistant", Content: messageContent}, FinishReason: "stop"},
+		},
+		Usage: chatUsage{
+			PromptTokens:     usageInputTokens(parsed.Usage),
+			CompletionTokens: usageOutputTokens(parsed.Usage),
+			TotalTokens:      usageTotalTokens(parsed.Usage),
+		},
+		DetectedSourceLanguage: detectedSource,
+	})
+}
+
+func (s *Handler) handleResponses(ctx context.Context, w http.ResponseWriter, body []byte, auth string) {
+	_, parseSpan := s.tracer.start(ctx, "request.parse", spanKindInternal)
+	var req responsesRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		parseSpan.setError(err.Error())
+		parseSpan.end()
+		writeAPIError(ctx, w, newAPIError("invalidJson"))
+		return
+	}
+	parseSpan.setAttr("http.request.body.size", len(body))
+	parseSpan.end()
+	spanFromContext(ctx).setAttr("gen_ai.request.model", req.Model)
+
+	if req.Model == "" {
+		writeAPIError(ctx, w, newAPIError("noModel"))
+		return
+	}
+	if CONFIG.StrictValidation {
+		if err := validateResponsesRequest(req); err != nil {
+			writeAPIError(ctx, w, err)
+			return
+		}
+	}
+
+	systemPrompt, userContent := parseResponsesInput(req.Input)
+	if userContent == nil {
+		writeAPIError(ctx, w, newAPIError("noMessage").withParam("input"))
+		return
+	}
+	if CONFIG.StrictValidation {
+		if err := validateInputLength(stringifyUserContent(userContent), "input"); err != nil {
+			writeAPIError(ctx, w, err)
+			return
+		}
+	}
+
+	translationOptions, err := s.resolveTranslationOptions(ctx, systemPrompt, req.TranslationOptions, req.Metadata)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+	detectedSource := detectSourceLanguage(translationOptions, userContent)
+	isStream := parseStreamFlag(req.Stream)
+	updateInflight(ctx, func(inflight *inflightRequest) { inflight.Model, inflight.Stream = req.Model, isStream })
+	ctx = withUsageMeta(ctx, req.Model, translationOptions, detectedSource, stringifyUserContent(userContent))
+
+	if source, ok := passthroughSource(translationOptions, userContent, req.TranslationOptions, req.Metadata); ok {
+		s.writeResponsesPassthrough(ctx, w, req.Model, stringifyUserContent(userContent), source, isStream)
+		return
+	}
+
+	payload := buildDoubaoPayload(req.Model, translationOptions, userContent, isStream)
+	ctx, err = s.protectPayload(ctx, &payload)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+	upstream, err := s.sendProtectedRequest(ctx, payload, auth)
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+
+	if isStream {
+		if isEventStream(upstream.Header) {
+			s.streamResponses(ctx, w, upstream, req.Model, detectedSource)
+		} else {
+			s.streamResponsesFromJSON(ctx, w, upstream, req.Model, detectedSource)
+		}
+		return
+	}
+
+	defer upstream.Body.Close()
+	responseBytes, err := io.ReadAll(upstream.Body)
+	if err != nil {
+		writeAPIError(ctx, w, newAPIError("serverError"))
+		return
+	}
+
+	if upstream.StatusCode < 200 || upstream.StatusCode >= 300 {
+		writeAPIError(ctx, w, &upstreamError{Status: upstream.StatusCode, Message: extractUpstreamError(responseBytes)})
+		return
+	}
+
+	var parsed doubaoResponse
+	if err := json.Unmarshal(responseBytes, &parsed); err != nil {
+		writeAPIError(ctx, w, newAPIError("serverError"))
+		return
+	}
+
+	if parsed.Error != nil {
+		writeAPIError(ctx, w, &upstreamError{Status: http.StatusBadGateway, Message: parsed.Error.Message})
+		return
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(responseBytes, &raw); err != nil {
+		writeAPIError(ctx, w, newAPIError("serverError"))
+		return
+	}
+
+	ensureResponsesFields(raw, parsed, req.Model)
+	outputText, err := restoreText(ctx, findAssistantMessage(parsed))
+	if err != nil {
+		writeAPIError(ctx, w, err)
+		return
+	}
+	restoreOutputText(ctx, raw)
+	recordUsageAttributes(spanFromContext(ctx), usageInputTokens(parsed.Usage), usageOutputTokens(parsed.Usage))
+	s.recordUsage(ctx, usageInputTokens(parsed.Usage), usageOutputTokens(parsed.Usage), usageTotalTokens(parsed.Usage), outputText)
+	if detectedSource != "" {
+		raw["detected_source_language"] = detectedSource
+	}
+	writeJSON(w, http.StatusOK, raw)
+}
+
+// resolveTranslationOptions 合并 system 提示词与请求级覆盖项，并记录到追踪属性中。
+func (s *Handler) resolveTranslationOptions(ctx context.Context, systemPrompt string, overrides ...interface{}) (translationOptions, error) {
+	_, resolveSpan := s.tracer.start(ctx, "translation.resolve_options", spanKindInternal)
+	defer resolveSpan.end()
+
+	options, err := parseTranslationOptions(systemPrompt)
+	if err == nil {
+		err = mergeTranslationOverrides(&options, overrides...)
+	}
+	if err != nil {
+		resolveSpan.setError(err.Error())
+		return options, err
+	}
+
+	source := ""
+	if options.SourceLanguage != nil {
+		source = *options.SourceLanguage
+	}
+	for _, sp := range []*span{resolveSpan, spanFromContext(ctx)} {
+		sp.setAttr("translation.source_language", source)
+		sp.setAttr("translation.target_language", options.TargetLanguage)
+	}
+	return options, nil
+}
+
+func (s *Handler) sendDoubaoRequest(ctx context.Context, payload doubaoRequest, auth string) (*http.Response, error) {
+	ctx, upstreamSpan := s.tracer.start(ctx, "doubao.request", spanKindClient)
+	defer upstreamSpan.end()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		upstreamSpan.setError(err.Error())
+		return nil, err
+	}
+
+	baseURL, enabled := s.upstream.target(s.baseURL)
+	if !enabled {
+		upstreamSpan.setError("upstream disabled")
+		return nil, newAPIError("upstreamDisabled")
+	}
+	if s.replay == nil {
+		s.auditUpstreamRequest(ctx, payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL, bytes.NewReader(body))
+	if err != nil {
+		upstreamSpan.setError(err.Error())
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/json")
+	injectTraceparent(ctx, req.Header)
+	upstreamSpan.setAttr("http.request.method", http.MethodPost)
+	upstreamSpan.setAttr("url.full", baseURL)
+	upstreamSpan.setAttr("gen_ai.request.model", payload.Model)
+
+	client := s.client
+	if payload.Stream {
+		client = s.streamClient
+	}
+	var resp *http.Response
+	if s.replay != nil {
+		resp, err = s.replay.response(payload)
+	} else {
+		resp, err = client.Do(req)
+	}
+	if err != nil {
+		upstreamSpan.setError(err.Error())
+		return nil, newTransportError(err)
+	}
+	upstreamSpan.setAttr("http.response.status_code", resp.StatusCode)
+	if s.recorder != nil {
+		s.recorder.capture(payload, resp)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	upstreamErr := &upstreamError{Status: resp.StatusCode, RetryAfter: resp.Header.Get("Retry-After")}
+	responseBytes, readErr := io.ReadAll(resp.Body)
+	if readErr != nil {
+		upstreamErr.Message = resp.Status
+	} else {
+		upstreamErr.Message = extractUpstreamError(responseBytes)
+	}
+	upstreamSpan.setError(upstreamErr.Message)
+	return nil, upstreamErr
+}
+
+func ensureResponsesFields(raw map[string]interface{}, parsed doubaoResponse, requestModel string) {
+	if raw == nil {
+		raw = map[string]interface{}{}
+	}
+
+	if _, ok := raw["id"].(string); !ok || raw["id"] == "" {
+		raw["id"] = genID("resp")
+	}
+	if _, ok := raw["object"].(string); !ok || raw["object"] == "" {
+		raw["object"] = "response"
+	}
+	if _, ok := raw["created"].(float64); !ok {
+		raw["created"] = float64(time.Now().Unix())
+	}
+	raw["model"] = requestModel
+
+	// usage 采用 Responses 字段（input/output_tokens），与流式 response.completed 一致；上游附带的明细字段保留。
+	usage := map[string]interface{}{
+		"input_tokens":  usageInputTokens(parsed.Usage),
+		"output_tokens": usageOutputTokens(parsed.Usage),
+		"total_tokens":  usageTotalTokens(parsed.Usage),
+	}
+	if upstreamUsage, ok := raw["usage"].(map[string]interface{}); ok {
+		for _, key := range []string{"input_tokens_details", "output_tokens_details"} {
+			if details, ok := upstreamUsage[key]; ok {
+				usage[key] = details
+			}
+		}
+	}
+	raw["usage"] = usage
+
+	if outputs, ok := raw["output"].([]interface{}); !ok || len(outputs) == 0 {
+		messageContent := findAssistantMessage(parsed)
+		if messageContent != "" {
+			raw["output"] = []map[string]interface{}{
+				{
+					"id":   genID("msg"),
+					"type": "message",
+					"role": "assistant",
+					"content": []map[string]interface{}{
+						{
+							"type": "output_text",
+							"text": messageContent,
+						},
+					},
+				},
+			}
+		} else {
+			raw["output"] = []interface{}{}
+		}
+	}
+}
+
+func parseResponsesInput(input interface{}) (string, interface{}) {
+	var systemPrompt string
+	var userContent interface{}
+
+	handleSegment := func(segment map[string]interface{}) {
+		if segment == nil {
+			return
+		}
+		role, _ := segment["role"].(string)
+		rawContent, ok := segment["content"]
+		if !ok {
+			if v, ok := segment["input"]; ok {
+				rawContent = v
+			} else if v, ok := segment["text"].(string); ok {
+				rawContent = v
+			} else if v, ok := segment["value"]; ok {
+				rawContent = v
+			}
+		}
+		text := extractTextFromContent(rawContent)
+		if role == "system" && text != "" && systemPrompt == "" {
+			systemPrompt = text
+			return
+		}
+		if (role == "" || role == "user") && text != "" && userContent == nil {
+			userContent = rawContent
+		}
+	}
+
+	switch val := input.(type) {
+	case string:
+		userContent = val
+	case []interface{}:
+		for _, segment := range val {
+			switch seg := segment.(type) {
+			case string:
+				if userContent == nil {
+					userContent = seg
+				}
+			case map[string]interface{}:
+				handleSegment(seg)
+			}
+		}
+	case map[string]interface{}:
+		handleSegment(val)
+	}
+
+	return systemPrompt, userContent
+}
+
+func buildDoubaoPayload(model string, options translationOptions, userContent interface{}, isStream bool) doubaoRequest {
+	return doubaoRequest{
+		Model: model,
+		Input: []doubaoInputMessage{
+			{
+				Role: "user",
+				Content: []doubaoInputText{
+					{Type: "input_text", Text: stringifyUserContent(userContent), TranslationOptions: options},
+				},
+			},
+		},
+		Stream: isStream,
+	}
+}
+
+func stringifyUserContent(content interface{}) string {
+	switch val := content.(type) {
+	case string:
+		return val
+	case fmt.Stringer:
+		return val.String()
+	default:
+		bytes, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+		return string(bytes)
+	}
+}
+
+func parseStreamFlag(stream interface{}) bool {
+	switch val := stream.(type) {
+	case bool:
+		return val
+	case string:
+		return strings.EqualFold(val, "true")
+	default:
+		return false
+	}
+}
+
+// parseIncludeUsage 读取 stream_options.include_usage，决定流末尾是否追加 usage chunk。
+func parseIncludeUsage(streamOptions interface{}) bool {
+	options, ok := streamOptions.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	return parseStreamFlag(options["include_usage"])
+}
+
+func extractTextFromContent(content interface{}) string {
+	switch val := content.(type) {
+	case string:
+		return val
+	case []interface{}:
+		for _, part := range val {
+			text := extractTextFromContent(part)
+			if text != "" {
+				return text
+			}
+		}
+	case map[string]interface{}:
+		if text, ok := val["text"].(string); ok && text != "" {
+			return text
+		}
+		if text, ok := val["content"].(string); ok && text != "" {
+			return text
+		}
+		if nested, ok := val["content"].([]interface{}); ok {
+			return extractTextFromContent(nested)
+		}
+	}
+	return ""
+}
+
+func parseTranslationOptions(systemPrompt string) (translationOptions, error) {
+	options := translationOptions{TargetLanguage: CONFIG.DefaultTargetLanguage}
+	if systemPrompt == "" {
+		return options, nil
+	}
+
+	if parsed, err := parseTranslationJSON(systemPrompt); err == nil {
+		return options, applyLanguageOption(&options, parsed)
+	}
+
+	return options, applyLanguageOption(&options, parseTranslationKV(systemPrompt))
+}
+
+func parseTranslationJSON(input string) (map[string]string, error) {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(input), &parsed); err != nil {
+		return nil, err
+	}
+	result := map[string]string{}
+	for key, value := range parsed {
+		if str, ok := toString(value); ok {
+			result[key] = str
+		}
+	}
+	return result, nil
+}
+
+var reSourceLanguage = regexp.MustCompile(`source_language\s*:\s*['\"]?([^'\"]+)['\"]?`)
+var reTargetLanguage = regexp.MustCompile(`target_language\s*:\s*['\"]?([^'\"]+)['\"]?`)
+
+func parseTranslationKV(input string) map[string]string {
+	result := map[string]string{}
+	if matches := reSourceLanguage.FindStringSubmatch(input); len(matches) > 1 {
+		result["source_language"] = matches[1]
+	}
+	if matches := reTargetLanguage.FindStringSubmatch(input); len(matches) > 1 {
+		result["target_language"] = matches[1]
+	}
+	return result
+}
+
+// resolveLanguageParam 解析语言参数，失败时返回带 param 的 apiError。
+func resolveLanguageParam(param, value string) (string, error) {
+	code, err := currentLanguages().resolve(value)
+	if err != nil {
+		var langErr *languageError
+		if errors.As(err, &langErr) && langErr.Unsupported != nil {
+			return "", newAPIError("unsupportedLanguage", strings.TrimSpace(value)).withParam(param)
+		}
+		return "", newAPIError("unknownLanguage", strings.TrimSpace(value)).withParam(param)
+	}
+	return code, nil
+}
+
+func applyLanguageOption(options *translationOptions, values map[string]string) error {
+	if values == nil {
+		return nil
+	}
+	if rawSource, ok := values["source_language"]; ok && strings.TrimSpace(rawSource) != "" {
+		converted, err := resolveLanguageParam("source_language", rawSource)
+		if err != nil {
+			return err
+		}
+		options.SourceLanguage = &converted
+	}
+	if rawTarget, ok := values["target_language"]; ok && strings.TrimSpace(rawTarget) != "" {
+		converted, err := resolveLanguageParam("target_language", rawTarget)
+		if err != nil {
+			return err
+		}
+		options.TargetLanguage = converted
+	}
+	return nil
+}
+
+func mergeTranslationOverrides(target *translationOptions, sources ...interface{}) error {
+	for _, src := range sources {
+		candidate := extractCandidate(src)
+		if candidate == nil {
+			continue
+		}
+		values := map[string]string{}
+		for _, key := range []string{"source_language", "target_language"} {
+			if raw, ok := candidate[key]; ok {
+				if str, ok := toString(raw); ok {
+					values[key] = str
+				}
+			}
+		}
+		if err := applyLanguageOption(target, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractCandidate(source interface{}) map[string]interface{} {
+	rawMap, ok := source.(map[string]interface{})
+	if !ok || rawMap == nil {
+		return nil
+	}
+
+	if translationOptions, ok := rawMap["translation_options"].(map[string]interface{}); ok {
+		return translationOptions
+	}
+
+	return rawMap
+}
+
+// getLanguageCode 将语言名称或 BCP-47 标签转换为豆包编码；无法识别或不支持时返回空串。
+func getLanguageCode(lang string) string {
+	code, err := currentLanguages().resolve(lang)
+	if err != nil {
+		return ""
+	}
+	return code
+}
+
+func findAssistantMessage(response doubaoResponse) string {
+	for _, output := range response.Output {
+		if output.Type == "message" && output.Role == "assistant" {
+			for _, content := range output.Content {
+				if content.Type == "output_text" && content.Text != "" {
+					return content.Text
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// usageInputTokens 等函数统一上游用量的统计口径：优先使用 Responses 风格的 input/output_tokens，
+// 缺失时回退到 prompt/completion_tokens，chat、Responses 与台账均以此为准。
+func usageInputTokens(usage *doubaoUsage) int {
+	if usage == nil {
+		return 0
+	}
+	if usage.InputTokens != 0 {
+		return usage.InputTokens
+	}
+	return usage.PromptTokens
+}
+
+func usageOutputTokens(usage *doubaoUsage) int {
+	if usage == nil {
+		return 0
+	}
+	if usage.OutputTokens != 0 {
+		return usage.OutputTokens
+	}
+	return usage.CompletionTokens
+}
+
+func usageTotalTokens(usage *doubaoUsage) int {
+	if usage == nil {
+		return 0
+	}
+	if usage.TotalTokens != 0 {
+		return usage.TotalTokens
+	}
+	return usageInputTokens(usage) + usageOutputTokens(usage)
+}
+
+func (s *Handler) streamDoubaoResponse(ctx context.Context, w http.ResponseWriter, upstream *http.Response, modelID, detectedSource string, includeUsage bool) {
+	defer upstream.Body.Close()
+	_, relaySpan := s.tracer.start(ctx, "stream.relay", spanKindInternal)
+	defer relaySpan.end()
+	chunkCount := 0
+	defer func() { relaySpan.setAttr("stream.chunks", chunkCount) }()
+	w = newDeadlineWriter(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(ctx, w, newAPIError("serverError"))
+		return
+	}
+
+	streamID := genID("chatcmpl")
+	createdAt := time.Now().Unix()
+	sentRoleChunk := false
+	closed := false
+	finished := false
+	bufferedNewlines := ""
+	var outputText strings.Builder
+	var usage *chatUsage
+	restorer := newPlaceholderRestorer(ctx)
+
+	// newChunk 构造 chat.completion.chunk；开启 include_usage 时按规范在每个 chunk 上带 "usage": null。
+	newChunk := func(choices ...chatChunkChoice) chatCompletionChunk {
+		if choices == nil {
+			choices = []chatChunkChoice{}
+		}
+		return chatCompletionChunk{
+			ID:      streamID,
+			Object:  "chat.completion.chunk",
+			Created: createdAt,
+			Model:   modelID,
+			Choices: choices,
+			Usage:   chunkUsage{include: includeUsage},
+		}
+	}
+
+	enqueue := func(payload chatCompletionChunk) {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			log.Printf("failed to marshal stream payload: %v", err)
+			return
+		}
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+			closed = true
+			return
+		}
+		chunkCount++
+		flusher.Flush()
+	}
+
+	enqueueDone := func() {
+		if closed {
+			return
+		}
+		if _, err := io.WriteString(w, "data: [DONE]\n\n"); err == nil {
+			flusher.Flush()
+		}
+		closed = true
+	}
+
+	// fail 按 OpenAI 流式错误格式写出错误对象后结束流，用于首 token、空闲超时、读取中断与占位符校验失败。
+	fail := func(apiErr *apiError) {
+		if finished || closed {
+			return
+		}
+		finished = true
+		log.Printf("streamDoubaoResponse aborted: %v", apiErr)
+		recordRecentError(ctx, apiErr)
+		relaySpan.setError(apiErr.Error())
+		spanFromContext(ctx).setAttr("error.code", apiErr.template().Code)
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", apiErr.body(localeFromContext(ctx))); err != nil {
+			closed = true
+			return
+		}
+		flusher.Flush()
+		enqueueDone()
+	}
+
+	// emitText 写出一段（已还原占位符的）译文增量；只含换行的增量先缓存，行尾换行留到下一段内容前输出。
+	emitText := func(delta string) {
+		if delta == "" {
+			return
+		}
+		outputText.WriteString(delta)
+
+		if !sentRoleChunk {
+			roleChunk := newChunk(chatChunkChoice{Delta: chatDelta{Role: "assistant"}})
+			roleChunk.DetectedSourceLanguage = detectedSource
+			enqueue(roleChunk)
+			sentRoleChunk = true
+		}
+
+		if trimmed := strings.Trim(delta, "\n"); trimmed == "" {
+			bufferedNewlines += delta
+			return
+		}
+
+		leadingNewlines := countLeadingNewlines(delta)
+		trailingNewlines := countTrailingNewlines(delta)
+		contentStart := leadingNewlines
+		contentEnd := len(delta) - trailingNewlines
+		if contentEnd < contentStart {
+			contentEnd = contentStart
+		}
+		coreContent := delta[contentStart:contentEnd]
+
+		var emit strings.Builder
+		if bufferedNewlines != "" {
+			emit.WriteString(bufferedNewlines)
+			bufferedNewlines = ""
+		}
+		if leadingNewlines > 0 {
+			emit.WriteString(strings.Repeat("\n", leadingNewlines))
+		}
+		if coreContent != "" {
+			emit.WriteString(coreContent)
+		}
+
+		if emit.Len() > 0 {
+			enqueue(newChunk(chatChunkChoice{Delta: chatDelta{Content: emit.String()}}))
+		}
+
+		bufferedNewlines = strings.Repeat("\n", trailingNewlines)
+	}
+
+	// finish 发送带 finish_reason 的 chunk；include_usage 时再追加 choices 为空的 usage chunk，最后发送 [DONE]。
+	finish := func(finishReason string) {
+		if finished || closed {
+			return
+		}
+		emitText(restorer.flush())
+		if usage == nil {
+			usage = &chatUsage{}
+		}
+		if finishReason == "stop" {
+			if err := restorer.verify(); err != nil {
+				s.recordMismatchUsage(ctx, usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens)
+				fail(err)
+				return
+			}
+		}
+		finished = true
+		bufferedNewlines = ""
+		enqueue(newChunk(chatChunkChoice{FinishReason: &finishReason}))
+		recordUsageAttributes(relaySpan, usage.PromptTokens, usage.CompletionTokens)
+		recordUsageAttributes(spanFromContext(ctx), usage.PromptTokens, usage.CompletionTokens)
+		s.recordUsage(ctx, usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens, outputText.String())
+		if includeUsage {
+			usageChunk := newChunk()
+			usageChunk.Usage.usage = usage
+			enqueue(usageChunk)
+		}
+		enqueueDone()
+	}
+
+	handleEvent := func(eventName, dataStr string) {
+		if dataStr == "" {
+			return
+		}
+		if dataStr == "[DONE]" {
+			finish("stop")
+			return
+		}
+
+		var eventData map[string]interface{}
+		if err := json.Unmarshal([]byte(dataStr), &eventData); err != nil {
+			log.Printf("failed to parse SSE chunk: %v", err)
+			return
+		}
+
+		// 任何携带 usage 的事件都记录下来，上游未发送 response.completed 时也能在结尾给出用量。
+		usageSource, _ := eventData["usage"].(map[string]interface{})
+		if response, ok := eventData["response"].(map[string]interface{}); ok {
+			if usageMap, ok := response["usage"].(map[string]interface{}); ok {
+				usageSource = usageMap
+			}
+		}
+		if usageSource != nil {
+			converted := chatUsageFromResponses(usageSource)
+			usage = &converted
+		}
+
+		switch eventName {
+		case "response.created":
+			if response, ok := eventData["response"].(map[string]interface{}); ok {
+				if createdVal, ok := response["created_at"].(float64); ok {
+					createdAt = int64(createdVal)
+				}
+			}
+		case "response.output_text.delta":
+			delta, _ := toString(eventData["delta"])
+			delta = strings.ReplaceAll(delta, "\r", "")
+			if delta == "" {
+				return
+			}
+			emitText(restorer.push(delta))
+		case "response.completed":
+			finish("stop")
+		case "response.incomplete":
+			finish("length")
+		}
+	}
+
+	relay := newSSERelay(upstream.Body, streamTimeoutsFromConfig(), func() error {
+		return writeSSEHeartbeat(w, flusher)
+	})
+	defer relay.Close()
+	for !closed {
+		event, err := relay.Next()
+		if err != nil {
+			var apiErr *apiError
+			if errors.As(err, &apiErr) {
+				fail(apiErr)
+				return
+			}
+			// 终止事件（[DONE]、response.completed/incomplete）会结束循环，走到这里说明流被截断：
+			// 读取出错或在帧边界处正常关闭都按错误结束，不能让截断的译文以 stop 形式出现。
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			} else {
+				log.Printf("streamDoubaoResponse read error: %v", err)
+			}
+			fail(newAPIError("streamInterrupted", err.Error()))
+			return
+		}
+		if event.Name == "response.output_text.delta" {
+			relay.markToken()
+		}
+		handleEvent(event.Name, event.Data)
+	}
+}
+
+// chatUsageFromResponses 将 Responses 风格（或 prompt/completion 风格）的 usage 转为 chat completions 字段。
+func chatUsageFromResponses(usage map[string]interface{}) chatUsage {
+	normalized := normalizeResponsesUsage(usage)
+	return chatUsage{
+		PromptTokens:     intFromInterface(normalized["input_tokens"]),
+		CompletionTokens: intFromInterface(normalized["output_tokens"]),
+		TotalTokens:      intFromInterface(normalized["total_tokens"]),
+	}
+}
+
+func countLeadingNewlines(input string) int {
+	count := 0
+	for _, r := range input {
+		if r == '\n' {
+			count++
+		} else {
+			break
+		}
+	}
+	return count
+}
+
+func countTrailingNewlines(input string) int {
+	count := 0
+	for i := len(input) - 1; i >= 0; i-- {
+		if input[i] == '\n' {
+			count++
+		} else {
+			break
+		}
+	}
+	return count
+}
+
+func toString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case fmt.Stringer:
+		return v.String(), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(v), true
+	case json.Number:
+		return v.String(), true
+	default:
+		return "", false
+	}
+}
+
+func intFromInterface(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	case json.Number:
+		i, _ := v.Int64()
+		return int(i)
+	default:
+		return 0
+	}
+}
+
+func extractUpstreamError(body []byte) string {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(body, &parsed); err == nil {
+		if errObj, ok := parsed["error"].(map[string]interface{}); ok {
+			if msg, ok := toString(errObj["message"]); ok {
+				return msg
+			}
+		}
+	}
+	trimmed := strings.TrimSpace(string(body))
+	if trimmed != "" {
+		return trimmed
+	}
+	return "上游接口错误"
+}
+
+func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(payload); err != nil {
+		log.Printf("failed to write json response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, body string) {
+	if body == "" {
+		body = newAPIError("serverError").body(CONFIG.DefaultLocale)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := io.WriteString(w, body); err != nil {
+		log.Printf("failed to write error response: %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+package translator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"doubao/mockupstream"
+)
+
+// newMockHandler 返回指向 mockupstream 测试服务的 Handler。
+func newMockHandler(t *testing.T, opts mockupstream.Options) (*Handler, *mockupstream.Server) {
+	t.Helper()
+	ts, mock := mockupstream.NewTestServer(opts)
+	t.Cleanup(ts.Close)
+	handler := newHandler(nil)
+	handler.baseURL = ts.URL + mockupstream.Path
+	return handler, mock
+}
+
+func serveJSON(handler http.Handler, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+// errorBody 为错误响应中 error 对象的字段。
+type errorBody struct {
+	Code    string
+	Type    string
+	Message string
+}
+
+// parseSSE 用 sseScanner 解析录制到的响应体。
+func parseSSE(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	scanner := newSSEScanner(strings.NewReader(body))
+	for {
+		event, err := scanner.Next()
+		if err == io.EOF {
+			return events
+		}
+		if err != nil {
+			t.Fatalf("parse SSE: %v", err)
+		}
+		events = append(events, event)
+	}
+}
+
+func decodeJSON(t *testing.T, data string, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+}
+
+func chatBody(model, text string, stream bool) string {
+	return fmt.Sprintf(`{"model":%q,"stream":%v,"messages":[{"role":"system","content":"target_language: ja"},{"role":"user","content":%q}]}`, model, stream, text)
+}
+
+func responsesBody(model, text string, stream bool) string {
+	return fmt.Sprintf(`{"model":%q,"stream":%v,"input":%q,"translation_options":{"target_language":"ja"}}`, model, stream, text)
+}
+
+func TestChatCompletionsMockUpstream(t *testing.T) {
+	handler, mock := newMockHandler(t, mockupstream.Options{})
+	rec := serveJSON(handler, "/v1/chat/completions", chatBody("m", "Hello", false))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp chatCompletion
+	decodeJSON(t, rec.Body.String(), &resp)
+	if len(resp.Choices) != 1 || resp.Choices[0].Message.Content != mockupstream.Translation("Hello", "ja") {
+		t.Errorf("choices = %+v", resp.Choices)
+	}
+	if resp.Model != "m" || resp.Usage.PromptTokens != 5 || resp.Usage.CompletionTokens != 10 || resp.Usage.TotalTokens != 15 {
+		t.Errorf("model %q usage %+v", resp.Model, resp.Usage)
+	}
+
+	requests := mock.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("upstream requests = %d, want 1", len(requests))
+	}
+	got := requests[0]
+	if got.Authorization != "Bearer test-key" || got.Model != "m" || got.Text != "Hello" || got.TargetLanguage != "ja" || got.Stream {
+		t.Errorf("upstream request = %+v", got)
+	}
+}
+
+func TestChatCompletionsStreamMockUpstream(t *testing.T) {
+	handler, _ := newMockHandler(t, mockupstream.Options{ChunkRunes: 3})
+	body := `{"model":"m","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"system","content":"target_language: ja"},{"role":"user","content":"Good morning"}]}`
+	rec := serveJSON(handler, "/v1/chat/completions", body)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	events := parseSSE(t, rec.Body.String())
+	if last := events[len(events)-1]; last.Data != "[DONE]" {
+		t.Fatalf("stream does not end with [DONE]: %q", last.Data)
+	}
+	var text strings.Builder
+	var finish string
+	var usage map[string]int
+	for _, event := range events[:len(events)-1] {
+		var chunk struct {
+			Choices []struct {
+				Delta        struct{ Content string }
+				FinishReason *string `json:"finish_reason"`
+			}
+			Usage map[string]int
+		}
+		decodeJSON(t, event.Data, &chunk)
+		for _, choice := range chunk.Choices {
+			text.WriteString(choice.Delta.Content)
+			if choice.FinishReason != nil {
+				finish = *choice.FinishReason
+			}
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+	if want := mockupstream.Translation("Good morning", "ja"); text.String() != want {
+		t.Errorf("streamed text = %q, want %q", text.String(), want)
+	}
+	if finish != "stop" {
+		t.Errorf("finish_reason = %q, want stop", finish)
+	}
+	if usage["prompt_tokens"] != 12 || usage["completion_tokens"] != 17 || usage["total_tokens"] != 29 {
+		t.Errorf("usage chunk = %v", usage)
+	}
+}
+
+func TestResponsesMockUpstream(t *testing.T) {
+	handler, mock := newMockHandler(t, mockupstream.Options{})
+	rec := serveJSON(handler, "/v1/responses", responsesBody("m", "Hello", false))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Object string
+		Model  string
+		Status string
+		Output []struct {
+			Content []struct{ Text string }
+		}
+		Usage map[string]int
+	}
+	decodeJSON(t, rec.Body.String(), &resp)
+	if resp.Object != "response" || resp.Model != "m" || resp.Status != "completed" {
+		t.Errorf("response = %+v", resp)
+	}
+	if len(resp.Output) != 1 || len(resp.Output[0].Content) != 1 || resp.Output[0].Content[0].Text != mockupstream.Translation("Hello", "ja") {
+		t.Errorf("output = %+v", resp.Output)
+	}
+	if resp.Usage["input_tokens"] != 5 || resp.Usage["output_tokens"] != 10 || resp.Usage["total_tokens"] != 15 {
+		t.Errorf("usage = %v", resp.Usage)
+	}
+	if requests := mock.Requests(); len(requests) != 1 || requests[0].TargetLanguage != "ja" {
+		t.Errorf("upstream requests = %+v", requests)
+	}
+}
+
+func TestResponsesStreamMockUpstream(t *testing.T) {
+	handler, _ := newMockHandler(t, mockupstream.Options{ChunkRunes: 3})
+	rec := serveJSON(handler, "/v1/responses", responsesBody("m", "Hello", true))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	events := parseSSE(t, rec.Body.String())
+	var names []string
+	var text strings.Builder
+	for i, event := range events {
+		var payload struct {
+			Type     string
+			Sequence int `json:"sequence_number"`
+			Delta    string
+			Response struct {
+				Status string
+				Usage  map[string]int
+			}
+		}
+		decodeJSON(t, event.Data, &payload)
+		if payload.Type != event.Name || payload.Sequence != i {
+			t.Errorf("event %d: type %q sequence %d, want %q %d", i, payload.Type, payload.Sequence, event.Name, i)
+		}
+		if event.Name == "response.output_text.delta" {
+			text.WriteString(payload.Delta)
+		} else {
+			names = append(names, event.Name)
+		}
+		if event.Name == "response.completed" {
+			if payload.Response.Status != "completed" || payload.Response.Usage["input_tokens"] != 5 || payload.Response.Usage["output_tokens"] != 10 {
+				t.Errorf("completed response = %+v", payload.Response)
+			}
+		}
+	}
+	if want := []string{"response.created", "response.output_text.done", "response.completed"}; strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("events = %v, want %v around the deltas", names, want)
+	}
+	if want := mockupstream.Translation("Hello", "ja"); text.String() != want {
+		t.Errorf("streamed text = %q, want %q", text.String(), want)
+	}
+}
+
+func TestMockUpstreamFaults(t *testing.T) {
+	handler, _ := newMockHandler(t, mockupstream.Options{ChunkRunes: 3, RetryAfter: 7})
+	for _, tc := range []struct {
+		model      string
+		status     int
+		code       string
+		retryAfter string
+	}{
+		{model: "mock-429", status: http.StatusTooManyRequests, code: "rate_limit_exceeded", retryAfter: "7"},
+		{model: "mock-500", status: http.StatusBadGateway, code: "upstream_error"},
+		{model: "mock-malformed", status: http.StatusInternalServerError, code: "internal_error"},
+		{model: "mock-truncated", status: http.StatusInternalServerError, code: "internal_error"},
+	} {
+		for _, path := range []string{"/v1/chat/completions", "/v1/responses"} {
+			t.Run(path+"/"+tc.model, func(t *testing.T) {
+				body := chatBody(tc.model, "Hello", false)
+				if path == "/v1/responses" {
+					body = responsesBody(tc.model, "Hello", false)
+				}
+				rec := serveJSON(handler, path, body)
+				var resp struct{ Error errorBody }
+				decodeJSON(t, rec.Body.String(), &resp)
+				if rec.Code != tc.status || resp.Error.Code != tc.code {
+					t.Errorf("status %d code %q, want %d %q", rec.Code, resp.Error.Code, tc.status, tc.code)
+				}
+				if got := rec.Header().Get("Retry-After"); got != tc.retryAfter {
+					t.Errorf("Retry-After = %q, want %q", got, tc.retryAfter)
+				}
+			})
+		}
+	}
+}
+
+func TestMockUpstreamStreamFaults(t *testing.T) {
+	handler, _ := newMockHandler(t, mockupstream.Options{ChunkRunes: 3})
+	translation := mockupstream.Translation("Hello", "ja")
+
+	t.Run("status", func(t *testing.T) {
+		for model, status := range map[string]int{"mock-429": http.StatusTooManyRequests, "mock-500": http.StatusBadGateway} {
+			if rec := serveJSON(handler, "/v1/chat/completions", chatBody(model, "Hello", true)); rec.Code != status {
+				t.Errorf("%s: status = %d, want %d", model, rec.Code, status)
+			}
+			if rec := serveJSON(handler, "/v1/responses", responsesBody(model, "Hello", true)); rec.Code != status {
+				t.Errorf("%s responses: status = %d, want %d", model, rec.Code, status)
+			}
+		}
+	})
+
+	chatEvents := func(t *testing.T, model string) (string, []sseEvent) {
+		rec := serveJSON(handler, "/v1/chat/completions", chatBody(model, "Hello", true))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+		events := parseSSE(t, rec.Body.String())
+		if events[len(events)-1].Data != "[DONE]" {
+			t.Fatalf("stream does not end with [DONE]")
+		}
+		var text strings.Builder
+		for _, event := range events[:len(events)-1] {
+			var chunk struct {
+				Choices []struct{ Delta struct{ Content string } }
+			}
+			decodeJSON(t, event.Data, &chunk)
+			for _, choice := range chunk.Choices {
+				text.WriteString(choice.Delta.Content)
+			}
+		}
+		return text.String(), events
+	}
+
+	t.Run("chat/malformed", func(t *testing.T) {
+		// 无法解析的 SSE 行被跳过，其余 delta 照常转发。
+		text, events := chatEvents(t, "mock-malformed")
+		if text != translation {
+			t.Errorf("text = %q, want %q", text, translation)
+		}
+		if last := events[len(events)-2].Data; !strings.Contains(last, `"finish_reason":"stop"`) {
+			t.Errorf("last chunk = %s, want finish_reason stop", last)
+		}
+	})
+
+	t.Run("chat/truncated", func(t *testing.T) {
+		text, events := chatEvents(t, "mock-truncated")
+		if text == "" || !strings.HasPrefix(translation, text) || text == translation {
+			t.Errorf("text = %q, want a strict prefix of %q", text, translation)
+		}
+		var last struct{ Error errorBody }
+		decodeJSON(t, events[len(events)-2].Data, &last)
+		if last.Error.Code != "stream_error" {
+			t.Errorf("last chunk = %s, want a stream_error", events[len(events)-2].Data)
+		}
+	})
+
+	responsesEvents := func(t *testing.T, model string) []string {
+		rec := serveJSON(handler, "/v1/responses", responsesBody(model, "Hello", true))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+		}
+		var names []string
+		for _, event := range parseSSE(t, rec.Body.String()) {
+			if event.Name != "response.output_text.delta" {
+				names = append(names, event.Name)
+			}
+		}
+		return names
+	}
+
+	t.Run("responses/malformed", func(t *testing.T) {
+		if got := responsesEvents(t, "mock-malformed"); strings.Join(got, ",") != "response.created,response.output_text.done,response.completed" {
+			t.Errorf("events = %v", got)
+		}
+	})
+
+	t.Run("responses/truncated", func(t *testing.T) {
+		if got := responsesEvents(t, "mock-truncated"); strings.Join(got, ",") != "response.created,error,response.failed" {
+			t.Errorf("events = %v", got)
+		}
+	})
+}
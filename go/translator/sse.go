@@ -0,0 +1,232 @@
+package translator
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// isEventStream 判断上游响应是否为 SSE；Content-Type 可能带 charset 等参数或大小写不一。
+func isEventStream(header http.Header) bool {
+	return strings.HasPrefix(strings.ToLower(header.Get("Content-Type")), "text/event-stream")
+}
+
+type sseEvent struct {
+	Name string
+	Data string
+}
+
+// sseScanner 按 "\n\n" 切分上游 SSE 字节流，解析 event: 与 data: 行。
+type sseScanner struct {
+	reader  *bufio.Reader
+	buffer  strings.Builder
+	pending []sseEvent
+	temp    []byte
+	err     error
+}
+
+func newSSEScanner(r io.Reader) *sseScanner {
+	return &sseScanner{reader: bufio.NewReader(r), temp: make([]byte, 4096)}
+}
+
+// Next 返回下一个完整事件；流结束时返回 io.EOF，读取失败时返回对应错误。
+func (s *sseScanner) Next() (sseEvent, error) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return sseEvent{}, s.err
+		}
+		n, err := s.reader.Read(s.temp)
+		if n > 0 {
+			s.buffer.Write(s.temp[:n])
+		}
+		s.drain()
+		if err != nil {
+			s.err = err
+		}
+	}
+	event := s.pending[0]
+	s.pending = s.pending[1:]
+	return event, nil
+}
+
+func (s *sseScanner) drain() {
+	for {
+		current := s.buffer.String()
+		idx := strings.Index(current, "\n\n")
+		if idx == -1 {
+			return
+		}
+		rawEvent := strings.ReplaceAll(current[:idx], "\r", "")
+		remaining := current[idx+2:]
+		s.buffer.Reset()
+		s.buffer.WriteString(remaining)
+		if strings.TrimSpace(rawEvent) == "" {
+			continue
+		}
+		event := sseEvent{}
+		dataLines := make([]string, 0)
+		for _, line := range strings.Split(rawEvent, "\n") {
+			if strings.HasPrefix(line, "event:") {
+				event.Name = strings.TrimSpace(line[6:])
+			} else if strings.HasPrefix(line, "data:") {
+				dataLines = append(dataLines, strings.TrimSpace(line[5:]))
+			}
+		}
+		event.Data = strings.Join(dataLines, "\n")
+		s.pending = append(s.pending, event)
+	}
+}
+
+// streamTimeouts 控制流式转发的心跳与超时，取值为 0 表示关闭对应功能。
+type streamTimeouts struct {
+	Heartbeat  time.Duration
+	FirstToken time.Duration
+	Idle       time.Duration
+}
+
+func streamTimeoutsFromConfig() streamTimeouts {
+	return streamTimeouts{
+		Heartbeat:  CONFIG.StreamHeartbeatInterval,
+		FirstToken: CONFIG.StreamFirstTokenTimeout,
+		Idle:       CONFIG.StreamIdleTimeout,
+	}
+}
+
+type sseResult struct {
+	event sseEvent
+	err   error
+}
+
+// sseRelay 在后台读取上游事件；等待期间定时发送 SSE 注释心跳，
+// 并在首个 token 前执行首 token 超时、之后执行相邻 chunk 之间的空闲超时。
+type sseRelay struct {
+	results      chan sseResult
+	done         chan struct{}
+	timeouts     streamTimeouts
+	heartbeat    func() error
+	started      time.Time
+	lastActivity time.Time
+	gotToken     bool
+}
+
+func newSSERelay(r io.Reader, timeouts streamTimeouts, heartbeat func() error) *sseRelay {
+	relay := &sseRelay{
+		results:   make(chan sseResult),
+		done:      make(chan struct{}),
+		timeouts:  timeouts,
+		heartbeat: heartbeat,
+		started:   time.Now(),
+	}
+	scanner := newSSEScanner(r)
+	go func() {
+		for {
+			event, err := scanner.Next()
+			select {
+			case relay.results <- sseResult{event: event, err: err}:
+			case <-relay.done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return relay
+}
+
+// markToken 记录已收到输出 token，此后改用相邻事件之间的空闲超时。
+func (r *sseRelay) markToken() {
+	r.gotToken = true
+	r.lastActivity = time.Now()
+}
+
+// Next 返回下一个上游事件；超时时返回 upstreamTimeout 类的 *apiError。
+func (r *sseRelay) Next() (sseEvent, error) {
+	var heartbeatC <-chan time.Time
+	if r.timeouts.Heartbeat > 0 && r.heartbeat != nil {
+		ticker := time.NewTicker(r.timeouts.Heartbeat)
+		defer ticker.Stop()
+		heartbeatC = ticker.C
+	}
+
+	var timeoutC <-chan time.Time
+	var timeoutErr *apiError
+	switch {
+	case !r.gotToken && r.timeouts.FirstToken > 0:
+		timer := time.NewTimer(time.Until(r.started.Add(r.timeouts.FirstToken)))
+		defer timer.Stop()
+		timeoutC = timer.C
+		timeoutErr = newAPIError("streamFirstTokenTimeout", r.timeouts.FirstToken.String())
+	case r.gotToken && r.timeouts.Idle > 0:
+		timer := time.NewTimer(time.Until(r.lastActivity.Add(r.timeouts.Idle)))
+		defer timer.Stop()
+		timeoutC = timer.C
+		timeoutErr = newAPIError("streamIdleTimeout", r.timeouts.Idle.String())
+	}
+
+	for {
+		select {
+		case result := <-r.results:
+			if r.gotToken {
+				r.lastActivity = time.Now()
+			}
+			return result.event, result.err
+		case <-heartbeatC:
+			if err := r.heartbeat(); err != nil {
+				return sseEvent{}, err
+			}
+		case <-timeoutC:
+			return sseEvent{}, timeoutErr
+		}
+	}
+}
+
+// Close 停止后台读取；调用方仍需关闭上游 Body 以解除阻塞的 Read。
+func (r *sseRelay) Close() {
+	close(r.done)
+}
+
+// writeSSEHeartbeat 写出一条 SSE 注释，客户端会忽略它，但能让负载均衡器认为连接仍然活跃。
+func writeSSEHeartbeat(w io.Writer, flusher http.Flusher) error {
+	if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	if flusher != nil {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// streamWriteTimeout 是流式响应单次写入的期限。
+const streamWriteTimeout = 30 * time.Second
+
+// deadlineWriter 在每次写入前顺延写超时，使长时间的流不受 http.Server.WriteTimeout 的整体限制，
+// 同时仍能及时发现卡住的客户端。
+type deadlineWriter struct {
+	http.ResponseWriter
+	controller *http.ResponseController
+}
+
+func newDeadlineWriter(w http.ResponseWriter) *deadlineWriter {
+	return &deadlineWriter{ResponseWriter: w, controller: http.NewResponseController(w)}
+}
+
+func (d *deadlineWriter) extend() {
+	_ = d.controller.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+}
+
+func (d *deadlineWriter) Write(b []byte) (int, error) {
+	d.extend()
+	return d.ResponseWriter.Write(b)
+}
+
+func (d *deadlineWriter) Flush() {
+	d.extend()
+	_ = d.controller.Flush()
+}
+
+func (d *deadlineWriter) Unwrap() http.ResponseWriter {
+	return d.ResponseWriter
+}
@@ -0,0 +1,482 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 轻量级的 OpenTelemetry 兼容追踪实现：只依赖标准库，
+// 通过 OTLP/HTTP（JSON 编码）导出 span，并使用 W3C traceparent 传播上下文。
+
+type spanKind int
+
+const (
+	spanKindInternal spanKind = 1
+	spanKindServer   spanKind = 2
+	spanKindClient   spanKind = 3
+)
+
+// spanStatusError 为 OTLP 的 STATUS_CODE_ERROR；未出错的 span 不设置状态。
+const spanStatusError = 2
+
+type spanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+func (sc spanContext) isValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// traceparent 按 W3C Trace Context 规范格式化为请求头值。
+func (sc spanContext) traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(sc.TraceID[:]), hex.EncodeToString(sc.SpanID[:]), flags)
+}
+
+func parseTraceparent(header string) (spanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return spanContext{}, false
+	}
+	var sc spanContext
+	traceID, err := hex.DecodeString(parts[1])
+	if err != nil || len(traceID) != 16 {
+		return spanContext{}, false
+	}
+	spanID, err := hex.DecodeString(parts[2])
+	if err != nil || len(spanID) != 8 {
+		return spanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return spanContext{}, false
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Sampled = flags[0]&0x01 == 0x01
+	if !sc.isValid() {
+		return spanContext{}, false
+	}
+	return sc, true
+}
+
+type spanData struct {
+	Name          string
+	Kind          spanKind
+	Context       spanContext
+	ParentSpanID  [8]byte
+	Start         time.Time
+	End           time.Time
+	Attributes    map[string]interface{}
+	StatusCode    int
+	StatusMessage string
+}
+
+type span struct {
+	tracer *tracer
+	mu     sync.Mutex
+	data   spanData
+	ended  bool
+}
+
+// setAttr 记录 span 属性；nil span（追踪未启用）时为空操作。
+func (s *span) setAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = map[string]interface{}{}
+	}
+	s.data.Attributes[key] = value
+}
+
+func (s *span) setError(message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.StatusCode = spanStatusError
+	s.data.StatusMessage = message
+}
+
+func (s *span) end() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	attrs := make(map[string]interface{}, len(s.data.Attributes))
+	for k, v := range s.data.Attributes {
+		attrs[k] = v
+	}
+	data.Attributes = attrs
+	s.mu.Unlock()
+	s.tracer.export(&data)
+}
+
+func (s *span) spanContext() spanContext {
+	if s == nil {
+		return spanContext{}
+	}
+	return s.data.Context
+}
+
+type spanExporter interface {
+	ExportSpans(ctx context.Context, serviceName string, spans []*spanData) error
+}
+
+type tracer struct {
+	serviceName string
+	exporter    spanExporter
+	queue       chan *spanData
+	interval    time.Duration
+	batchSize   int
+	done        chan struct{}
+	flushReq    chan chan struct{}
+}
+
+// newTracer 创建同步导出的 tracer：span 结束即调用 exporter，适合配合内存 exporter 做测试。
+func newTracer(serviceName string, exporter spanExporter) *tracer {
+	return &tracer{serviceName: serviceName, exporter: exporter}
+}
+
+// newBatchingTracer 创建后台批量导出的 tracer，避免请求路径上阻塞于网络 I/O。
+func newBatchingTracer(serviceName string, exporter spanExporter, interval time.Duration, batchSize int) *tracer {
+	t := &tracer{
+		serviceName: serviceName,
+		exporter:    exporter,
+		queue:       make(chan *spanData, 2048),
+		interval:    interval,
+		batchSize:   batchSize,
+		done:        make(chan struct{}),
+		flushReq:    make(chan chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+func newTracerFromEnv() *tracer {
+	endpoint := strings.TrimSpace(CONFIG.OTLPTracesEndpoint)
+	if endpoint == "" {
+		return nil
+	}
+	exporter := &otlpHTTPExporter{
+		endpoint: endpoint,
+		headers:  CONFIG.OTLPHeaders,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	log.Printf("OTLP trace export enabled: %s", endpoint)
+	return newBatchingTracer(CONFIG.ServiceName, exporter, 5*time.Second, 512)
+}
+
+func (t *tracer) run() {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	batch := make([]*spanData, 0, t.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.ExportSpans(context.Background(), t.serviceName, batch); err != nil {
+			log.Printf("failed to export spans: %v", err)
+		}
+		batch = make([]*spanData, 0, t.batchSize)
+	}
+	for {
+		select {
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) >= t.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case ack := <-t.flushReq:
+			for drained := false; !drained; {
+				select {
+				case data := <-t.queue:
+					batch = append(batch, data)
+				default:
+					drained = true
+				}
+			}
+			flush()
+			close(ack)
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *tracer) export(data *spanData) {
+	if t == nil || t.exporter == nil {
+		return
+	}
+	if t.queue == nil {
+		if err := t.exporter.ExportSpans(context.Background(), t.serviceName, []*spanData{data}); err != nil {
+			log.Printf("failed to export span: %v", err)
+		}
+		return
+	}
+	select {
+	case t.queue <- data:
+	default:
+		log.Printf("trace queue full, dropping span %s", data.Name)
+	}
+}
+
+// flush 等待队列中的 span 全部导出；同步 tracer 无需等待。
+func (t *tracer) flush() {
+	if t == nil || t.queue == nil {
+		return
+	}
+	ack := make(chan struct{})
+	t.flushReq <- ack
+	<-ack
+}
+
+func (t *tracer) shutdown() {
+	if t == nil || t.queue == nil {
+		return
+	}
+	t.flush()
+	close(t.done)
+}
+
+type spanContextKey struct{}
+type remoteParentKey struct{}
+
+func spanFromContext(ctx context.Context) *span {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(spanContextKey{}).(*span)
+	return s
+}
+
+// contextWithRemoteParent 解析入站 traceparent，使本服务的 span 挂到调用方的 trace 下。
+func contextWithRemoteParent(ctx context.Context, header string) context.Context {
+	if header == "" {
+		return ctx
+	}
+	if sc, ok := parseTraceparent(header); ok {
+		return context.WithValue(ctx, remoteParentKey{}, sc)
+	}
+	return ctx
+}
+
+// start 开启一个子 span；tracer 为 nil 时返回 nil span，调用方无需判空。
+func (t *tracer) start(ctx context.Context, name string, kind spanKind) (context.Context, *span) {
+	if t == nil {
+		return ctx, nil
+	}
+	var parent spanContext
+	if p := spanFromContext(ctx); p != nil {
+		parent = p.data.Context
+	} else if remote, ok := ctx.Value(remoteParentKey{}).(spanContext); ok {
+		parent = remote
+	}
+
+	sc := spanContext{Sampled: true}
+	if parent.isValid() {
+		sc.TraceID = parent.TraceID
+		sc.Sampled = parent.Sampled
+	} else {
+		randomBytes(sc.TraceID[:])
+	}
+	randomBytes(sc.SpanID[:])
+
+	s := &span{
+		tracer: t,
+		data: spanData{
+			Name:         name,
+			Kind:         kind,
+			Context:      sc,
+			ParentSpanID: parent.SpanID,
+			Start:        time.Now(),
+		},
+	}
+	return context.WithValue(ctx, spanContextKey{}, s), s
+}
+
+func randomBytes(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		for i := range b {
+			b[i] = idAlphabet[idSource.Intn(len(idAlphabet))]
+		}
+	}
+}
+
+// injectTraceparent 将当前 span 写入出站请求头，向 Ark 传播调用链。
+func injectTraceparent(ctx context.Context, header http.Header) {
+	if s := spanFromContext(ctx); s != nil {
+		header.Set("traceparent", s.spanContext().traceparent())
+	}
+}
+
+func recordUsageAttributes(s *span, inputTokens, outputTokens int) {
+	s.setAttr("gen_ai.usage.input_tokens", inputTokens)
+	s.setAttr("gen_ai.usage.output_tokens", outputTokens)
+}
+
+// otlpHTTPExporter 以 OTLP/HTTP JSON 协议推送 span。
+type otlpHTTPExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+func (e *otlpHTTPExporter) ExportSpans(ctx context.Context, serviceName string, spans []*spanData) error {
+	body, err := json.Marshal(buildOTLPTracePayload(serviceName, spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("otlp exporter: %s", resp.Status)
+	}
+	return nil
+}
+
+func buildOTLPTracePayload(serviceName string, spans []*spanData) map[string]interface{} {
+	otlpSpans := make([]map[string]interface{}, 0, len(spans))
+	for _, data := range spans {
+		item := map[string]interface{}{
+			"traceId":           hex.EncodeToString(data.Context.TraceID[:]),
+			"spanId":            hex.EncodeToString(data.Context.SpanID[:]),
+			"name":              data.Name,
+			"kind":              int(data.Kind),
+			"startTimeUnixNano": strconv.FormatInt(data.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(data.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(data.Attributes),
+			"status": map[string]interface{}{
+				"code":    data.StatusCode,
+				"message": data.StatusMessage,
+			},
+		}
+		if data.ParentSpanID != [8]byte{} {
+			item["parentSpanId"] = hex.EncodeToString(data.ParentSpanID[:])
+		}
+		otlpSpans = append(otlpSpans, item)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []map[string]interface{}{
+			{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(map[string]interface{}{"service.name": serviceName}),
+				},
+				"scopeSpans": []map[string]interface{}{
+					{
+						"scope": map[string]interface{}{"name": "doubao-translation-proxy"},
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	}
+}
+
+func otlpAttributes(attrs map[string]interface{}) []map[string]interface{} {
+	result := make([]map[string]interface{}, 0, len(attrs))
+	for key, value := range attrs {
+		var v map[string]interface{}
+		switch val := value.(type) {
+		case string:
+			v = map[string]interface{}{"stringValue": val}
+		case bool:
+			v = map[string]interface{}{"boolValue": val}
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(val)}
+		case int64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(val, 10)}
+		case float64:
+			v = map[string]interface{}{"doubleValue": val}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprintf("%v", val)}
+		}
+		result = append(result, map[string]interface{}{"key": key, "value": v})
+	}
+	return result
+}
+
+func parseOTLPHeaders(raw string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(raw, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if key != "" {
+			headers[key] = strings.TrimSpace(value)
+		}
+	}
+	return headers
+}
+
+// statusRecorder 记录下游响应状态码，同时保留 http.Flusher 能力以支持 SSE。
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,272 @@
+package translator
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memoryExporter 在进程内收集 span，供测试断言使用。
+type memoryExporter struct {
+	mu    sync.Mutex
+	spans []*spanData
+}
+
+func (e *memoryExporter) ExportSpans(_ context.Context, _ string, spans []*spanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, spans...)
+	return nil
+}
+
+func (e *memoryExporter) Spans() []*spanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := make([]*spanData, len(e.spans))
+	copy(out, e.spans)
+	return out
+}
+
+const (
+	inboundTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+	inboundParentID    = "00f067aa0ba902b7"
+	inboundTraceparent = "00-" + inboundTraceID + "-" + inboundParentID + "-01"
+)
+
+// stubTranslation 为 Ark 桩服务返回的译文，usage 按字节数计算，便于断言；原文为 stubFailText 时返回 429。
+const (
+	stubTranslation = "Bonjour tout le monde"
+	stubFailText    = "trigger upstream failure"
+)
+
+// newArkStub 启动一个按 Responses API 应答的 Ark 桩服务，并记录收到的 traceparent 请求头。
+func newArkStub(t *testing.T) (string, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var traceparents []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceparents = append(traceparents, r.Header.Get("traceparent"))
+		mu.Unlock()
+
+		var payload struct {
+			Input  []struct{ Content []struct{ Text string } }
+			Stream bool
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &payload); err != nil || len(payload.Input) == 0 || len(payload.Input[0].Content) == 0 {
+			http.Error(w, `{"error":{"message":"bad request"}}`, http.StatusBadRequest)
+			return
+		}
+		if payload.Input[0].Content[0].Text == stubFailText {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			io.WriteString(w, `{"error":{"message":"quota exceeded","type":"rate_limit_error"}}`)
+			return
+		}
+		usage := map[string]int{
+			"input_tokens":  len(payload.Input[0].Content[0].Text),
+			"output_tokens": len(stubTranslation),
+			"total_tokens":  len(payload.Input[0].Content[0].Text) + len(stubTranslation),
+		}
+		if !payload.Stream {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":     "resp-stub",
+				"object": "response",
+				"output": []map[string]interface{}{{
+					"type": "message", "role": "assistant",
+					"content": []map[string]interface{}{{"type": "output_text", "text": stubTranslation}},
+				}},
+				"usage": usage,
+			})
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, word := range strings.SplitAfter(stubTranslation, " ") {
+			data, _ := json.Marshal(map[string]interface{}{"type": "response.output_text.delta", "delta": word})
+			fmt.Fprintf(w, "event: response.output_text.delta\ndata: %s\n\n", data)
+		}
+		data, _ := json.Marshal(map[string]interface{}{"type": "response.completed", "response": map[string]interface{}{"usage": usage}})
+		fmt.Fprintf(w, "event: response.completed\ndata: %s\n\n", data)
+	}))
+	t.Cleanup(ts.Close)
+	return ts.URL, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), traceparents...)
+	}
+}
+
+// newTracedHandler 返回指向 Ark 桩服务的 Handler 与收集其 span 的 memoryExporter。
+func newTracedHandler(t *testing.T) (*Handler, *memoryExporter, func() []string) {
+	t.Helper()
+	baseURL, traceparents := newArkStub(t)
+
+	exporter := &memoryExporter{}
+	srv := newHandler(newTracer("doubao-test", exporter))
+	srv.baseURL = baseURL
+	return srv, exporter, traceparents
+}
+
+func findSpan(t *testing.T, spans []*spanData, name string) *spanData {
+	t.Helper()
+	for _, sd := range spans {
+		if sd.Name == name {
+			return sd
+		}
+	}
+	names := make([]string, len(spans))
+	for i, sd := range spans {
+		names[i] = sd.Name
+	}
+	t.Fatalf("span %q not exported; got %v", name, names)
+	return nil
+}
+
+func assertAttr(t *testing.T, sd *spanData, key string, want interface{}) {
+	t.Helper()
+	if got, ok := sd.Attributes[key]; !ok || got != want {
+		t.Errorf("span %q attribute %s = %v (present %v), want %v", sd.Name, key, got, ok, want)
+	}
+}
+
+func TestTracingChatCompletions(t *testing.T) {
+	srv, exporter, traceparents := newTracedHandler(t)
+
+	body := `{"model":"m","messages":[{"role":"system","content":"target_language: fr"},{"role":"user","content":"Hello"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-key")
+	req.Header.Set("traceparent", inboundTraceparent)
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	spans := exporter.Spans()
+	root := findSpan(t, spans, "POST /v1/chat/completions")
+	if got := hex.EncodeToString(root.Context.TraceID[:]); got != inboundTraceID {
+		t.Errorf("root trace id = %s, want inbound %s", got, inboundTraceID)
+	}
+	if got := hex.EncodeToString(root.ParentSpanID[:]); got != inboundParentID {
+		t.Errorf("root parent span id = %s, want inbound %s", got, inboundParentID)
+	}
+	if root.Kind != spanKindServer {
+		t.Errorf("root kind = %d, want server", root.Kind)
+	}
+	assertAttr(t, root, "http.response.status_code", http.StatusOK)
+	assertAttr(t, root, "gen_ai.request.model", "m")
+	assertAttr(t, root, "gen_ai.usage.input_tokens", len("Hello"))
+	assertAttr(t, root, "gen_ai.usage.output_tokens", len(stubTranslation))
+
+	parse := findSpan(t, spans, "request.parse")
+	assertAttr(t, parse, "http.request.body.size", len(body))
+	resolve := findSpan(t, spans, "translation.resolve_options")
+	assertAttr(t, resolve, "translation.target_language", "fr")
+	upstream := findSpan(t, spans, "doubao.request")
+	if upstream.Kind != spanKindClient {
+		t.Errorf("doubao.request kind = %d, want client", upstream.Kind)
+	}
+	assertAttr(t, upstream, "gen_ai.request.model", "m")
+	assertAttr(t, upstream, "http.response.status_code", http.StatusOK)
+	for _, sd := range []*spanData{parse, resolve, upstream} {
+		if sd.Context.TraceID != root.Context.TraceID {
+			t.Errorf("span %q is not in the inbound trace", sd.Name)
+		}
+		if sd.ParentSpanID != root.Context.SpanID {
+			t.Errorf("span %q parent = %x, want root %x", sd.Name, sd.ParentSpanID, root.Context.SpanID)
+		}
+	}
+
+	got := traceparents()
+	if want := upstream.Context.traceparent(); len(got) != 1 || got[0] != want {
+		t.Errorf("outbound traceparent = %q, want %q", got, want)
+	}
+}
+
+func TestTracingStreamRelay(t *testing.T) {
+	srv, exporter, traceparents := newTracedHandler(t)
+
+	body := `{"model":"m","stream":true,"messages":[{"role":"system","content":"target_language: fr"},{"role":"user","content":"Good morning"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.HasSuffix(rec.Body.String(), "data: [DONE]\n\n") {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	spans := exporter.Spans()
+	root := findSpan(t, spans, "POST /v1/chat/completions")
+	if root.ParentSpanID != [8]byte{} {
+		t.Errorf("root span without inbound traceparent has parent %x", root.ParentSpanID)
+	}
+	relay := findSpan(t, spans, "stream.relay")
+	if relay.Context.TraceID != root.Context.TraceID {
+		t.Error("stream.relay is not in the request trace")
+	}
+	if chunks, _ := relay.Attributes["stream.chunks"].(int); chunks < 2 {
+		t.Errorf("stream.chunks = %v, want several chunks", relay.Attributes["stream.chunks"])
+	}
+	for _, sd := range []*spanData{relay, root} {
+		assertAttr(t, sd, "gen_ai.usage.input_tokens", len("Good morning"))
+		assertAttr(t, sd, "gen_ai.usage.output_tokens", len(stubTranslation))
+	}
+
+	upstream := findSpan(t, spans, "doubao.request")
+	got := traceparents()
+	if want := upstream.Context.traceparent(); len(got) != 1 || got[0] != want {
+		t.Errorf("outbound traceparent = %q, want %q", got, want)
+	}
+	if !strings.HasPrefix(got[0], "00-"+hex.EncodeToString(root.Context.TraceID[:])+"-") {
+		t.Errorf("outbound traceparent %q does not carry the request trace id", got[0])
+	}
+}
+
+func TestTracingUpstreamError(t *testing.T) {
+	srv, exporter, _ := newTracedHandler(t)
+
+	body := `{"model":"m","input":"` + stubFailText + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/responses", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code < http.StatusBadRequest {
+		t.Fatalf("status = %d, want an error; body %s", rec.Code, rec.Body)
+	}
+
+	spans := exporter.Spans()
+	upstream := findSpan(t, spans, "doubao.request")
+	assertAttr(t, upstream, "http.response.status_code", http.StatusTooManyRequests)
+	if upstream.StatusCode != spanStatusError || upstream.StatusMessage == "" {
+		t.Errorf("doubao.request status = %d %q, want error with message", upstream.StatusCode, upstream.StatusMessage)
+	}
+	root := findSpan(t, spans, "POST /v1/responses")
+	assertAttr(t, root, "http.response.status_code", rec.Code)
+}
+
+func TestParseTraceparent(t *testing.T) {
+	sc, ok := parseTraceparent(inboundTraceparent)
+	if !ok || !sc.Sampled || sc.traceparent() != inboundTraceparent {
+		t.Errorf("parseTraceparent(%q) = %+v, %v", inboundTraceparent, sc, ok)
+	}
+	for _, header := range []string{
+		"",
+		"ff-" + inboundTraceID + "-" + inboundParentID + "-01",
+		"00-" + strings.Repeat("0", 32) + "-" + inboundParentID + "-01",
+		"00-" + inboundTraceID + "-" + strings.Repeat("0", 16) + "-01",
+		"00-" + inboundTraceID + "-" + inboundParentID,
+	} {
+		if _, ok := parseTraceparent(header); ok {
+			t.Errorf("parseTraceparent accepted %q", header)
+		}
+	}
+}
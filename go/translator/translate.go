@@ -0,0 +1,190 @@
+package translator
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// 面向非 OpenAI 协议入口（WebSocket 等）的单段翻译：复用选项解析结果、同语种直通、
+// 上游客户端与 SSE 解析，返回纯文本结果与用量。
+
+type translationRequest struct {
+	Auth    string
+	Model   string
+	Text    string
+	Options translationOptions
+	// Overrides 为原始的 translation_options / metadata，用于读取 skip_same_language。
+	Overrides []interface{}
+}
+
+type translationResult struct {
+	Text                   string
+	DetectedSourceLanguage string
+	Passthrough            bool
+	InputTokens            int
+	OutputTokens           int
+	TotalTokens            int
+}
+
+// translateText 执行一次翻译；onDelta 非空时以流式请求上游，并按到达顺序回调增量文本。
+func (s *Handler) translateText(ctx context.Context, req translationRequest, onDelta func(string) error) (translationResult, error) {
+	result := translationResult{DetectedSourceLanguage: detectSourceLanguage(req.Options, req.Text)}
+	updateInflight(ctx, func(inflight *inflightRequest) { inflight.Model, inflight.Stream = req.Model, onDelta != nil })
+	ctx = withUsageMeta(ctx, req.Model, req.Options, result.DetectedSourceLanguage, req.Text)
+
+	if source, ok := passthroughSource(req.Options, req.Text, req.Overrides...); ok {
+		spanFromContext(ctx).setAttr("translation.passthrough", true)
+		result.Text = req.Text
+		result.DetectedSourceLanguage = source
+		result.Passthrough = true
+		if onDelta != nil && req.Text != "" {
+			if err := onDelta(req.Text); err != nil {
+				return result, err
+			}
+		}
+		return result, nil
+	}
+
+	isStream := onDelta != nil
+	payload := buildDoubaoPayload(req.Model, req.Options, req.Text, isStream)
+	ctx, err := s.protectPayload(ctx, &payload)
+	if err != nil {
+		return result, err
+	}
+	upstream, err := s.sendProtectedRequest(ctx, payload, req.Auth)
+	if err != nil {
+		return result, err
+	}
+	defer upstream.Body.Close()
+
+	if isStream && isEventStream(upstream.Header) {
+		err = collectUpstreamStream(upstream.Body, &result, newPlaceholderRestorer(ctx), onDelta)
+		// 非流式响应的占位符不一致已由 sendProtectedRequest 记账，流式响应只能在读完后校验。
+		if apiErr, ok := err.(*apiError); ok && apiErr.Template == "placeholderMismatch" {
+			s.recordMismatchUsage(ctx, result.InputTokens, result.OutputTokens, result.TotalTokens)
+		}
+	} else {
+		err = collectUpstreamJSON(upstream.Body, &result)
+		if err == nil {
+			result.Text, err = restoreText(ctx, result.Text)
+		}
+		if err == nil && onDelta != nil {
+			err = onDelta(result.Text)
+		}
+	}
+	if err != nil {
+		return result, err
+	}
+	recordUsageAttributes(spanFromContext(ctx), result.InputTokens, result.OutputTokens)
+	s.recordUsage(ctx, result.InputTokens, result.OutputTokens, result.TotalTokens, result.Text)
+	return result, nil
+}
+
+func collectUpstreamJSON(body io.Reader, result *translationResult) error {
+	responseBytes, err := io.ReadAll(body)
+	if err != nil {
+		return newTransportError(err)
+	}
+	var parsed doubaoResponse
+	if err := json.Unmarshal(responseBytes, &parsed); err != nil {
+		return newAPIError("serverError")
+	}
+	if parsed.Error != nil {
+		return &upstreamError{Status: http.StatusBadGateway, Message: parsed.Error.Message}
+	}
+	result.Text = findAssistantMessage(parsed)
+	if result.Text == "" {
+		return newAPIError("upstreamNoResult")
+	}
+	result.InputTokens = usageInputTokens(parsed.Usage)
+	result.OutputTokens = usageOutputTokens(parsed.Usage)
+	result.TotalTokens = usageTotalTokens(parsed.Usage)
+	return nil
+}
+
+// collectUpstreamStream 读取上游 SSE，经 restorer 还原占位符后回调增量文本。
+func collectUpstreamStream(body io.Reader, result *translationResult, restorer *placeholderRestorer, onDelta func(string) error) error {
+	relay := newSSERelay(body, streamTimeoutsFromConfig(), nil)
+	defer relay.Close()
+
+	var text strings.Builder
+	emit := func(delta string) error {
+		if delta == "" {
+			return nil
+		}
+		text.WriteString(delta)
+		return onDelta(delta)
+	}
+	finish := func() error {
+		if err := emit(restorer.flush()); err != nil {
+			return err
+		}
+		result.Text = text.String()
+		if result.Text == "" {
+			return newAPIError("upstreamNoResult")
+		}
+		if err := restorer.verify(); err != nil {
+			return err
+		}
+		return nil
+	}
+	for {
+		event, err := relay.Next()
+		if err != nil {
+			if _, ok := err.(*apiError); ok {
+				return err
+			}
+			// 未收到 response.completed 或 [DONE] 就结束的流按截断处理，不返回残缺译文。
+			if err == io.EOF {
+				return newAPIError("streamInterrupted", io.ErrUnexpectedEOF.Error())
+			}
+			return newTransportError(err)
+		}
+		if event.Data == "[DONE]" {
+			return finish()
+		}
+		if event.Data == "" {
+			continue
+		}
+
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(event.Data), &data); err != nil {
+			continue
+		}
+		if response, ok := data["response"].(map[string]interface{}); ok {
+			if usage, ok := response["usage"].(map[string]interface{}); ok {
+				normalized := normalizeResponsesUsage(usage)
+				result.InputTokens = intFromInterface(normalized["input_tokens"])
+				result.OutputTokens = intFromInterface(normalized["output_tokens"])
+				result.TotalTokens = intFromInterface(normalized["total_tokens"])
+			}
+		}
+
+		switch event.Name {
+		case "response.output_text.delta":
+			relay.markToken()
+			delta, _ := toString(data["delta"])
+			if err := emit(restorer.push(delta)); err != nil {
+				return err
+			}
+		case "response.completed", "response.incomplete":
+			return finish()
+		case "error":
+			message, _ := normalizeResponsesErrorEvent(data)["message"].(string)
+			return &upstreamError{Status: http.StatusBadGateway, Message: message}
+		case "response.failed":
+			message := "上游接口错误"
+			if response, ok := data["response"].(map[string]interface{}); ok {
+				if errObj, ok := response["error"].(map[string]interface{}); ok {
+					if m, ok := errObj["message"].(string); ok && m != "" {
+						message = m
+					}
+				}
+			}
+			return &upstreamError{Status: http.StatusBadGateway, Message: message}
+		}
+	}
+}
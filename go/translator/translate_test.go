@@ -0,0 +1,45 @@
+package translator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCollectUpstreamStream(t *testing.T) {
+	delta := "event: response.output_text.delta\ndata: {\"delta\":\"Bonjour\"}\n\n"
+	tests := []struct {
+		name     string
+		body     string
+		wantText string
+		wantCode string
+	}{
+		{name: "completed", body: delta + "event: response.completed\ndata: {\"response\":{\"usage\":{\"input_tokens\":3,\"output_tokens\":2}}}\n\n", wantText: "Bonjour"},
+		{name: "done marker", body: delta + "data: [DONE]\n\n", wantText: "Bonjour"},
+		{name: "truncated", body: delta, wantCode: "stream_error"},
+		{name: "empty", body: "", wantCode: "stream_error"},
+		{name: "completed without text", body: "event: response.completed\ndata: {}\n\n", wantCode: "upstream_empty_result"},
+		{name: "failed", body: delta + "event: response.failed\ndata: {\"response\":{\"error\":{\"message\":\"boom\"}}}\n\n", wantCode: "upstream_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var result translationResult
+			var deltas strings.Builder
+			err := collectUpstreamStream(strings.NewReader(tt.body), &result, nil, func(d string) error {
+				deltas.WriteString(d)
+				return nil
+			})
+			if tt.wantCode == "" {
+				if err != nil || result.Text != tt.wantText || deltas.String() != tt.wantText {
+					t.Errorf("got %q (deltas %q), %v; want %q", result.Text, deltas.String(), err, tt.wantText)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("got %q, want error %s", result.Text, tt.wantCode)
+			}
+			if code := toAPIError(err).template().Code; code != tt.wantCode {
+				t.Errorf("error code = %s, want %s", code, tt.wantCode)
+			}
+		})
+	}
+}
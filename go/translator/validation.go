@@ -0,0 +1,205 @@
+package translator
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// 严格校验模式（STRICT_VALIDATION=true）：在调用上游前逐项检查请求，
+// 对类型错误、非文本内容、未知角色和超长输入返回带 param 的精确错误。
+
+var allowedMessageRoles = map[string]bool{
+	"system":    true,
+	"developer": true,
+	"user":      true,
+	"assistant": true,
+	"tool":      true,
+}
+
+func validateChatCompletionsRequest(req chatCompletionsRequest) *apiError {
+	if err := validateCommonFields(req.Stream, req.TranslationOptions, req.Metadata); err != nil {
+		return err
+	}
+	if err := validateStreamOptions(req.StreamOptions, req.Stream); err != nil {
+		return err
+	}
+	if req.Messages == nil {
+		return newAPIError("noMessage")
+	}
+	for i, msg := range req.Messages {
+		param := fmt.Sprintf("messages[%d]", i)
+		role := strings.ToLower(msg.Role)
+		if !allowedMessageRoles[role] {
+			return newAPIError("invalidValue", param+".role", msg.Role).withParam(param + ".role")
+		}
+		if role == "user" || role == "system" || role == "developer" {
+			if err := validateContentParts(msg.Content, param+".content"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateResponsesRequest(req responsesRequest) *apiError {
+	if err := validateCommonFields(req.Stream, req.TranslationOptions, req.Metadata); err != nil {
+		return err
+	}
+	switch input := req.Input.(type) {
+	case nil:
+		return newAPIError("noMessage").withParam("input")
+	case string:
+		return nil
+	case map[string]interface{}:
+		return validateResponsesSegment(input, "input")
+	case []interface{}:
+		for i, segment := range input {
+			param := fmt.Sprintf("input[%d]", i)
+			switch seg := segment.(type) {
+			case string:
+			case map[string]interface{}:
+				if err := validateResponsesSegment(seg, param); err != nil {
+					return err
+				}
+			default:
+				return newAPIError("invalidType", param, "string or object").withParam(param)
+			}
+		}
+		return nil
+	default:
+		return newAPIError("invalidType", "input", "string, array or object").withParam("input")
+	}
+}
+
+func validateResponsesSegment(segment map[string]interface{}, param string) *apiError {
+	if rawRole, ok := segment["role"]; ok {
+		role, isString := rawRole.(string)
+		if !isString {
+			return newAPIError("invalidType", param+".role", "string").withParam(param + ".role")
+		}
+		if !allowedMessageRoles[strings.ToLower(role)] {
+			return newAPIError("invalidValue", param+".role", role).withParam(param + ".role")
+		}
+	}
+	if content, ok := segment["content"]; ok {
+		return validateContentParts(content, param+".content")
+	}
+	if _, ok := segment["type"]; ok {
+		return validateContentPart(segment, param)
+	}
+	return nil
+}
+
+func validateContentParts(content interface{}, param string) *apiError {
+	switch val := content.(type) {
+	case nil:
+		return nil
+	case string:
+		return nil
+	case []interface{}:
+		for i, part := range val {
+			partParam := fmt.Sprintf("%s[%d]", param, i)
+			switch p := part.(type) {
+			case string:
+			case map[string]interface{}:
+				if err := validateContentPart(p, partParam); err != nil {
+					return err
+				}
+			default:
+				return newAPIError("invalidType", partParam, "string or object").withParam(partParam)
+			}
+		}
+		return nil
+	case map[string]interface{}:
+		return validateContentPart(val, param)
+	default:
+		return newAPIError("invalidType", param, "string or array").withParam(param)
+	}
+}
+
+func validateContentPart(part map[string]interface{}, param string) *apiError {
+	rawType, hasType := part["type"]
+	if !hasType {
+		if _, ok := part["text"].(string); ok {
+			return nil
+		}
+		return newAPIError("invalidValue", param, "missing text").withParam(param)
+	}
+	partType, ok := rawType.(string)
+	if !ok {
+		return newAPIError("invalidType", param+".type", "string").withParam(param + ".type")
+	}
+	switch partType {
+	case "text", "input_text":
+		if _, ok := part["text"].(string); !ok {
+			return newAPIError("invalidType", param+".text", "string").withParam(param + ".text")
+		}
+		return nil
+	default:
+		return newAPIError("unsupportedContentPart", partType).withParam(param + ".type")
+	}
+}
+
+func validateCommonFields(stream, translationOptions, metadata interface{}) *apiError {
+	if stream != nil {
+		if _, ok := stream.(bool); !ok {
+			return newAPIError("invalidType", "stream", "boolean").withParam("stream")
+		}
+	}
+	if translationOptions != nil {
+		if _, ok := translationOptions.(map[string]interface{}); !ok {
+			return newAPIError("invalidType", "translation_options", "object").withParam("translation_options")
+		}
+	}
+	if metadata != nil {
+		if _, ok := metadata.(map[string]interface{}); !ok {
+			return newAPIError("invalidType", "metadata", "object").withParam("metadata")
+		}
+	}
+	return nil
+}
+
+// validateStreamOptions 与 OpenAI 一致：stream_options 仅在 stream=true 时允许出现。
+func validateStreamOptions(streamOptions, stream interface{}) *apiError {
+	if streamOptions == nil {
+		return nil
+	}
+	options, ok := streamOptions.(map[string]interface{})
+	if !ok {
+		return newAPIError("invalidType", "stream_options", "object").withParam("stream_options")
+	}
+	if stream != true {
+		return newAPIError("streamOptionsWithoutStream")
+	}
+	if includeUsage, ok := options["include_usage"]; ok {
+		if _, isBool := includeUsage.(bool); !isBool {
+			return newAPIError("invalidType", "stream_options.include_usage", "boolean").withParam("stream_options.include_usage")
+		}
+	}
+	return nil
+}
+
+// validateInputLength 在严格模式下限制待翻译文本的字符数。
+func validateInputLength(text, param string) *apiError {
+	if CONFIG.MaxInputChars <= 0 {
+		return nil
+	}
+	if count := utf8.RuneCountInString(text); count > CONFIG.MaxInputChars {
+		return newAPIError("inputTooLong", count, CONFIG.MaxInputChars).withParam(param)
+	}
+	return nil
+}
+
+// validateSegmentText 校验 gRPC 与 Go 客户端传入的单段文本。
+func validateSegmentText(text, param string) error {
+	if strings.TrimSpace(text) == "" {
+		return newAPIError("noMessage").withParam(param)
+	}
+	if CONFIG.StrictValidation {
+		if err := validateInputLength(text, param); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package translator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateChatCompletionsRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  string
+		wantParam string
+	}{
+		{name: "valid", body: `{"model":"m","messages":[{"role":"system","content":"x"},{"role":"user","content":[{"type":"text","text":"hi"}]}]}`},
+		{name: "assistant content not checked", body: `{"model":"m","messages":[{"role":"assistant","content":[{"type":"image_url"}]},{"role":"user","content":"hi"}]}`},
+		{name: "missing messages", body: `{"model":"m"}`, wantCode: "missing_user_message", wantParam: "messages"},
+		{name: "stream not boolean", body: `{"model":"m","stream":"yes","messages":[]}`, wantCode: "invalid_type", wantParam: "stream"},
+		{name: "translation_options not object", body: `{"model":"m","translation_options":"fr","messages":[]}`, wantCode: "invalid_type", wantParam: "translation_options"},
+		{name: "metadata not object", body: `{"model":"m","metadata":[1],"messages":[]}`, wantCode: "invalid_type", wantParam: "metadata"},
+		{name: "unknown role", body: `{"model":"m","messages":[{"role":"robot","content":"hi"}]}`, wantCode: "invalid_value", wantParam: "messages[0].role"},
+		{name: "image part", body: `{"model":"m","messages":[{"role":"user","content":[{"type":"text","text":"a"},{"type":"image_url"}]}]}`, wantCode: "unsupported_content_part", wantParam: "messages[0].content[1].type"},
+		{name: "text part without text", body: `{"model":"m","messages":[{"role":"user","content":[{"type":"text","text":3}]}]}`, wantCode: "invalid_type", wantParam: "messages[0].content[0].text"},
+		{name: "numeric content", body: `{"model":"m","messages":[{"role":"user","content":42}]}`, wantCode: "invalid_type", wantParam: "messages[0].content"},
+		{name: "part without type or text", body: `{"model":"m","messages":[{"role":"developer","content":[{"foo":"bar"}]}]}`, wantCode: "invalid_value", wantParam: "messages[0].content[0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req chatCompletionsRequest
+			if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+				t.Fatal(err)
+			}
+			assertValidation(t, validateChatCompletionsRequest(req), tt.wantCode, tt.wantParam)
+		})
+	}
+}
+
+func TestValidateResponsesRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantCode  string
+		wantParam string
+	}{
+		{name: "string input", body: `{"model":"m","input":"hi"}`},
+		{name: "message list", body: `{"model":"m","input":[{"role":"user","content":[{"type":"input_text","text":"hi"}]},"plain"]}`},
+		{name: "single part", body: `{"model":"m","input":{"type":"input_text","text":"hi"}}`},
+		{name: "missing input", body: `{"model":"m"}`, wantCode: "missing_user_message", wantParam: "input"},
+		{name: "numeric input", body: `{"model":"m","input":1}`, wantCode: "invalid_type", wantParam: "input"},
+		{name: "numeric segment", body: `{"model":"m","input":["a",2]}`, wantCode: "invalid_type", wantParam: "input[1]"},
+		{name: "role not string", body: `{"model":"m","input":[{"role":1,"content":"x"}]}`, wantCode: "invalid_type", wantParam: "input[0].role"},
+		{name: "unknown role", body: `{"model":"m","input":[{"role":"robot","content":"x"}]}`, wantCode: "invalid_value", wantParam: "input[0].role"},
+		{name: "audio part", body: `{"model":"m","input":[{"role":"user","content":[{"type":"input_audio"}]}]}`, wantCode: "unsupported_content_part", wantParam: "input[0].content[0].type"},
+		{name: "type not string", body: `{"model":"m","input":{"type":7}}`, wantCode: "invalid_type", wantParam: "input.type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req responsesRequest
+			if err := json.Unmarshal([]byte(tt.body), &req); err != nil {
+				t.Fatal(err)
+			}
+			assertValidation(t, validateResponsesRequest(req), tt.wantCode, tt.wantParam)
+		})
+	}
+}
+
+func assertValidation(t *testing.T, err *apiError, wantCode, wantParam string) {
+	t.Helper()
+	if wantCode == "" {
+		if err != nil {
+			t.Errorf("unexpected error %s", err.body("en"))
+		}
+		return
+	}
+	if err == nil {
+		t.Fatalf("got no error, want %s", wantCode)
+	}
+	var body openAIErrorBody
+	if jsonErr := json.Unmarshal([]byte(err.body("en")), &body); jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+	if body.Error.Code == nil || *body.Error.Code != wantCode {
+		t.Errorf("code = %v, want %s", body.Error.Code, wantCode)
+	}
+	if body.Error.Param == nil || *body.Error.Param != wantParam {
+		t.Errorf("param = %v, want %s", body.Error.Param, wantParam)
+	}
+}
+
+func TestValidateInputLength(t *testing.T) {
+	previous := CONFIG.MaxInputChars
+	defer func() { CONFIG.MaxInputChars = previous }()
+
+	tests := []struct {
+		limit   int
+		text    string
+		wantErr bool
+	}{
+		{limit: 5, text: "你好世界！", wantErr: false},
+		{limit: 5, text: "你好，世界！", wantErr: true},
+		{limit: 0, text: strings.Repeat("a", 100000), wantErr: false},
+	}
+	for _, tt := range tests {
+		CONFIG.MaxInputChars = tt.limit
+		err := validateInputLength(tt.text, "input")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("limit %d, %d runes: error = %v, want error %v", tt.limit, len([]rune(tt.text)), err, tt.wantErr)
+		}
+		if err != nil && err.message("en") != "Input is too long: 6 characters, limit is 5" {
+			t.Errorf("message = %q", err.message("en"))
+		}
+	}
+}
+
+func TestStrictValidationMode(t *testing.T) {
+	previous := CONFIG.StrictValidation
+	defer func() { CONFIG.StrictValidation = previous }()
+
+	body := `{"model":"m","messages":[{"role":"robot","content":"hi"}]}`
+	for _, strict := range []bool{false, true} {
+		CONFIG.StrictValidation = strict
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		rec := httptest.NewRecorder()
+		newHandler(nil).ServeHTTP(rec, req)
+		// 非严格模式下未知角色会被忽略，请求因缺少 user 消息而失败；严格模式下直接指出 role 无效。
+		want := `"param":"messages"`
+		if strict {
+			want = `"param":"messages[0].role"`
+		}
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("strict=%v: status %d, body %s; want 400 with %s", strict, rec.Code, rec.Body, want)
+		}
+	}
+}
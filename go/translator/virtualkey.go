@@ -0,0 +1,239 @@
+package translator
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 虚拟密钥：通过管理接口签发的 vk- 前缀令牌，调用方用它代替方舟 API Key。服务只保存令牌的 SHA-256，
+// 请求到达时映射为身份 "vk:<name>" 与该密钥绑定的上游 API Key；停用或删除后立即失效。
+
+const virtualKeyPrefix = "vk-"
+
+type virtualKey struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	KeyHash   string    `json:"key_hash"`
+	APIKey    string    `json:"api_key"`
+	Disabled  bool      `json:"disabled,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// virtualKeyView 为管理接口返回的虚拟密钥信息：不含上游 API Key，令牌本身只在创建时返回一次。
+type virtualKeyView struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Identity   string    `json:"identity"`
+	APIKeyHint string    `json:"api_key_hint"`
+	Disabled   bool      `json:"disabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	Key        string    `json:"key,omitempty"`
+}
+
+func (k virtualKey) view() virtualKeyView {
+	hint := k.APIKey
+	if len(hint) > 4 {
+		hint = "…" + hint[len(hint)-4:]
+	}
+	return virtualKeyView{ID: k.ID, Name: k.Name, Identity: "vk:" + k.Name, APIKeyHint: hint, Disabled: k.Disabled, CreatedAt: k.CreatedAt}
+}
+
+type virtualKeyStore struct {
+	mu   sync.RWMutex
+	keys []virtualKey
+}
+
+func hashVirtualKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newVirtualKeyToken 生成 vk- 加 48 位十六进制随机数的令牌。
+func newVirtualKeyToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return virtualKeyPrefix + hex.EncodeToString(buf), nil
+}
+
+// lookup 按令牌查找启用中的虚拟密钥，比较哈希时使用常量时间比较。
+func (s *virtualKeyStore) lookup(token string) (virtualKey, bool) {
+	hash := []byte(hashVirtualKey(token))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, key := range s.keys {
+		if subtle.ConstantTimeCompare(hash, []byte(key.KeyHash)) == 1 {
+			return key, !key.Disabled
+		}
+	}
+	return virtualKey{}, false
+}
+
+func (s *virtualKeyStore) list() []virtualKeyView {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	views := make([]virtualKeyView, 0, len(s.keys))
+	for _, key := range s.keys {
+		views = append(views, key.view())
+	}
+	return views
+}
+
+func (s *virtualKeyStore) snapshot() []virtualKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.keys)
+}
+
+func (s *virtualKeyStore) replace(keys []virtualKey) {
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+}
+
+// authorize 在 authenticate 的基础上把虚拟密钥映射为对应的身份与上游 API Key，HTTP 与 gRPC 入口都通过它鉴权。
+// 限流不在这里检查：入口确定要消耗的令牌数（gRPC 批量请求需先解码出条数）后调用 checkRateLimit。
+func (s *Handler) authorize(ctx context.Context, r *http.Request) (Identity, string, error) {
+	identity, auth, err := authenticate(r)
+	if err != nil {
+		return identity, auth, err
+	}
+	if token, ok := strings.CutPrefix(auth, "Bearer "); ok && identity.Method == IdentityMethodBearer && strings.HasPrefix(token, virtualKeyPrefix) {
+		key, ok := s.virtualKeys.lookup(token)
+		if !ok {
+			return Identity{}, "", newAPIError("invalidVirtualKey")
+		}
+		identity.Name = "vk:" + key.Name
+		auth = "Bearer " + key.APIKey
+		spanFromContext(ctx).setAttr("translation.virtual_key", key.ID)
+	}
+	return identity, auth, nil
+}
+
+type virtualKeyRequest struct {
+	Name     *string `json:"name"`
+	APIKey   *string `json:"api_key"`
+	Disabled *bool   `json:"disabled"`
+}
+
+// handleAdminKeys 管理虚拟密钥：GET /admin/keys 列出，POST /admin/keys 签发（{"name","api_key"}，响应中的 key 只返回这一次），
+// PATCH /admin/keys/{id} 修改名称、上游 API Key 或停用，DELETE /admin/keys/{id} 删除。
+func (s *Handler) handleAdminKeys(ctx context.Context, w http.ResponseWriter, r *http.Request, id string) {
+	switch {
+	case id == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, listResponse[virtualKeyView]{Object: "list", Data: s.virtualKeys.list()})
+	case id == "" && r.Method == http.MethodPost:
+		var req virtualKeyRequest
+		if err := readAdminJSON(r, &req); err != nil {
+			writeAPIError(ctx, w, err)
+			return
+		}
+		if req.Name == nil || !adminName.MatchString(*req.Name) {
+			writeAPIError(ctx, w, newAPIError("invalidValue", "name", stringValue(req.Name)).withParam("name"))
+			return
+		}
+		if req.APIKey == nil || strings.TrimSpace(*req.APIKey) == "" {
+			writeAPIError(ctx, w, newAPIError("invalidValue", "api_key", "").withParam("api_key"))
+			return
+		}
+		token, err := newVirtualKeyToken()
+		if err != nil {
+			writeAPIError(ctx, w, newAPIError("serverError"))
+			return
+		}
+		key := virtualKey{
+			ID:        genID("vk"),
+			Name:      *req.Name,
+			KeyHash:   hashVirtualKey(token),
+			APIKey:    strings.TrimSpace(*req.APIKey),
+			Disabled:  req.Disabled != nil && *req.Disabled,
+			CreatedAt: time.Now().UTC(),
+		}
+		state := s.adminSnapshot()
+		if slices.ContainsFunc(state.VirtualKeys, func(k virtualKey) bool { return k.Name == key.Name }) {
+			writeAPIError(ctx, w, newAPIError("adminConflict", key.Name))
+			return
+		}
+		state.VirtualKeys = append(state.VirtualKeys, key)
+		if !s.commitAdminState(ctx, w, state) {
+			return
+		}
+		s.virtualKeys.replace(state.VirtualKeys)
+		s.auditAdmin(r, "virtual_key.create", nil, key.view())
+		view := key.view()
+		view.Key = token
+		writeJSON(w, http.StatusCreated, view)
+	case id != "" && (r.Method == http.MethodPatch || r.Method == http.MethodDelete):
+		var req virtualKeyRequest
+		if r.Method == http.MethodPatch {
+			if err := readAdminJSON(r, &req); err != nil {
+				writeAPIError(ctx, w, err)
+				return
+			}
+			if req.Name != nil && !adminName.MatchString(*req.Name) {
+				writeAPIError(ctx, w, newAPIError("invalidValue", "name", *req.Name).withParam("name"))
+				return
+			}
+			if req.APIKey != nil && strings.TrimSpace(*req.APIKey) == "" {
+				writeAPIError(ctx, w, newAPIError("invalidValue", "api_key", "").withParam("api_key"))
+				return
+			}
+		}
+		state := s.adminSnapshot()
+		index := slices.IndexFunc(state.VirtualKeys, func(k virtualKey) bool { return k.ID == id })
+		if index < 0 {
+			writeAPIError(ctx, w, newAPIError("notFound"))
+			return
+		}
+		before := state.VirtualKeys[index]
+		if r.Method == http.MethodDelete {
+			state.VirtualKeys = slices.Delete(state.VirtualKeys, index, index+1)
+			if !s.commitAdminState(ctx, w, state) {
+				return
+			}
+			s.virtualKeys.replace(state.VirtualKeys)
+			s.auditAdmin(r, "virtual_key.delete", before.view(), nil)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if req.Name != nil && *req.Name != before.Name && slices.ContainsFunc(state.VirtualKeys, func(k virtualKey) bool { return k.Name == *req.Name }) {
+			writeAPIError(ctx, w, newAPIError("adminConflict", *req.Name))
+			return
+		}
+		after := before
+		if req.Name != nil {
+			after.Name = *req.Name
+		}
+		if req.APIKey != nil {
+			after.APIKey = strings.TrimSpace(*req.APIKey)
+		}
+		if req.Disabled != nil {
+			after.Disabled = *req.Disabled
+		}
+		state.VirtualKeys[index] = after
+		if !s.commitAdminState(ctx, w, state) {
+			return
+		}
+		s.virtualKeys.replace(state.VirtualKeys)
+		s.auditAdmin(r, "virtual_key.update", before.view(), after.view())
+		writeJSON(w, http.StatusOK, after.view())
+	default:
+		writeAPIError(ctx, w, newAPIError("notFound"))
+	}
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,295 @@
+// 控制台脚本：密钥只保存在当前标签页的 sessionStorage 中，请求全部发往同源的 /v1 与 /admin 接口。
+"use strict";
+
+const $ = (id) => document.getElementById(id);
+
+for (const id of ["api-key", "admin-key"]) {
+  $(id).value = sessionStorage.getItem(id) || "";
+  $(id).addEventListener("change", () => sessionStorage.setItem(id, $(id).value));
+}
+
+function headers(keyId, extra) {
+  return Object.assign({ Authorization: "Bearer " + $(keyId).value.trim() }, extra || {});
+}
+
+// errorMessage 读取 OpenAI 风格的错误对象，失败时退回 HTTP 状态。
+async function errorMessage(resp) {
+  try {
+    const body = await resp.json();
+    if (body.error && body.error.message) return body.error.message;
+  } catch (e) { /* 非 JSON 响应 */ }
+  return resp.status + " " + resp.statusText;
+}
+
+async function adminGet(path) {
+  const resp = await fetch(path, { headers: headers("admin-key") });
+  if (!resp.ok) throw new Error(await errorMessage(resp));
+  return resp.json();
+}
+
+function setStatus(id, text, isError) {
+  $(id).textContent = text;
+  $(id).classList.toggle("error", !!isError);
+}
+
+// renderTable 按列定义渲染表格；列为 [标题, 取值函数, 是否数字]。
+function renderTable(id, columns, rows) {
+  const table = $(id);
+  table.replaceChildren();
+  const head = table.createTHead().insertRow();
+  for (const [title] of columns) {
+    const th = document.createElement("th");
+    th.textContent = title;
+    head.appendChild(th);
+  }
+  const body = table.createTBody();
+  if (rows.length === 0) {
+    const cell = body.insertRow().insertCell();
+    cell.colSpan = columns.length;
+    cell.textContent = "暂无数据";
+    return;
+  }
+  for (const row of rows) {
+    const tr = body.insertRow();
+    for (const [, value, numeric] of columns) {
+      const cell = tr.insertCell();
+      const v = value(row);
+      cell.textContent = v === undefined || v === null ? "" : String(v);
+      if (numeric) cell.className = "num";
+    }
+  }
+}
+
+const formatTime = (value) => new Date(value).toLocaleString();
+
+// ---- 页面切换 ----
+
+let requestsTimer = null;
+
+function showPage(page) {
+  for (const button of document.querySelectorAll("nav button")) {
+    button.classList.toggle("active", button.dataset.page === page);
+  }
+  for (const section of document.querySelectorAll(".page")) {
+    section.classList.toggle("active", section.id === page);
+  }
+  clearInterval(requestsTimer);
+  if (page === "requests") requestsTimer = setInterval(() => loaders.requests(), 2000);
+  if (loaders[page]) loaders[page]();
+}
+
+for (const button of document.querySelectorAll("nav button")) {
+  button.addEventListener("click", () => showPage(button.dataset.page));
+}
+for (const button of document.querySelectorAll("button.refresh")) {
+  button.addEventListener("click", () => loaders[button.dataset.load]());
+}
+
+// ---- 翻译试用 ----
+
+let languagesLoaded = false;
+
+async function loadLanguages() {
+  if (languagesLoaded || !$("api-key").value.trim()) return;
+  const resp = await fetch("/v1/languages?supported=true", { headers: headers("api-key") });
+  if (!resp.ok) {
+    setStatus("status", "加载语言列表失败：" + (await errorMessage(resp)), true);
+    return;
+  }
+  const { data } = await resp.json();
+  for (const entry of data) {
+    const label = entry.zh_name + " (" + entry.code + ")";
+    $("source").add(new Option(label, entry.code));
+    $("target").add(new Option(label, entry.code, entry.code === "zh", entry.code === "zh"));
+  }
+  languagesLoaded = true;
+  setStatus("status", "");
+}
+
+$("api-key").addEventListener("change", loadLanguages);
+
+$("swap").addEventListener("click", () => {
+  const source = $("source").value;
+  if (!source) return;
+  $("source").value = $("target").value;
+  $("target").value = source;
+});
+
+let controller = null;
+
+$("stop").addEventListener("click", () => controller && controller.abort());
+
+$("translate").addEventListener("click", async () => {
+  const text = $("input").value;
+  if (!text.trim()) return;
+  await loadLanguages();
+  const options = { target_language: $("target").value };
+  if ($("source").value) options.source_language = $("source").value;
+
+  controller = new AbortController();
+  $("translate").disabled = true;
+  $("stop").disabled = false;
+  $("output").textContent = "";
+  setStatus("status", "翻译中…");
+  const started = performance.now();
+  try {
+    const resp = await fetch("/v1/chat/completions", {
+      method: "POST",
+      headers: headers("api-key", { "Content-Type": "application/json" }),
+      body: JSON.stringify({
+        model: $("model").value.trim(),
+        stream: true,
+        stream_options: { include_usage: true },
+        messages: [{ role: "user", content: text }],
+        translation_options: options,
+      }),
+      signal: controller.signal,
+    });
+    if (!resp.ok) throw new Error(await errorMessage(resp));
+    const summary = await readStream(resp.body, (delta) => { $("output").textContent += delta; });
+    const parts = ["完成，用时 " + ((performance.now() - started) / 1000).toFixed(1) + " 秒"];
+    if (summary.detected) parts.push("识别的源语言：" + summary.detected);
+    if (summary.usage) parts.push("token：" + summary.usage.prompt_tokens + " + " + summary.usage.completion_tokens);
+    setStatus("status", parts.join("，"));
+  } catch (err) {
+    setStatus("status", err.name === "AbortError" ? "已停止" : "翻译失败：" + err.message, err.name !== "AbortError");
+  } finally {
+    $("translate").disabled = false;
+    $("stop").disabled = true;
+    controller = null;
+  }
+});
+
+// readStream 解析 chat.completion.chunk SSE 流，逐段回调增量文本，返回识别的源语言与用量。
+async function readStream(body, onDelta) {
+  const reader = body.pipeThrough(new TextDecoderStream()).getReader();
+  const summary = {};
+  let buffer = "";
+  for (;;) {
+    const { value, done } = await reader.read();
+    if (done) return summary;
+    buffer += value;
+    let index;
+    while ((index = buffer.indexOf("\n\n")) >= 0) {
+      const event = buffer.slice(0, index);
+      buffer = buffer.slice(index + 2);
+      for (const line of event.split("\n")) {
+        if (!line.startsWith("data:")) continue;
+        const data = line.slice(5).trim();
+        if (data === "[DONE]") return summary;
+        const chunk = JSON.parse(data);
+        if (chunk.error) throw new Error(chunk.error.message);
+        if (chunk.detected_source_language) summary.detected = chunk.detected_source_language;
+        if (chunk.usage) summary.usage = chunk.usage;
+        const delta = chunk.choices && chunk.choices[0] && chunk.choices[0].delta;
+        if (delta && delta.content) onDelta(delta.content);
+      }
+    }
+  }
+}
+
+// ---- 管理页面 ----
+
+function usageQuery() {
+  const params = new URLSearchParams({ group_by: $("usage-group").value });
+  if ($("usage-from").value) params.set("from", $("usage-from").value);
+  if ($("usage-to").value) params.set("to", $("usage-to").value);
+  return params;
+}
+
+const usageColumns = {
+  day: [["日期", (r) => r.day]],
+  key: [["身份", (r) => r.key]],
+  model: [["模型", (r) => r.model]],
+  language_pair: [["源语言", (r) => r.source_language], ["目标语言", (r) => r.target_language]],
+};
+
+const loaders = {
+  async metrics() {
+    try {
+      const data = await adminGet("/admin/metrics");
+      const upstream = data.upstream.enabled ? "上游已启用" : "上游已停用";
+      setStatus("metrics-summary", "运行 " + Math.round(data.uptime_seconds / 60) + " 分钟，进行中 " + data.in_flight + " 个请求，" + upstream + "（" + data.upstream.base_url + "）");
+      renderTable("metrics-table", [
+        ["路由", (r) => r.route],
+        ["请求数", (r) => r.requests, true],
+        ["错误数", (r) => r.errors, true],
+        ["平均耗时 (ms)", (r) => r.avg_latency_ms.toFixed(1), true],
+        ["状态码", (r) => Object.entries(r.status).map(([code, n]) => code + "×" + n).join("  ")],
+      ], data.routes);
+    } catch (err) {
+      setStatus("metrics-summary", err.message, true);
+    }
+  },
+
+  async usage() {
+    try {
+      const data = await adminGet("/admin/usage?" + usageQuery());
+      const columns = data.group_by.flatMap((d) => usageColumns[d]).concat([
+        ["请求数", (r) => r.requests, true],
+        ["输入 token", (r) => r.input_tokens, true],
+        ["输出 token", (r) => r.output_tokens, true],
+        ["原文字符", (r) => r.input_characters, true],
+        ["译文字符", (r) => r.output_characters, true],
+        ["费用 (" + data.currency + ")", (r) => r.cost.toFixed(4), true],
+      ]);
+      renderTable("usage-table", columns, data.data);
+      setStatus("usage-summary", "合计 " + data.total.requests + " 次请求，费用 " + data.total.cost.toFixed(4) + " " + data.currency);
+    } catch (err) {
+      setStatus("usage-summary", err.message, true);
+    }
+  },
+
+  async requests() {
+    try {
+      const data = await adminGet("/admin/requests");
+      renderTable("requests-table", [
+        ["开始时间", (r) => formatTime(r.started_at)],
+        ["已耗时 (ms)", (r) => r.duration_ms, true],
+        ["接口", (r) => r.method + " " + r.path],
+        ["身份", (r) => r.identity],
+        ["模型", (r) => r.model],
+        ["流式", (r) => (r.stream ? "是" : "")],
+        ["客户端", (r) => r.client_address],
+      ], data.data);
+    } catch (err) {
+      clearInterval(requestsTimer);
+      renderTable("requests-table", [["错误", (r) => r]], [err.message]);
+    }
+  },
+
+  async errors() {
+    try {
+      const identity = $("errors-identity").value.trim();
+      const data = await adminGet("/admin/errors" + (identity ? "?identity=" + encodeURIComponent(identity) : ""));
+      renderTable("errors-table", [
+        ["时间", (r) => formatTime(r.time)],
+        ["状态", (r) => r.status, true],
+        ["错误码", (r) => r.code],
+        ["信息", (r) => r.message],
+        ["接口", (r) => (r.method || "") + " " + (r.path || "")],
+        ["身份", (r) => r.identity],
+        ["模型", (r) => r.model],
+      ], data.data);
+    } catch (err) {
+      renderTable("errors-table", [["错误", (r) => r]], [err.message]);
+    }
+  },
+};
+
+$("usage-csv").addEventListener("click", async () => {
+  const params = usageQuery();
+  params.set("format", "csv");
+  const resp = await fetch("/admin/usage?" + params, { headers: headers("admin-key") });
+  if (!resp.ok) {
+    setStatus("usage-summary", await errorMessage(resp), true);
+    return;
+  }
+  const link = document.createElement("a");
+  link.href = URL.createObjectURL(await resp.blob());
+  link.download = "usage.csv";
+  link.click();
+  setTimeout(() => URL.revokeObjectURL(link.href), 1000);
+});
+
+loadLanguages();
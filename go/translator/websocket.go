@@ -0,0 +1,257 @@
+package translator
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 基于标准库的最小 WebSocket 服务端实现（RFC 6455）：仅支持服务端角色、
+// 文本/二进制消息、分片重组以及 ping/pong/close 控制帧，不支持扩展。
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+const (
+	wsCloseNormal        = 1000
+	wsCloseProtocolError = 1002
+	wsCloseTooLarge      = 1009
+	wsCloseInternalError = 1011
+)
+
+var errWebSocketClosed = errors.New("websocket closed")
+
+type wsConn struct {
+	conn       net.Conn
+	reader     *bufio.Reader
+	maxMessage int64
+
+	writeMu sync.Mutex
+	closed  bool
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeWebSocket 完成握手并接管底层连接；失败时尚未写出任何响应，由调用方返回错误。
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request, maxMessage int64) (*wsConn, error) {
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, fmt.Errorf("unsupported websocket version %q", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := strings.TrimSpace(r.Header.Get("Sec-WebSocket-Key"))
+	if key == "" {
+		return nil, errors.New("missing Sec-WebSocket-Key")
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// 接管后清除 http.Server 设置的读写超时，由会话自行管理。
+	_ = conn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	accept := base64.StdEncoding.EncodeToString(sum[:])
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n"
+	if _, err := rw.WriteString(response); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &wsConn{conn: conn, reader: rw.Reader, maxMessage: maxMessage}, nil
+}
+
+// readMessage 读取一条完整消息，期间自动应答 ping 并处理 close；对端关闭时返回 io.EOF。
+func (c *wsConn) readMessage() (byte, []byte, error) {
+	var opcode byte
+	var message []byte
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			code := uint16(wsCloseNormal)
+			if len(payload) >= 2 {
+				code = binary.BigEndian.Uint16(payload[:2])
+			}
+			// 1005/1006/1015 只用于本地表示，不允许出现在 close 帧中。
+			if code == 1005 || code == 1006 || code == 1015 {
+				code = wsCloseNormal
+			}
+			c.close(code, "")
+			return 0, nil, io.EOF
+		case wsOpText, wsOpBinary:
+			if message != nil {
+				c.close(wsCloseProtocolError, "expected continuation frame")
+				return 0, nil, errWebSocketClosed
+			}
+			opcode = op
+			message = payload
+		case wsOpContinuation:
+			if message == nil {
+				c.close(wsCloseProtocolError, "unexpected continuation frame")
+				return 0, nil, errWebSocketClosed
+			}
+			message = append(message, payload...)
+		default:
+			c.close(wsCloseProtocolError, "unknown opcode")
+			return 0, nil, errWebSocketClosed
+		}
+		if int64(len(message)) > c.maxMessage {
+			c.close(wsCloseTooLarge, "message too large")
+			return 0, nil, errWebSocketClosed
+		}
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		c.close(wsCloseProtocolError, "reserved bits set")
+		return false, 0, nil, errWebSocketClosed
+	}
+	if header[1]&0x80 == 0 {
+		c.close(wsCloseProtocolError, "client frames must be masked")
+		return false, 0, nil, errWebSocketClosed
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= wsOpClose && (length > 125 || !fin) {
+		c.close(wsCloseProtocolError, "invalid control frame")
+		return false, 0, nil, errWebSocketClosed
+	}
+	if length > uint64(c.maxMessage) {
+		c.close(wsCloseTooLarge, "message too large")
+		return false, 0, nil, errWebSocketClosed
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame 写出一个不分片、不加掩码的服务端帧，可被多个 goroutine 并发调用。
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return errWebSocketClosed
+	}
+
+	header := make([]byte, 0, 10)
+	header = append(header, 0x80|opcode)
+	switch length := len(payload); {
+	case length <= 125:
+		header = append(header, byte(length))
+	case length <= 0xFFFF:
+		header = append(header, 126, byte(length>>8), byte(length))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		c.closed = true
+		c.conn.Close()
+		return err
+	}
+	return nil
+}
+
+func (c *wsConn) writeJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.writeFrame(wsOpText, data)
+}
+
+// close 发送 close 帧后关闭底层连接，可重复调用。
+func (c *wsConn) close(code uint16, reason string) {
+	payload := binary.BigEndian.AppendUint16(nil, code)
+	payload = append(payload, reason...)
+	_ = c.writeFrame(wsOpClose, payload)
+
+	c.writeMu.Lock()
+	c.closed = true
+	c.writeMu.Unlock()
+	c.conn.Close()
+}
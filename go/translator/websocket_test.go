@@ -0,0 +1,190 @@
+package translator
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+var testMask = [4]byte{0x12, 0x34, 0x56, 0x78}
+
+// clientFrame 按客户端规则编码一帧（必须加掩码）。
+func clientFrame(fin bool, opcode byte, payload []byte) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	switch length := len(payload); {
+	case length <= 125:
+		frame = append(frame, 0x80|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, 0x80|126, byte(length>>8), byte(length))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+	frame = append(frame, testMask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^testMask[i%4])
+	}
+	return frame
+}
+
+type serverFrame struct {
+	opcode  byte
+	payload []byte
+}
+
+// readServerFrame 读取一个服务端帧；服务端只发送不分片、不加掩码的帧。
+func readServerFrame(r *bufio.Reader) (serverFrame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return serverFrame{}, err
+	}
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return serverFrame{}, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return serverFrame{}, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return serverFrame{}, err
+	}
+	return serverFrame{opcode: header[0] & 0x0F, payload: payload}, nil
+}
+
+func closeCode(frame serverFrame) uint16 {
+	if frame.opcode != wsOpClose || len(frame.payload) < 2 {
+		return 0
+	}
+	return binary.BigEndian.Uint16(frame.payload[:2])
+}
+
+func TestWebSocketReadMessage(t *testing.T) {
+	long := []byte(strings.Repeat("a", 200))
+	tests := []struct {
+		name        string
+		frames      [][]byte
+		wantMessage string
+		wantErr     error
+		wantPong    string
+		wantClose   uint16
+	}{
+		{name: "text", frames: [][]byte{clientFrame(true, wsOpText, []byte("hello"))}, wantMessage: "hello"},
+		{name: "16-bit length", frames: [][]byte{clientFrame(true, wsOpBinary, long)}, wantMessage: string(long)},
+		{
+			name: "fragmented with interleaved ping",
+			frames: [][]byte{
+				clientFrame(false, wsOpText, []byte("hel")),
+				clientFrame(true, wsOpPing, []byte("p")),
+				clientFrame(true, wsOpContinuation, []byte("lo")),
+			},
+			wantMessage: "hello", wantPong: "p",
+		},
+		{name: "client close", frames: [][]byte{clientFrame(true, wsOpClose, []byte{0x03, 0xE8})}, wantErr: io.EOF, wantClose: wsCloseNormal},
+		{name: "unmasked", frames: [][]byte{{0x81, 0x02, 'h', 'i'}}, wantErr: errWebSocketClosed, wantClose: wsCloseProtocolError},
+		{name: "reserved bits", frames: [][]byte{append([]byte{0xC1}, clientFrame(true, wsOpText, []byte("x"))[1:]...)}, wantErr: errWebSocketClosed, wantClose: wsCloseProtocolError},
+		{name: "orphan continuation", frames: [][]byte{clientFrame(true, wsOpContinuation, []byte("x"))}, wantErr: errWebSocketClosed, wantClose: wsCloseProtocolError},
+		{
+			name:    "new message inside fragmented message",
+			frames:  [][]byte{clientFrame(false, wsOpText, []byte("a")), clientFrame(true, wsOpText, []byte("b"))},
+			wantErr: errWebSocketClosed, wantClose: wsCloseProtocolError,
+		},
+		{name: "fragmented control frame", frames: [][]byte{clientFrame(false, wsOpPing, nil)}, wantErr: errWebSocketClosed, wantClose: wsCloseProtocolError},
+		{name: "oversized control frame", frames: [][]byte{clientFrame(true, wsOpPing, long[:126])}, wantErr: errWebSocketClosed, wantClose: wsCloseProtocolError},
+		{name: "unknown opcode", frames: [][]byte{clientFrame(true, 0x3, nil)}, wantErr: errWebSocketClosed, wantClose: wsCloseProtocolError},
+		{name: "frame too large", frames: [][]byte{clientFrame(true, wsOpText, append(long, long...))}, wantErr: errWebSocketClosed, wantClose: wsCloseTooLarge},
+		{
+			name:    "message too large",
+			frames:  [][]byte{clientFrame(false, wsOpText, long), clientFrame(true, wsOpContinuation, long)},
+			wantErr: errWebSocketClosed, wantClose: wsCloseTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConn, clientConn := net.Pipe()
+			defer clientConn.Close()
+			conn := &wsConn{conn: serverConn, reader: bufio.NewReader(serverConn), maxMessage: 300}
+
+			go func() {
+				for _, frame := range tt.frames {
+					if _, err := clientConn.Write(frame); err != nil {
+						return
+					}
+				}
+			}()
+			received := make(chan []serverFrame)
+			go func() {
+				var frames []serverFrame
+				reader := bufio.NewReader(clientConn)
+				for {
+					frame, err := readServerFrame(reader)
+					if err != nil {
+						received <- frames
+						return
+					}
+					frames = append(frames, frame)
+				}
+			}()
+
+			_, message, err := conn.readMessage()
+			serverConn.Close()
+			frames := <-received
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("readMessage error = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil || string(message) != tt.wantMessage {
+				t.Fatalf("readMessage = %q, %v; want %q", message, err, tt.wantMessage)
+			}
+			var pong string
+			var code uint16
+			for _, frame := range frames {
+				if frame.opcode == wsOpPong {
+					pong = string(frame.payload)
+				}
+				if frame.opcode == wsOpClose {
+					code = closeCode(frame)
+				}
+			}
+			if pong != tt.wantPong {
+				t.Errorf("pong payload = %q, want %q", pong, tt.wantPong)
+			}
+			if code != tt.wantClose {
+				t.Errorf("close code = %d, want %d", code, tt.wantClose)
+			}
+		})
+	}
+}
+
+func TestWebSocketWriteFrameLengths(t *testing.T) {
+	for _, size := range []int{0, 125, 126, 0xFFFF, 0x10000} {
+		serverConn, clientConn := net.Pipe()
+		conn := &wsConn{conn: serverConn, reader: bufio.NewReader(serverConn), maxMessage: 1 << 20}
+		payload := []byte(strings.Repeat("x", size))
+		go conn.writeFrame(wsOpBinary, payload)
+
+		frame, err := readServerFrame(bufio.NewReader(clientConn))
+		if err != nil || frame.opcode != wsOpBinary || len(frame.payload) != size {
+			t.Errorf("size %d: got opcode %d, %d bytes, %v", size, frame.opcode, len(frame.payload), err)
+		}
+		serverConn.Close()
+		clientConn.Close()
+	}
+}